@@ -0,0 +1,38 @@
+package main
+
+import (
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/basicauth"
+)
+
+// adminRealm is the realm of the BasicAuth challenge of the admin routes
+const adminRealm = "intelxtealer admin"
+
+// adminAuth protects the /admin route group with the ADMIN_USER and
+// ADMIN_PASSWORD credentials, read on every request so a reload applies them.
+// Without credentials the admin routes are disabled.
+func adminAuth() fiber.Handler {
+	auth := basicauth.New(basicauth.Config{
+		Realm: adminRealm,
+		Authorizer: func(user, pass string) bool {
+			cfg := currentConfig()
+			// Both are compared so a wrong username takes as long as a wrong password
+			userOK := subtle.ConstantTimeCompare([]byte(user), []byte(cfg.AdminUser))
+			passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(cfg.AdminPassword))
+			return userOK&passOK == 1
+		},
+		Unauthorized: func(c fiber.Ctx) error {
+			c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="`+adminRealm+`", charset="UTF-8"`)
+			return jsonError(c, fiber.StatusUnauthorized, errCodeUnauthorized, "Admin credentials required", "")
+		},
+	})
+
+	return func(c fiber.Ctx) error {
+		if cfg := currentConfig(); cfg.AdminUser == "" || cfg.AdminPassword == "" {
+			return jsonError(c, fiber.StatusForbidden, errCodeForbidden, "Admin routes are disabled, set ADMIN_USER and ADMIN_PASSWORD", "")
+		}
+		return auth(c)
+	}
+}
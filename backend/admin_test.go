@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestAdminAuth(t *testing.T) {
+	setConfig(Config{AdminUser: testAdminUser, AdminPassword: testAdminPassword})
+	defer setConfig(Config{})
+
+	// An unsupported endpoint is refused before the database is used, so a
+	// 400 shows the request got through to the handler
+	const path = "/api/admin/explain?endpoint=entries"
+	for _, tt := range []struct {
+		name       string
+		user, pass string
+		status     int
+		code       string
+	}{
+		{"no credentials", "", "", fiber.StatusUnauthorized, errCodeUnauthorized},
+		{"wrong password", testAdminUser, "guess", fiber.StatusUnauthorized, errCodeUnauthorized},
+		{"wrong user", "root", testAdminPassword, fiber.StatusUnauthorized, errCodeUnauthorized},
+		{"admin", testAdminUser, testAdminPassword, fiber.StatusBadRequest, errCodeBadRequest},
+	} {
+		req := httptest.NewRequest("GET", path, nil)
+		if tt.user != "" {
+			req.SetBasicAuth(tt.user, tt.pass)
+		}
+		resp, err := newApp().Test(req, testConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body ErrorResponse
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status || body.Error.Code != tt.code {
+			t.Errorf("%s: status = %d (%s), want %d (%s)", tt.name, resp.StatusCode, body.Error.Code, tt.status, tt.code)
+		}
+
+		// Refused requests are challenged so browsers ask for credentials
+		challenge := resp.Header.Get(fiber.HeaderWWWAuthenticate)
+		if want := `Basic realm="` + adminRealm + `", charset="UTF-8"`; tt.status == fiber.StatusUnauthorized && challenge != want {
+			t.Errorf("%s: WWW-Authenticate = %q, want %q", tt.name, challenge, want)
+		}
+	}
+
+	// The admin operations moved out of the regular API
+	var body ErrorResponse
+	if status := getJSON(t, "/api/audit", &body); status != fiber.StatusNotFound {
+		t.Errorf("GET /api/audit status = %d, want 404", status)
+	}
+}
+
+func TestAdminAuthDisabled(t *testing.T) {
+	setConfig(Config{AdminUser: testAdminUser})
+	defer setConfig(Config{})
+
+	// Without a password even an empty one would match, so nothing gets in
+	var body ErrorResponse
+	if status := doJSON(t, adminRequest("GET", "/api/admin/audit", nil), &body); status != fiber.StatusForbidden || body.Error.Code != errCodeForbidden {
+		t.Errorf("status = %d (%s), want 403 (%s)", status, body.Error.Code, errCodeForbidden)
+	}
+}
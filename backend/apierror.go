@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Codes of APIError, stable for clients to switch on
+const (
+	errCodeBadRequest  = "bad_request"
+	errCodeNotFound    = "not_found"
+	errCodeConflict    = "conflict"
+	errCodeTooLarge    = "too_large"
+	errCodeInternal    = "internal_error"
+	errCodeUnavailable = "database_unavailable"
+	errCodeTimeout     = "timeout"
+	// errCodeUnauthorized and errCodeForbidden are returned by the admin routes
+	errCodeUnauthorized = "unauthorized"
+	errCodeForbidden    = "forbidden"
+)
+
+// APIError is the body of every error response, wrapped as {"error": {...}}
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details string `json:"details,omitempty"`
+}
+
+// ErrorResponse is the envelope of an error response
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// jsonError sends an error response with the given status
+func jsonError(c fiber.Ctx, status int, code, msg, details string) error {
+	return c.Status(status).JSON(ErrorResponse{
+		Error: APIError{Code: code, Message: msg, Details: details},
+	})
+}
+
+// serverError reports a failed operation. Lost database connections and
+// queries the server cancelled after SEARCH_TIMEOUT are reported as 503 and
+// queries cut off by REQUEST_TIMEOUT as 504, so clients can tell when
+// retrying makes sense; anything else is a 500.
+func serverError(c fiber.Ctx, msg string, err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return jsonError(c, fiber.StatusGatewayTimeout, errCodeTimeout, msg, err.Error())
+	case isStatementTimeout(err):
+		return jsonError(c, fiber.StatusServiceUnavailable, errCodeTimeout, msg, err.Error())
+	case isTransientError(err):
+		return jsonError(c, fiber.StatusServiceUnavailable, errCodeUnavailable, msg, err.Error())
+	default:
+		return jsonError(c, fiber.StatusInternalServerError, errCodeInternal, msg, err.Error())
+	}
+}
+
+// errorHandler sends errors returned by handlers and Fiber itself, such as
+// unknown routes, in the same envelope
+func errorHandler(c fiber.Ctx, err error) error {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		code := errCodeInternal
+		switch {
+		case fiberErr.Code == fiber.StatusNotFound:
+			code = errCodeNotFound
+		case fiberErr.Code == fiber.StatusConflict:
+			code = errCodeConflict
+		case fiberErr.Code == fiber.StatusRequestEntityTooLarge:
+			code = errCodeTooLarge
+		case fiberErr.Code < fiber.StatusInternalServerError:
+			code = errCodeBadRequest
+		}
+		return jsonError(c, fiberErr.Code, code, fiberErr.Message, "")
+	}
+	return serverError(c, "Internal server error", err)
+}
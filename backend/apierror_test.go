@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestErrorEnvelope(t *testing.T) {
+	tests := []struct {
+		method, path, body string
+		status             int
+		code               string
+	}{
+		{"GET", "/api/entries?page=abc", "", fiber.StatusBadRequest, errCodeBadRequest},
+		{"GET", "/api/processed-files?pageSize=ten", "", fiber.StatusBadRequest, errCodeBadRequest},
+		{"GET", "/api/processed-files?from=2024-13-01", "", fiber.StatusBadRequest, errCodeBadRequest},
+		{"GET", "/api/entries?since=week", "", fiber.StatusBadRequest, errCodeBadRequest},
+		{"POST", "/api/search", "{not json", fiber.StatusBadRequest, errCodeBadRequest},
+		{"GET", "/api/stats/timeline?bucket=year", "", fiber.StatusBadRequest, errCodeBadRequest},
+		{"GET", "/api/diff?runA=1", "", fiber.StatusBadRequest, errCodeBadRequest},
+		{"GET", "/api/no-such-route", "", fiber.StatusNotFound, errCodeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := newApp().Test(req, testConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var body map[string]map[string]any
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("error body is not an envelope: %v", err)
+			}
+			if resp.StatusCode != tt.status || body["error"]["code"] != tt.code || body["error"]["message"] == "" {
+				t.Errorf("got %d %v, want %d with code %s and a message", resp.StatusCode, body, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestServerErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{errors.New("syntax error"), fiber.StatusInternalServerError, errCodeInternal},
+		{fmt.Errorf("dial: %w", syscall.ECONNREFUSED), fiber.StatusServiceUnavailable, errCodeUnavailable},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), fiber.StatusGatewayTimeout, errCodeTimeout},
+		{&pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"}, fiber.StatusServiceUnavailable, errCodeTimeout},
+	}
+
+	for _, tt := range tests {
+		app := fiber.New()
+		app.Get("/", func(c fiber.Ctx) error {
+			return serverError(c, "Failed to query database", tt.err)
+		})
+		resp, err := app.Test(httptest.NewRequest("GET", "/", nil), testConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var body ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		want := APIError{Code: tt.code, Message: "Failed to query database", Details: tt.err.Error()}
+		if resp.StatusCode != tt.status || body.Error != want {
+			t.Errorf("serverError(%v) = %d %+v, want %d %+v", tt.err, resp.StatusCode, body.Error, tt.status, want)
+		}
+	}
+}
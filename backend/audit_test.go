@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://a.com", User: "alice", Pass: "one"},
+		Entry{URL: "https://a.com", User: "alice", Pass: "one"},
+		Entry{URL: "https://a.com", User: "alice", Pass: "one"},
+		Entry{URL: "https://b.com", User: "bob", Pass: "two"},
+	)
+
+	var audit AuditResponse
+	getAdminJSON(t, "/api/admin/audit", &audit)
+	if len(audit.Entries) != 0 || audit.Total != 0 {
+		t.Fatalf("audit log before any delete = %+v, want empty", audit)
+	}
+
+	// Reporting duplicates doesn't delete anything
+	var report DuplicatesResponse
+	getJSON(t, "/api/duplicates", &report)
+	var removal map[string]any
+	getJSON(t, "/api/duplicates?remove=true&key=url_user_pass", &removal)
+
+	resp, err := newApp().Test(adminRequest("POST", "/api/admin/purge", strings.NewReader(`{"confirm": "DELETE-ALL"}`)), testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	getAdminJSON(t, "/api/admin/audit", &audit)
+	if audit.Total != 2 || len(audit.Entries) != 2 {
+		t.Fatalf("audit log = %+v, want 2 entries", audit)
+	}
+
+	// Newest first
+	purge, dedupe := audit.Entries[0], audit.Entries[1]
+	if purge.Action != auditPurge || purge.AffectedCount != 2 {
+		t.Errorf("purge audit entry = %+v, want %s of 2 entries", purge, auditPurge)
+	}
+	if dedupe.Action != auditRemoveDuplicates || dedupe.AffectedCount != 2 {
+		t.Errorf("duplicates audit entry = %+v, want %s of 2 entries", dedupe, auditRemoveDuplicates)
+	}
+	if dedupe.Actor == "" || dedupe.CreatedAt.IsZero() {
+		t.Errorf("duplicates audit entry = %+v, want an actor and a time", dedupe)
+	}
+	if !strings.HasPrefix(purge.Actor, testAdminUser+"@") {
+		t.Errorf("purge actor = %q, want the admin user and address", purge.Actor)
+	}
+
+	var details map[string]any
+	if err := json.Unmarshal(dedupe.Details, &details); err != nil {
+		t.Fatal(err)
+	}
+	if details["key"] != "url_user_pass" {
+		t.Errorf("duplicates audit details = %v, want the key", details)
+	}
+
+	// The audit log outlives the purge
+	getAdminJSON(t, "/api/admin/audit?pageSize=1&page=2", &audit)
+	if len(audit.Entries) != 1 || audit.Entries[0].Action != auditRemoveDuplicates || !audit.HasPrevious {
+		t.Errorf("second page = %+v, want the duplicates removal", audit)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+
+	"github.com/jackc/pgx/v5"
+
+	"hello-world/backend/parser"
+)
+
+// fileProgress is the checkpoint of a file whose import hasn't finished,
+// stored in file_progress with every committed batch. End is where the last
+// committed entry ends and Stats counts what was read up to there. SHA256 is
+// the hash of the first HashedBytes bytes of the file, as far as it was read
+// when the checkpoint was saved, which is at least up to End.
+type fileProgress struct {
+	SHA256      string
+	HashedBytes int64
+	End         parser.Position
+	Stats       ParseStats
+}
+
+// checkpointInput returns r and where it's positioned when an import of it
+// can resume from a checkpoint, that is r can be read again from an offset.
+// Pipes and sockets can't seek, and offsets into UTF-16 text don't match the
+// decoded lines. r is left where it was.
+func checkpointInput(r io.Reader) (io.ReadSeeker, int64, bool, error) {
+	seeker, ok := r.(io.ReadSeeker)
+	if !ok {
+		return nil, 0, false, nil
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, 0, false, nil
+	}
+
+	head := make([]byte, 2)
+	n, err := io.ReadFull(seeker, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, 0, false, err
+	}
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return nil, 0, false, err
+	}
+	if parser.IsUTF16(head[:n]) {
+		return nil, 0, false, nil
+	}
+	return seeker, start, true, nil
+}
+
+// hashInput returns the hex encoded SHA-256 of input from start to its end,
+// and positions it at start again
+func hashInput(input io.ReadSeeker, start int64) (string, error) {
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, input); err != nil {
+		return "", err
+	}
+	if _, err := input.Seek(start, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// inputHasher hashes an input as the parser reads it, for the checkpoints
+// and inputs that can't be read again. Bytes read again after resuming from
+// a checkpoint were hashed before and are skipped.
+type inputHasher struct {
+	hash hash.Hash
+	size int64
+	skip int64
+}
+
+func (h *inputHasher) Write(p []byte) (int, error) {
+	n := len(p)
+	if h.skip > 0 {
+		skipped := min(int64(len(p)), h.skip)
+		p = p[skipped:]
+		h.skip -= skipped
+	}
+	h.hash.Write(p)
+	h.size += int64(len(p))
+	return n, nil
+}
+
+// sum returns the hex encoded SHA-256 of the bytes hashed so far
+func (h *inputHasher) sum() string {
+	return hex.EncodeToString(h.hash.Sum(nil))
+}
+
+// resumeInput positions input, which starts at start, where the import of it
+// goes on and returns the hasher to feed it from there. When progress is a
+// checkpoint of the same content, only the bytes it hashed are read to check
+// that, and input is left at the end of the checkpoint with resumed set.
+// Otherwise the import starts over from start. The in-file cache of
+// DEDUPE_CACHE_SIZE starts empty after resuming, so only DEDUPE_SCOPE drops
+// entries repeated on both sides of the checkpoint.
+func resumeInput(input io.ReadSeeker, start int64, progress *fileProgress) (*inputHasher, bool, error) {
+	if progress != nil {
+		hasher := &inputHasher{hash: sha256.New()}
+		n, err := io.CopyN(hasher, input, progress.HashedBytes)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, false, err
+		}
+		if n == progress.HashedBytes && hasher.sum() == progress.SHA256 {
+			hasher.skip = progress.HashedBytes - progress.End.Offset
+			_, err := input.Seek(start+progress.End.Offset, io.SeekStart)
+			return hasher, true, err
+		}
+		if _, err := input.Seek(start, io.SeekStart); err != nil {
+			return nil, false, err
+		}
+	}
+	return &inputHasher{hash: sha256.New()}, false, nil
+}
+
+// loadFileProgress returns the checkpoint of fileName, or nil when its last
+// import finished or never started
+func loadFileProgress(ctx context.Context, db queryRower, fileName string) (*fileProgress, error) {
+	var progress fileProgress
+	var stats []byte
+	err := db.QueryRow(ctx,
+		"SELECT sha256, hashed_bytes, byte_offset, line_no, stats FROM file_progress WHERE filename = $1",
+		fileName).Scan(&progress.SHA256, &progress.HashedBytes, &progress.End.Offset, &progress.End.Line, &stats)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(stats, &progress.Stats); err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
+
+// saveFileProgress stores the checkpoint of fileName, replacing the previous one
+func saveFileProgress(ctx context.Context, db execer, fileName string, progress fileProgress) error {
+	stats, err := json.Marshal(progress.Stats)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(ctx,
+		"INSERT INTO file_progress (filename, sha256, hashed_bytes, byte_offset, line_no, stats) VALUES ($1, $2, $3, $4, $5, $6) "+
+			"ON CONFLICT (filename) DO UPDATE SET sha256 = $2, hashed_bytes = $3, byte_offset = $4, line_no = $5, stats = $6, updated_at = NOW()",
+		fileName, progress.SHA256, progress.HashedBytes, progress.End.Offset, progress.End.Line, stats)
+	return err
+}
+
+// deleteFileProgress drops the checkpoint of fileName once it was imported
+func deleteFileProgress(ctx context.Context, db execer, fileName string) error {
+	_, err := db.Exec(ctx, "DELETE FROM file_progress WHERE filename = $1", fileName)
+	return err
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"hello-world/backend/parser"
+)
+
+func TestCheckpointInput(t *testing.T) {
+	seeker := strings.NewReader("skipped\nhttps://a.com:alice:one\n")
+	if _, err := seeker.Seek(8, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		r         io.Reader
+		wantOK    bool
+		wantStart int64
+	}{
+		{"seekable input", seeker, true, 8},
+		{"empty input", strings.NewReader(""), true, 0},
+		{"stream", io.MultiReader(strings.NewReader("x")), false, 0},
+		{"UTF-16", strings.NewReader("\xff\xfeh\x00"), false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, start, ok, err := checkpointInput(tt.r)
+			if err != nil || ok != tt.wantOK || start != tt.wantStart {
+				t.Errorf("checkpointInput = %d, %v, %v, want %d, %v", start, ok, err, tt.wantStart, tt.wantOK)
+			}
+		})
+	}
+
+	// The input is read again from where it was
+	if rest, _ := io.ReadAll(seeker); string(rest) != "https://a.com:alice:one\n" {
+		t.Errorf("read %q after the check", rest)
+	}
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHashInput(t *testing.T) {
+	r := strings.NewReader("skipped\nhttps://a.com:alice:one\n")
+	if _, err := r.Seek(8, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := hashInput(r, 8)
+	if err != nil || hash != sha256Hex("https://a.com:alice:one\n") {
+		t.Errorf("hashInput = %s, %v, want the hash from the start position", hash, err)
+	}
+	if rest, _ := io.ReadAll(r); string(rest) != "https://a.com:alice:one\n" {
+		t.Errorf("read %q after hashing, want the input again", rest)
+	}
+}
+
+func TestResumeInput(t *testing.T) {
+	content := "skipped\nhttps://a.com:alice:one\nhttps://b.com:bob:two\n"
+	input := content[8:]
+	// Committed up to the end of the first entry, with the whole input hashed
+	progress := &fileProgress{
+		SHA256:      sha256Hex(input),
+		HashedBytes: int64(len(input)),
+		End:         parser.Position{Offset: 24, Line: 1},
+	}
+
+	open := func() *strings.Reader {
+		r := strings.NewReader(content)
+		if _, err := r.Seek(8, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	r := open()
+	hasher, resumed, err := resumeInput(r, 8, progress)
+	if err != nil || !resumed {
+		t.Fatalf("resumeInput = %v, %v, want a resumed import", resumed, err)
+	}
+	// The rest is read again from the checkpoint without being hashed twice
+	rest, _ := io.ReadAll(io.TeeReader(r, hasher))
+	if string(rest) != input[24:] {
+		t.Errorf("read %q after resuming, want %q", rest, input[24:])
+	}
+	if hasher.sum() != sha256Hex(input) || hasher.size != int64(len(input)) {
+		t.Errorf("hashed %d bytes to %s, want the whole input", hasher.size, hasher.sum())
+	}
+
+	// A checkpoint of other content starts the import over
+	changed := *progress
+	changed.SHA256 = sha256Hex("other content")
+	r = open()
+	hasher, resumed, err = resumeInput(r, 8, &changed)
+	if err != nil || resumed {
+		t.Fatalf("resumeInput with changed content = %v, %v, want no resume", resumed, err)
+	}
+	if rest, _ := io.ReadAll(io.TeeReader(r, hasher)); string(rest) != input || hasher.sum() != sha256Hex(input) {
+		t.Errorf("read %q to %s after starting over, want the whole input", rest, hasher.sum())
+	}
+
+	// So does a checkpoint beyond the end of a truncated file
+	r = strings.NewReader(input[:10])
+	if _, resumed, err := resumeInput(r, 0, progress); err != nil || resumed {
+		t.Errorf("resumeInput with truncated content = %v, %v, want no resume", resumed, err)
+	}
+	if rest, _ := io.ReadAll(r); string(rest) != input[:10] {
+		t.Errorf("read %q after starting over, want %q", rest, input[:10])
+	}
+}
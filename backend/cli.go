@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"hello-world/backend/parser"
+)
+
+// stdinSourceName is the source file recorded for entries piped to the CLI
+const stdinSourceName = "stdin"
+
+// progressInterval is the number of entries between progress lines on stderr
+const progressInterval = 10000
+
+// cliUsage is printed when the command line isn't understood
+const cliUsage = `Usage:
+  app                     start the HTTP server and the log watcher
+  app import [-]          import a log piped to standard input and exit
+  app --stdin             same as import -
+`
+
+// isImportCommand reports whether args, the command line without the program
+// name, ask for an import from standard input
+func isImportCommand(args []string) bool {
+	switch len(args) {
+	case 1:
+		return args[0] == "import" || args[0] == "--stdin"
+	case 2:
+		return args[0] == "import" && args[1] == "-"
+	}
+	return false
+}
+
+// runCommand runs the CLI mode selected by args instead of the server and
+// returns the exit code. Stats are printed to stdout as JSON, logs go to
+// stderr.
+func runCommand(args []string, stdin io.Reader, stdout io.Writer) int {
+	if !isImportCommand(args) {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+
+	// Imports into SINK=file don't use the database
+	if currentConfig().Sink != sinkFile {
+		if err := initDB(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to initialize database: %v\n", err)
+			return 1
+		}
+		defer closeDB()
+	}
+
+	// An interrupt rolls the import back rather than keeping part of it
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	reported := 0
+	ctx = withProgress(ctx, func(entriesSoFar int) {
+		if entriesSoFar-reported >= progressInterval {
+			fmt.Fprintf(os.Stderr, "Processed %d entries so far\n", entriesSoFar)
+			reported = entriesSoFar
+		}
+	})
+
+	if err := importStdin(ctx, stdin, stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Import failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// importStdin imports the log read from stdin, with "stdin" as the source
+// file of its entries, and writes the stats to stdout as JSON
+func importStdin(ctx context.Context, stdin io.Reader, stdout io.Writer) error {
+	stats, err := processReader(ctx, stdin, stdinSourceName, parser.Options{}, nil)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(stats)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestIsImportCommand(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"import"}, true},
+		{[]string{"import", "-"}, true},
+		{[]string{"--stdin"}, true},
+		{[]string{"import", "dump.txt"}, false},
+		{[]string{"serve"}, false},
+		{[]string{"--stdin", "-"}, false},
+	}
+	for _, tt := range tests {
+		if got := isImportCommand(tt.args); got != tt.want {
+			t.Errorf("isImportCommand(%q) = %t, want %t", tt.args, got, tt.want)
+		}
+	}
+
+	if code := runCommand([]string{"serve"}, nil, io.Discard); code != 2 {
+		t.Errorf("unknown command exited with %d, want 2", code)
+	}
+}
+
+func TestImportStdin(t *testing.T) {
+	setupTestDB(t)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go func() {
+		io.WriteString(w, "https://a.com:alice:one\nhttps://b.com:bob:two\nincomplete\n")
+		w.Close()
+	}()
+
+	var out bytes.Buffer
+	if err := importStdin(context.Background(), r, &out); err != nil {
+		t.Fatalf("importStdin failed: %v", err)
+	}
+
+	var stats ParseStats
+	if err := json.Unmarshal(out.Bytes(), &stats); err != nil {
+		t.Fatalf("output %q isn't JSON stats: %v", out.String(), err)
+	}
+	if stats.Inserted != 2 || stats.Skipped() != 1 {
+		t.Errorf("stats = %+v, want 2 inserted and 1 skipped", stats)
+	}
+
+	var source string
+	if err := dbPool.QueryRow(context.Background(), "SELECT DISTINCT source_file FROM entries").Scan(&source); err != nil {
+		t.Fatal(err)
+	}
+	if source != stdinSourceName {
+		t.Errorf("source_file = %q, want %q", source, stdinSourceName)
+	}
+}
+
+func TestRunCommandFileSink(t *testing.T) {
+	// No database is configured, SINK=file doesn't need one
+	path := useFileSink(t, Config{})
+
+	var out bytes.Buffer
+	stdin := strings.NewReader("https://a.com:alice:one\nhttps://b.com:bob:two\n")
+	if code := runCommand([]string{"import", "-"}, stdin, &out); code != 0 {
+		t.Fatalf("import exited with %d", code)
+	}
+	if got := readSinkFile(t, path); len(got) != 2 || *got[0].SourceFile != stdinSourceName {
+		t.Errorf("sink file = %+v, want the 2 entries of stdin", got)
+	}
+}
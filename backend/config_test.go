@@ -0,0 +1,252 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReloadConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "intelxtealer.env")
+	writeConfig := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv("CONFIG_FILE", configPath)
+	t.Setenv("DATABASE_URL", "postgres://localhost/original")
+	t.Setenv("LOG_DIR", "")
+	t.Setenv("BATCH_SIZE", "")
+	t.Setenv("REJECT_VALUES", "")
+	t.Setenv("DB_MAX_CONNS", "")
+
+	writeConfig("BATCH_SIZE=500\n")
+	setConfig(loadConfig())
+	defer setConfig(Config{})
+
+	if isRejectedValue(currentConfig(), "UNKNOWN") {
+		t.Fatal("UNKNOWN rejected before it was configured")
+	}
+
+	writeConfig(`# tuned settings
+BATCH_SIZE=50
+REJECT_VALUES=UNKNOWN, n/a
+DB_MAX_CONNS=50
+DATABASE_URL=postgres://localhost/other
+LOG_DIR=/tmp/elsewhere
+`)
+
+	changed := reloadConfig()
+	if want := []string{"BATCH_SIZE", "REJECT_VALUES"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("reloadConfig changed = %v, want %v", changed, want)
+	}
+
+	cfg := currentConfig()
+	if cfg.BatchSize != 50 {
+		t.Errorf("BatchSize = %d, want 50", cfg.BatchSize)
+	}
+	if !isRejectedValue(cfg, "unknown") || !isRejectedValue(cfg, "N/A") {
+		t.Errorf("reject list %v not applied", cfg.RejectValues)
+	}
+
+	// Settings that require a restart keep their original value
+	if cfg.DatabaseURL != "postgres://localhost/original" {
+		t.Errorf("DatabaseURL = %q, changed without restart", cfg.DatabaseURL)
+	}
+	if cfg.LogDir != defaultLogDir {
+		t.Errorf("LogDir = %q, changed without restart", cfg.LogDir)
+	}
+	if cfg.DBMaxConns != defaultDBMaxConns {
+		t.Errorf("DBMaxConns = %d, changed without restart", cfg.DBMaxConns)
+	}
+}
+
+func TestReloadConfigRawLineWithHashedPasswords(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "intelxtealer.env")
+	t.Setenv("CONFIG_FILE", configPath)
+	t.Setenv("HASH_PASSWORDS", "")
+	t.Setenv("RAW_LINE", "")
+
+	if err := os.WriteFile(configPath, []byte("HASH_PASSWORDS=true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setConfig(loadConfig())
+	defer setConfig(Config{})
+
+	// Turning hashing off needs a restart, so raw lines stay off meanwhile
+	if err := os.WriteFile(configPath, []byte("RAW_LINE=true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reloadConfig()
+	if cfg := currentConfig(); !cfg.HashPasswords || cfg.RawLine {
+		t.Errorf("HashPasswords = %t, RawLine = %t, want hashing without raw lines", cfg.HashPasswords, cfg.RawLine)
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("LOG_DIR", "")
+	t.Setenv("BATCH_SIZE", "not-a-number")
+	t.Setenv("REJECT_VALUES", "")
+	t.Setenv("WATCHER_POLL_INTERVAL", "0s")
+	t.Setenv("WATCHER_STABLE_CHECKS", "")
+	t.Setenv("DB_MAX_CONNS", "")
+	t.Setenv("DB_MIN_CONNS", "")
+	t.Setenv("DB_MAX_CONN_LIFETIME", "forever")
+	t.Setenv("DB_MAX_CONN_IDLE_TIME", "")
+	t.Setenv("DB_MIGRATE", "")
+	t.Setenv("DB_SEED", "")
+	t.Setenv("CREATED_SOURCE", "yesterday")
+	t.Setenv("SEARCH_TIMEOUT", "-1s")
+	t.Setenv("MAX_UPLOAD_SIZE", "0")
+	t.Setenv("MAX_CONCURRENT_FILES", "0")
+	t.Setenv("MAX_PASSWORD_LEN", "-1")
+	t.Setenv("SINK", "kafka")
+	t.Setenv("SINK_FILE", "")
+	t.Setenv("DEDUPE_SCOPE", "")
+	t.Setenv("DEDUPE_ENTRIES", "")
+
+	cfg := loadConfig()
+	if cfg.DatabaseURL != defaultDatabaseURL {
+		t.Errorf("DatabaseURL = %q, want %q", cfg.DatabaseURL, defaultDatabaseURL)
+	}
+	if cfg.LogDir != defaultLogDir {
+		t.Errorf("LogDir = %q, want %q", cfg.LogDir, defaultLogDir)
+	}
+	if cfg.BatchSize != defaultBatchSize {
+		t.Errorf("BatchSize = %d, want %d", cfg.BatchSize, defaultBatchSize)
+	}
+	if len(cfg.RejectValues) != 0 {
+		t.Errorf("RejectValues = %v, want none", cfg.RejectValues)
+	}
+	if cfg.CreatedSource != createdSourceImportTime {
+		t.Errorf("CreatedSource = %q, want %q", cfg.CreatedSource, createdSourceImportTime)
+	}
+	if cfg.Sink != sinkPostgres || cfg.SinkFile != defaultSinkFile {
+		t.Errorf("sink = %s, want %s", describeSink(cfg), sinkPostgres)
+	}
+	if cfg.DedupeScope != dedupeGlobal {
+		t.Errorf("DedupeScope = %q, want %q", cfg.DedupeScope, dedupeGlobal)
+	}
+	if cfg.SearchTimeout != defaultSearchTimeout {
+		t.Errorf("SearchTimeout = %s, want %s", cfg.SearchTimeout, defaultSearchTimeout)
+	}
+	if cfg.MaxUsernameLen != defaultMaxFieldLen || cfg.MaxPasswordLen != defaultMaxFieldLen {
+		t.Errorf("field limits = %d/%d, want %d", cfg.MaxUsernameLen, cfg.MaxPasswordLen, defaultMaxFieldLen)
+	}
+	if cfg.MaxUploadSize != defaultMaxUploadSize {
+		t.Errorf("MaxUploadSize = %d, want %d", cfg.MaxUploadSize, defaultMaxUploadSize)
+	}
+	if cfg.MaxConcurrentFiles != defaultMaxConcurrentFiles {
+		t.Errorf("MaxConcurrentFiles = %d, want %d", cfg.MaxConcurrentFiles, defaultMaxConcurrentFiles)
+	}
+	if cfg.WatcherPollInterval != defaultWatcherPollInterval || cfg.WatcherStableChecks != defaultWatcherStableChecks {
+		t.Errorf("watcher stability = %d polls every %s, want %d every %s",
+			cfg.WatcherStableChecks, cfg.WatcherPollInterval, defaultWatcherStableChecks, defaultWatcherPollInterval)
+	}
+	if cfg.DBMaxConns != defaultDBMaxConns || cfg.DBMinConns != defaultDBMinConns {
+		t.Errorf("pool size = %d-%d, want %d-%d", cfg.DBMinConns, cfg.DBMaxConns, defaultDBMinConns, defaultDBMaxConns)
+	}
+	if cfg.DBMaxConnLifetime != defaultDBMaxConnLifetime || cfg.DBMaxConnIdleTime != defaultDBMaxConnIdleTime {
+		t.Errorf("pool lifetimes = %s/%s, want defaults", cfg.DBMaxConnLifetime, cfg.DBMaxConnIdleTime)
+	}
+	if !cfg.DBMigrate || cfg.DBSeed {
+		t.Errorf("DBMigrate = %t, DBSeed = %t, want migrations without seeding", cfg.DBMigrate, cfg.DBSeed)
+	}
+}
+
+func TestLoadConfigWatcherSettings(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("WATCHER_POLL_INTERVAL", "1s")
+	t.Setenv("WATCHER_STABLE_CHECKS", "5")
+
+	cfg := loadConfig()
+	if cfg.WatcherPollInterval != time.Second || cfg.WatcherStableChecks != 5 {
+		t.Errorf("watcher settings = %d polls every %s, want 5 polls every 1s",
+			cfg.WatcherStableChecks, cfg.WatcherPollInterval)
+	}
+
+	// A newly created watcher waits for the configured number of polls
+	setConfig(cfg)
+	defer setConfig(Config{})
+	w, err := newLogWatcher(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	if w.pollInterval != time.Second || w.stableChecks != 5 {
+		t.Errorf("watcher polls %d times every %s, want 5 times every 1s", w.stableChecks, w.pollInterval)
+	}
+}
+
+func TestLoadConfigPoolSettings(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DB_MAX_CONNS", "40")
+	t.Setenv("DB_MIN_CONNS", "5")
+	t.Setenv("DB_MAX_CONN_LIFETIME", "2h")
+	t.Setenv("DB_MAX_CONN_IDLE_TIME", "5m")
+	t.Setenv("MAX_CONCURRENT_FILES", "4")
+
+	cfg := loadConfig()
+	// Files processed at once don't depend on the pool size
+	if cfg.MaxConcurrentFiles != 4 {
+		t.Errorf("MaxConcurrentFiles = %d, want 4", cfg.MaxConcurrentFiles)
+	}
+	if cfg.DBMaxConns != 40 || cfg.DBMinConns != 5 {
+		t.Errorf("pool size = %d-%d, want 5-40", cfg.DBMinConns, cfg.DBMaxConns)
+	}
+	if cfg.DBMaxConnLifetime != 2*time.Hour || cfg.DBMaxConnIdleTime != 5*time.Minute {
+		t.Errorf("pool lifetimes = %s/%s, want 2h/5m", cfg.DBMaxConnLifetime, cfg.DBMaxConnIdleTime)
+	}
+
+	// A minimum above the maximum falls back to the default minimum
+	t.Setenv("DB_MIN_CONNS", "50")
+	if cfg := loadConfig(); cfg.DBMinConns != defaultDBMinConns {
+		t.Errorf("DBMinConns = %d, want %d", cfg.DBMinConns, defaultDBMinConns)
+	}
+}
+
+func TestLoadConfigDedupeScope(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	tests := []struct {
+		scope, entries, want string
+		isDefault            bool
+	}{
+		{"", "", dedupeGlobal, true},
+		{"", "true", dedupeGlobal, false},
+		{"", "false", dedupeNone, false},
+		{"PER_FILE", "false", dedupePerFile, false},
+		{"none", "true", dedupeNone, false},
+		{"global", "", dedupeGlobal, false},
+		{"everything", "", dedupeGlobal, false},
+	}
+	for _, tt := range tests {
+		t.Setenv("DEDUPE_SCOPE", tt.scope)
+		t.Setenv("DEDUPE_ENTRIES", tt.entries)
+		cfg := loadConfig()
+		if cfg.DedupeScope != tt.want || cfg.DedupeScopeDefault != tt.isDefault {
+			t.Errorf("DEDUPE_SCOPE=%q DEDUPE_ENTRIES=%q: DedupeScope = %q, default %v, want %q, default %v",
+				tt.scope, tt.entries, cfg.DedupeScope, cfg.DedupeScopeDefault, tt.want, tt.isDefault)
+		}
+	}
+}
+
+func TestLoadConfigSkipLineRegex(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+
+	t.Setenv("SKIP_LINE_REGEX", `^#`)
+	if re := loadConfig().SkipLineRegex; re == nil || !re.MatchString("# comment") {
+		t.Errorf("SkipLineRegex = %v, want ^#", re)
+	}
+
+	// An invalid pattern skips nothing rather than failing the startup
+	t.Setenv("SKIP_LINE_REGEX", `(`)
+	if re := loadConfig().SkipLineRegex; re != nil {
+		t.Errorf("SkipLineRegex = %v, want nil for an invalid pattern", re)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// backfillBatch is the number of rows updated per query when the columns
+// computed at import are filled in for older entries
+const backfillBatch = 10000
+
+// contentID returns the stable identifier of an entry: the hex SHA-256 of its
+// identity, the URL and username ignoring case and the exact password.
+// Duplicates are found by it, see entryIdentity. Unlike id it stays the same
+// when the entry is imported again.
+func contentID(url, username, password string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(url) + "\x00" + strings.ToLower(username) + "\x00" + password))
+	return hex.EncodeToString(sum[:])
+}
+
+// backfillEntryColumns sets the content ID and kind of entries stored before
+// those columns existed. They're computed in Go so they match new imports.
+func backfillEntryColumns(ctx context.Context) error {
+	total := 0
+	for {
+		rows, err := dbPool.Query(ctx, "SELECT id, url, username, password FROM entries WHERE content_id IS NULL OR kind = '' LIMIT $1", backfillBatch)
+		if err != nil {
+			return fmt.Errorf("failed to query entries to backfill: %w", err)
+		}
+		batch := &pgx.Batch{}
+		for rows.Next() {
+			var id int
+			var url, username, password string
+			if err := rows.Scan(&id, &url, &username, &password); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan entry: %w", err)
+			}
+			batch.Queue("UPDATE entries SET content_id = $2, kind = $3 WHERE id = $1", id, contentID(url, username, password), classify(url, username))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to query entries to backfill: %w", err)
+		}
+
+		if batch.Len() == 0 {
+			break
+		}
+		if err := dbPool.SendBatch(ctx, batch).Close(); err != nil {
+			return fmt.Errorf("failed to backfill entries: %w", err)
+		}
+		total += batch.Len()
+	}
+
+	if total > 0 {
+		log.Printf("Backfilled the content ID and kind of %d entries", total)
+	}
+	return nil
+}
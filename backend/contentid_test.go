@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"hello-world/backend/parser"
+)
+
+func TestContentID(t *testing.T) {
+	id := contentID("https://a.com/login", "alice", "Secret")
+	if len(id) != 64 {
+		t.Fatalf("contentID = %q, want a hex SHA-256", id)
+	}
+
+	if other := contentID("HTTPS://A.com/login", "ALICE", "Secret"); other != id {
+		t.Errorf("URL and username case changed the content ID: %s != %s", other, id)
+	}
+	for _, other := range [][3]string{
+		{"https://a.com/login", "alice", "secret"},
+		{"https://a.com/logi", "nalice", "Secret"},
+		{"https://b.com/login", "alice", "Secret"},
+	} {
+		if contentID(other[0], other[1], other[2]) == id {
+			t.Errorf("contentID%q matches the content ID of another entry", other)
+		}
+	}
+}
+
+func TestContentIDStableAcrossImports(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+
+	contentIDs := func() []string {
+		t.Helper()
+		rows, err := dbPool.Query(ctx, "SELECT content_id FROM entries ORDER BY id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+
+		var ids []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+
+	// Importing the same lines again yields the same content IDs, not new ones
+	content := "https://a.com:alice:one\nhttps://b.com:bob:two\n"
+	var runs [][]string
+	for i := 0; i < 2; i++ {
+		if _, err := dbPool.Exec(ctx, "TRUNCATE entries"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := processReader(ctx, strings.NewReader(content), "stable.txt", parser.Options{}, nil); err != nil {
+			t.Fatalf("processReader failed: %v", err)
+		}
+		runs = append(runs, contentIDs())
+	}
+	want := []string{contentID("https://a.com", "alice", "one"), contentID("https://b.com", "bob", "two")}
+	for i, ids := range runs {
+		if !reflect.DeepEqual(ids, want) {
+			t.Errorf("run %d: content IDs = %v, want %v", i+1, ids, want)
+		}
+	}
+
+	// Entries stored before the column existed are backfilled the same way
+	if _, err := dbPool.Exec(ctx, "UPDATE entries SET content_id = NULL"); err != nil {
+		t.Fatal(err)
+	}
+	if err := backfillEntryColumns(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ids := contentIDs(); !reflect.DeepEqual(ids, want) {
+		t.Errorf("backfilled content IDs = %v, want %v", ids, want)
+	}
+}
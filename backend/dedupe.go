@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Values of DEDUPE_SCOPE
+const (
+	dedupeGlobal  = "global"
+	dedupePerFile = "per_file"
+	dedupeNone    = "none"
+)
+
+// dedupeIndexes are the unique entries indexes of each DEDUPE_SCOPE. Entries
+// pushed through the API have no source file and count as one file of their
+// own. Only live entries are indexed, so a soft-deleted credential that is
+// imported again comes back as a new entry. The per_file index also holds the
+// file name, which makes it larger than the global one, and stores a row per
+// file a credential appears in so each keeps its provenance.
+var dedupeIndexes = map[string]struct{ name, columns string }{
+	dedupeGlobal:  {"idx_entries_content_id_live_unique", "(content_id)"},
+	dedupePerFile: {"idx_entries_source_content_live_unique", "((COALESCE(source_file, '')), content_id)"},
+}
+
+// previousDedupeIndexes are unique entries indexes of earlier versions, which
+// also covered soft-deleted entries
+var previousDedupeIndexes = []string{
+	"idx_entries_identity",
+	"idx_entries_content_id_unique",
+	"idx_entries_source_content_unique",
+}
+
+// dedupeConflict returns the ON CONFLICT clause that drops entries the unique
+// index of scope already holds, or "" when duplicates are kept
+func dedupeConflict(scope string) string {
+	index, ok := dedupeIndexes[scope]
+	if !ok {
+		return ""
+	}
+	return " ON CONFLICT " + index.columns + " WHERE " + liveEntries + " DO NOTHING"
+}
+
+// errDuplicateEntries is returned when the unique index of a DEDUPE_SCOPE
+// can't be built over the duplicates entries already holds
+var errDuplicateEntries = errors.New("entries hold duplicates within DEDUPE_SCOPE, remove them with /api/duplicates?remove=true first")
+
+// dedupeIndexLockID is the advisory lock held while the unique entries
+// indexes are changed, so instances starting at once don't build or drop
+// them under each other
+const dedupeIndexLockID = 7226592
+
+// createEntryIdentityIndex adds the unique index used by DEDUPE_SCOPE and
+// drops the ones of the other scopes, so imports under the none scope keep
+// every duplicate. Creating an index fails while the table still holds
+// live duplicates within its scope. The indexes of earlier versions are
+// dropped too.
+//
+// Indexes are built and dropped concurrently so imports and API writes go on
+// meanwhile, and nothing is done when they're already as the scope needs, so
+// only the first start after a scope change pays for building the index
+// over the whole table.
+func createEntryIdentityIndex(ctx context.Context, scope string) error {
+	// A session lock on a connection of its own, since the concurrent
+	// builds can't run inside the transaction an xact lock needs
+	conn, err := dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire database connection: %w", err)
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", dedupeIndexLockID); err != nil {
+		return fmt.Errorf("failed to lock the unique entries indexes: %w", err)
+	}
+	defer func() {
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", dedupeIndexLockID); err != nil {
+			log.Printf("Warning: Failed to unlock the unique entries indexes: %v", err)
+		}
+	}()
+
+	if index, ok := dedupeIndexes[scope]; ok {
+		exists, valid, err := indexState(ctx, index.name)
+		if err != nil {
+			return fmt.Errorf("failed to look up the unique entries index: %w", err)
+		}
+		if exists && !valid {
+			// No other instance builds it while the lock is held, so this
+			// is left by a build that failed, and would still slow down
+			// writes
+			if err := dropIndex(ctx, index.name); err != nil {
+				return fmt.Errorf("failed to drop the invalid unique entries index: %w", err)
+			}
+		}
+		if !valid {
+			log.Printf("Building the unique entries index of DEDUPE_SCOPE=%s", scope)
+			_, err := dbPool.Exec(ctx, fmt.Sprintf("CREATE UNIQUE INDEX CONCURRENTLY %s ON entries %s WHERE %s", index.name, index.columns, liveEntries))
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "42P07" {
+				// Created meanwhile by an instance that doesn't take the
+				// lock, which is left to finish its build
+				return fmt.Errorf("unique entries index created by another instance meanwhile: %w", err)
+			}
+			if err != nil {
+				if dropErr := dropIndex(ctx, index.name); dropErr != nil {
+					log.Printf("Warning: Failed to drop the invalid unique entries index: %v", dropErr)
+				}
+				if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+					return fmt.Errorf("failed to create unique entries index: %w: %w", errDuplicateEntries, err)
+				}
+				return fmt.Errorf("failed to create unique entries index: %w", err)
+			}
+		}
+	}
+
+	for other, index := range dedupeIndexes {
+		if other == scope {
+			continue
+		}
+		if err := dropIndex(ctx, index.name); err != nil {
+			return fmt.Errorf("failed to drop the %s unique entries index: %w", other, err)
+		}
+	}
+
+	for _, name := range previousDedupeIndexes {
+		if err := dropIndex(ctx, name); err != nil {
+			return fmt.Errorf("failed to drop the previous unique entries index %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// indexState reports whether the index name exists and whether it's valid,
+// that is its build completed
+func indexState(ctx context.Context, name string) (exists, valid bool, err error) {
+	err = dbPool.QueryRow(ctx, "SELECT indisvalid FROM pg_index WHERE indexrelid = to_regclass($1::text)", name).Scan(&valid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, false, nil
+	}
+	return err == nil, valid, err
+}
+
+// dropIndex drops the index name if it exists, concurrently so writes to
+// entries aren't blocked meanwhile
+func dropIndex(ctx context.Context, name string) error {
+	_, err := dbPool.Exec(ctx, "DROP INDEX CONCURRENTLY IF EXISTS "+name)
+	return err
+}
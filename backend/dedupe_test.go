@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"hello-world/backend/parser"
+)
+
+// useDedupeScope switches DEDUPE_SCOPE and its unique index for one test.
+// Other tests insert duplicates on purpose, so the index is dropped again
+// afterwards.
+func useDedupeScope(t *testing.T, scope string) {
+	t.Helper()
+
+	c := currentConfig()
+	c.DedupeScope = scope
+	setConfig(c)
+	if err := createEntryIdentityIndex(context.Background(), scope); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := createEntryIdentityIndex(context.Background(), dedupeNone); err != nil {
+			t.Errorf("failed to drop index: %v", err)
+		}
+	})
+}
+
+func TestDedupeConflict(t *testing.T) {
+	tests := map[string]string{
+		dedupeGlobal:  " ON CONFLICT (content_id) WHERE deleted_at IS NULL DO NOTHING",
+		dedupePerFile: " ON CONFLICT ((COALESCE(source_file, '')), content_id) WHERE deleted_at IS NULL DO NOTHING",
+		dedupeNone:    "",
+	}
+	for scope, want := range tests {
+		if got := dedupeConflict(scope); got != want {
+			t.Errorf("dedupeConflict(%s) = %q, want %q", scope, got, want)
+		}
+	}
+}
+
+func TestDedupeScopes(t *testing.T) {
+	// Both files repeat a.com, the first one twice
+	first := "https://a.com:alice:one\nhttps://A.com:ALICE:one\nhttps://b.com:bob:two\n"
+	second := "https://a.com:alice:one\nhttps://c.com:carol:three\n"
+
+	tests := []struct {
+		scope                    string
+		firstInserted, secondDup int
+		total                    int
+	}{
+		{dedupeGlobal, 2, 1, 3},
+		{dedupePerFile, 2, 0, 4},
+		{dedupeNone, 3, 0, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			setupTestDB(t)
+			useDedupeScope(t, tt.scope)
+			// Only the unique index drops repeats here
+			c := currentConfig()
+			c.DedupeCacheSize = 0
+			setConfig(c)
+
+			logDir := t.TempDir()
+			importFile := func(name, content string) ParseStats {
+				t.Helper()
+				path := filepath.Join(logDir, name)
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+				stats, err := processLogFile(context.Background(), path, parser.Options{}, nil)
+				if err != nil {
+					t.Fatalf("processLogFile(%s) failed: %v", name, err)
+				}
+				return stats
+			}
+
+			if stats := importFile("first.txt", first); stats.Inserted != tt.firstInserted {
+				t.Errorf("first.txt inserted %d, want %d", stats.Inserted, tt.firstInserted)
+			}
+			if stats := importFile("second.txt", second); stats.SkippedDuplicate != tt.secondDup {
+				t.Errorf("second.txt skipped %d duplicates, want %d", stats.SkippedDuplicate, tt.secondDup)
+			}
+			if got := countEntries(t); got != tt.total {
+				t.Errorf("entries = %d, want %d", got, tt.total)
+			}
+		})
+	}
+}
+
+func TestCreateEntryIdentityIndexWithDuplicates(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://a.com", User: "alice", Pass: "one"},
+		Entry{URL: "https://a.com", User: "alice", Pass: "one"},
+	)
+	t.Cleanup(func() {
+		if err := createEntryIdentityIndex(context.Background(), dedupeNone); err != nil {
+			t.Errorf("failed to drop index: %v", err)
+		}
+	})
+
+	// The failed build doesn't leave an invalid index behind
+	ctx := context.Background()
+	if err := createEntryIdentityIndex(ctx, dedupeGlobal); !errors.Is(err, errDuplicateEntries) {
+		t.Fatalf("createEntryIdentityIndex = %v, want %v", err, errDuplicateEntries)
+	}
+	name := dedupeIndexes[dedupeGlobal].name
+	if exists, _, err := indexState(ctx, name); err != nil || exists {
+		t.Fatalf("index after the failed build: exists = %v, err = %v", exists, err)
+	}
+
+	// Once the duplicates are removed the next start builds it
+	getJSON(t, "/api/duplicates?remove=true", new(map[string]any))
+	if err := createEntryIdentityIndex(ctx, dedupeGlobal); err != nil {
+		t.Fatal(err)
+	}
+	if exists, valid, err := indexState(ctx, name); err != nil || !exists || !valid {
+		t.Errorf("index after removing the duplicates: exists = %v, valid = %v, err = %v", exists, valid, err)
+	}
+}
+
+func TestInitDedupeScopeWithDuplicates(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://a.com", User: "alice", Pass: "one"},
+		Entry{URL: "https://a.com", User: "alice", Pass: "one"},
+	)
+	t.Cleanup(func() {
+		if err := createEntryIdentityIndex(context.Background(), dedupeNone); err != nil {
+			t.Errorf("failed to drop index: %v", err)
+		}
+	})
+	ctx := context.Background()
+
+	// DEDUPE_SCOPE=global refuses to start
+	cfg := currentConfig()
+	cfg.DedupeScope = dedupeGlobal
+	setConfig(cfg)
+	if err := initDedupeScope(ctx, cfg); !errors.Is(err, errDuplicateEntries) {
+		t.Fatalf("initDedupeScope with DEDUPE_SCOPE set = %v, want %v", err, errDuplicateEntries)
+	}
+
+	// The default runs with none instead
+	cfg.DedupeScopeDefault = true
+	setConfig(cfg)
+	if err := initDedupeScope(ctx, cfg); err != nil {
+		t.Fatalf("initDedupeScope with the default scope failed: %v", err)
+	}
+	if got := currentConfig().DedupeScope; got != dedupeNone {
+		t.Errorf("DedupeScope = %q, want %q", got, dedupeNone)
+	}
+	if exists, _, err := indexState(ctx, dedupeIndexes[dedupeGlobal].name); err != nil || exists {
+		t.Errorf("global index after falling back: exists = %v, err = %v", exists, err)
+	}
+}
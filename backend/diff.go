@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// errRunNotFound is returned by diffRuns for an import run that doesn't exist
+var errRunNotFound = errors.New("import run not found")
+
+// diffRunsWhere selects the entries of run $1 whose content_id isn't among
+// the entries of run $2
+const diffRunsWhere = `
+	FROM entries
+	WHERE run_id = $1 AND ` + liveEntries + ` AND NOT EXISTS (
+		SELECT 1 FROM entries AS earlier
+		WHERE earlier.run_id = $2 AND earlier.content_id = entries.content_id AND earlier.deleted_at IS NULL
+	)`
+
+// diffRuns returns one page of the entries imported by runB that runA didn't
+// import, compared by content_id, and the number of such entries
+func diffRuns(ctx context.Context, runA, runB, pageSize, offset int) ([]Entry, int, error) {
+	var found int
+	err := dbPool.QueryRow(ctx, "SELECT COUNT(*) FROM import_runs WHERE id IN ($1, $2)", runA, runB).Scan(&found)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to look up import runs: %w", err)
+	}
+	want := 2
+	if runA == runB {
+		want = 1
+	}
+	if found != want {
+		return nil, 0, errRunNotFound
+	}
+
+	var total int
+	if err := dbPool.QueryRow(ctx, "SELECT COUNT(*)"+diffRunsWhere, runB, runA).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count new entries: %w", err)
+	}
+
+	rows, err := dbPool.Query(ctx, "SELECT "+entryColumns+diffRunsWhere+" ORDER BY id LIMIT $3 OFFSET $4", runB, runA, pageSize, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query new entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var entry Entry
+		if err := rows.Scan(entry.scanFields()...); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to query new entries: %w", err)
+	}
+	return entries, total, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"hello-world/backend/parser"
+)
+
+func TestDiffRuns(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+
+	importRun := func(content string) int {
+		t.Helper()
+		runID, err := startImportRun(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := fmt.Sprintf("run%d.txt", *runID)
+		if _, err := processReader(ctx, strings.NewReader(content), name, parser.Options{}, runID); err != nil {
+			t.Fatalf("processReader failed: %v", err)
+		}
+		return *runID
+	}
+
+	// The second dump repeats alice, bob in another case and adds two entries
+	runA := importRun("https://a.com:alice:one\nhttps://b.com:bob:two\nhttps://c.com:carol:three\n")
+	runB := importRun("https://a.com:alice:one\nhttps://B.com:BOB:two\nhttps://d.com:dave:four\nhttps://a.com:alice:changed\n")
+
+	var result PaginationResponse
+	if status := getJSON(t, fmt.Sprintf("/api/diff?runA=%d&runB=%d", runA, runB), &result); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	var got []string
+	for _, e := range result.Items {
+		got = append(got, e.User+":"+e.Pass)
+	}
+	if want := "dave:four alice:changed"; strings.Join(got, " ") != want || result.Total != 2 {
+		t.Errorf("new entries = %v (total %d), want %s", got, result.Total, want)
+	}
+
+	// Pages follow the import order
+	if status := getJSON(t, fmt.Sprintf("/api/diff?runA=%d&runB=%d&page=2&pageSize=1", runA, runB), &result); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if len(result.Items) != 1 || result.Items[0].Pass != "changed" || result.HasNext {
+		t.Errorf("second page = %+v, want alice's changed password", result.Items)
+	}
+
+	// Nothing of the first run is new compared to itself
+	if status := getJSON(t, fmt.Sprintf("/api/diff?runA=%d&runB=%d", runA, runA), &result); status != 200 || result.Total != 0 {
+		t.Errorf("status = %d, total = %d, want an empty diff", status, result.Total)
+	}
+
+	var errResult map[string]any
+	if status := getJSON(t, fmt.Sprintf("/api/diff?runA=%d&runB=%d", runA, runB+100), &errResult); status != 404 {
+		t.Errorf("unknown run: status = %d, want 404", status)
+	}
+}
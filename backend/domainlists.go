@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"hello-world/backend/parser"
+)
+
+// loadDomainList returns the domains of a comma separated setting plus those
+// listed one per line in the file at path, if set. Domains are normalized
+// like the domain column, so "https://www.Example.com/" lists example.com.
+func loadDomainList(name, value, path string) []string {
+	values := parseListSetting(value)
+	if path != "" {
+		fromFile, err := readDomainFile(path)
+		if err != nil {
+			log.Printf("Warning: Failed to read %s_FILE %s: %v", name, path, err)
+		}
+		values = append(values, fromFile...)
+	}
+
+	var domains []string
+	for _, v := range values {
+		if d := parser.ExtractDomain(v); d != "" && !slices.Contains(domains, d) {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// readDomainFile reads one domain per line, ignoring blanks and # comments
+func readDomainFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var domains []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			domains = append(domains, line)
+		}
+	}
+	return domains, scanner.Err()
+}
+
+// domainListed reports whether domain or one of its parent domains is in list
+func domainListed(list []string, domain string) bool {
+	for _, d := range list {
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// domainAllowed reports whether entries of domain are imported: it's not on
+// DOMAIN_DENYLIST and, when DOMAIN_ALLOWLIST is set, it's on the allowlist.
+// Entries without a URL have no domain and only pass without an allowlist.
+func domainAllowed(c Config, domain string) bool {
+	if domainListed(c.DomainDenylist, domain) {
+		return false
+	}
+	return len(c.DomainAllowlist) == 0 || domainListed(c.DomainAllowlist, domain)
+}
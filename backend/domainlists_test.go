@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"hello-world/backend/parser"
+)
+
+func TestScanEntriesDomainLists(t *testing.T) {
+	input := strings.Join([]string{
+		"https://target.com/login:alice:one",
+		"https://mail.target.com/login:bob:two",
+		"https://noise.com/login:carol:three",
+		"https://ads.noise.com:dave:four",
+		"https://other.org:erin:five",
+	}, "\n")
+
+	tests := []struct {
+		name        string
+		allow, deny []string
+		want        []string
+	}{
+		{"none", nil, nil, []string{"alice", "bob", "carol", "dave", "erin"}},
+		{"allow only", []string{"target.com"}, nil, []string{"alice", "bob"}},
+		{"deny only", nil, []string{"noise.com"}, []string{"alice", "bob", "erin"}},
+		{"combined", []string{"target.com", "noise.com"}, []string{"mail.target.com", "ads.noise.com"}, []string{"alice", "carol"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{DomainAllowlist: tt.allow, DomainDenylist: tt.deny}
+
+			var users []string
+			var stats ParseStats
+			err := scanEntries(strings.NewReader(input), cfg, parser.Options{}, &stats, func(e parser.Entry) error {
+				users = append(users, e.Username)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(users, tt.want) {
+				t.Errorf("imported %v, want %v", users, tt.want)
+			}
+			if want := 5 - len(tt.want); stats.SkippedDomain != want || stats.Skipped() != want {
+				t.Errorf("SkippedDomain = %d, Skipped() = %d, want %d", stats.SkippedDomain, stats.Skipped(), want)
+			}
+		})
+	}
+}
+
+func TestLoadDomainList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	if err := os.WriteFile(path, []byte("# targets\nhttps://www.Target.com/\n\nother.org\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := loadDomainList("DOMAIN_ALLOWLIST", "target.com, Example.com", path)
+	if want := []string{"target.com", "example.com", "other.org"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("loadDomainList = %v, want %v", got, want)
+	}
+
+	// A missing file is logged and the setting's own domains still apply
+	got = loadDomainList("DOMAIN_ALLOWLIST", "target.com", filepath.Join(t.TempDir(), "missing.txt"))
+	if want := []string{"target.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("loadDomainList with missing file = %v, want %v", got, want)
+	}
+}
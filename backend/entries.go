@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"hello-world/backend/parser"
+)
+
+// maxBulkEntries is the most entries accepted by one POST /api/entries
+const maxBulkEntries = 10000
+
+// sanitizeField cleans up a value pushed through the API like a line of a log file
+func sanitizeField(cfg Config, value string) string {
+	value = parser.SanitizeString(value)
+	if cfg.SanitizeStripControl {
+		value = parser.StripControl(value)
+	}
+	return strings.TrimSpace(value)
+}
+
+// normalizeEntry sanitizes and normalizes the fields of an entry pushed
+// through the API like those of a log line. The password is left in
+// plaintext for checkEntry.
+func normalizeEntry(cfg Config, url, username, password string) parser.Entry {
+	entry := parser.Entry{
+		URL:      sanitizeField(cfg, url),
+		Username: sanitizeField(cfg, username),
+		Password: sanitizeField(cfg, password),
+	}
+	if cfg.NormalizePasswords {
+		entry.Password = parser.NormalizePassword(entry.Password)
+	}
+	entry.Username = parser.NormalizeUsername(entry.Username, cfg.NormalizeUsername)
+	return entry
+}
+
+// Errors of checkEntry
+var (
+	errEntryRejected = errors.New("the username or password is a placeholder from REJECT_VALUES")
+	errEntryTooLong  = errors.New("the username or password is longer than MAX_USERNAME_LEN or MAX_PASSWORD_LEN")
+)
+
+// checkEntry applies the reject list and the length limits, which skip the
+// same entries of a log, to an entry returned by normalizeEntry. Empty fields
+// pass, so the fields of a PATCH can be checked on their own.
+func checkEntry(cfg Config, entry parser.Entry) error {
+	for _, value := range []string{entry.Username, entry.Password} {
+		if value != "" && isRejectedValue(cfg, value) {
+			return errEntryRejected
+		}
+	}
+	if fieldLimits(cfg).Exceeded(entry.Username, entry.Password) {
+		return errEntryTooLong
+	}
+	return nil
+}
+
+// insertEntries imports entries pushed through the API. They are sanitized
+// and filtered like the lines of a log file, and entries already in the
+// database are skipped.
+func insertEntries(ctx context.Context, entries []Entry) (ParseStats, error) {
+	var stats ParseStats
+
+	cfg := currentConfig()
+	seen := newEntryCache(cfg.DedupeCacheSize, cfg.DedupeScope != dedupeNone)
+	created := time.Now().Format("2006-01-02")
+
+	var batches []*pgx.Batch
+	batch := &pgx.Batch{}
+	queued := 0
+	for _, e := range entries {
+		stats.Total++
+
+		entry := normalizeEntry(cfg, e.URL, e.User, e.Pass)
+		if entry.Username == "" || entry.Password == "" {
+			stats.SkippedShort++
+			continue
+		}
+		switch err := checkEntry(cfg, entry); err {
+		case errEntryRejected:
+			stats.SkippedPlaceholder++
+			continue
+		case errEntryTooLong:
+			stats.SkippedTooLong++
+			continue
+		}
+		if !domainAllowed(cfg, parser.ExtractDomain(entry.URL)) {
+			stats.SkippedDomain++
+			continue
+		}
+		if seen.seen(entry) {
+			stats.SkippedInFileDuplicate++
+			continue
+		}
+		entry.Password = storedPassword(cfg, entry.Password)
+
+		batch.Queue("INSERT INTO entries (url, username, password, created, domain, content_id, kind) VALUES ($1, $2, $3, $4, $5, $6, $7)"+dedupeConflict(cfg.DedupeScope),
+			entry.URL, entry.Username, entry.Password, created, parser.ExtractDomain(entry.URL), contentID(entry.URL, entry.Username, entry.Password),
+			classify(entry.URL, entry.Username))
+		queued++
+		if batch.Len() >= cfg.BatchSize {
+			batches = append(batches, batch)
+			batch = &pgx.Batch{}
+		}
+	}
+	if batch.Len() > 0 {
+		batches = append(batches, batch)
+	}
+
+	// All batches share a transaction so a failed request inserts nothing
+	inserted := 0
+	err := pgx.BeginFunc(ctx, dbPool, func(tx pgx.Tx) error {
+		for _, b := range batches {
+			n, err := sendEntryBatch(ctx, tx, b)
+			if err != nil {
+				return fmt.Errorf("batch execution failed: %w", err)
+			}
+			inserted += n
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	stats.Inserted = inserted
+	stats.SkippedDuplicate = queued - inserted
+	return stats, nil
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func postEntries(t *testing.T, body string) (int, map[string]any) {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", "/api/entries", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := newApp().Test(req, testConfig)
+	if err != nil {
+		t.Fatalf("POST /api/entries failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return resp.StatusCode, result
+}
+
+func TestPostEntries(t *testing.T) {
+	setupTestDB(t)
+
+	c := currentConfig()
+	c.RejectValues = []string{"unknown"}
+	c.DedupeCacheSize = 100
+	setConfig(c)
+
+	insertTestEntries(t, Entry{URL: "https://a.com", User: "alice", Pass: "one"})
+
+	body := `[
+		{"url": "https://a.com", "user": "alice", "pass": "one"},
+		{"url": "https://b.com/login", "user": " bob ", "pass": "two\u0000"},
+		{"url": "https://b.com/login", "user": "bob", "pass": "two"},
+		{"url": "https://c.com", "user": "UNKNOWN", "pass": "three"},
+		{"url": "https://d.com", "user": "dave", "pass": ""},
+		{"user": "erin@mail.com", "pass": "four"}
+	]`
+	status, result := postEntries(t, body)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %v", status, http.StatusOK, result)
+	}
+	// The first entry is skipped by the unique index only with DEDUPE_SCOPE,
+	// repeats within the request are always dropped
+	if result["inserted"] != float64(3) || result["skipped"] != float64(3) {
+		t.Errorf("inserted %v, skipped %v, want 3 and 3", result["inserted"], result["skipped"])
+	}
+
+	var domain string
+	err := dbPool.QueryRow(context.Background(),
+		"SELECT domain FROM entries WHERE username = 'bob' AND password = 'two'").Scan(&domain)
+	if err != nil {
+		t.Fatalf("sanitized entry not found: %v", err)
+	}
+	if domain != "b.com" {
+		t.Errorf("domain = %q, want b.com", domain)
+	}
+	if got := countEntries(t); got != 4 {
+		t.Errorf("entries = %d, want 4", got)
+	}
+}
+
+func TestPostEntriesTooMany(t *testing.T) {
+	entries := make([]Entry, maxBulkEntries+1)
+	for i := range entries {
+		entries[i] = Entry{URL: "https://a.com", User: "alice", Pass: "one"}
+	}
+	body, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, _ := postEntries(t, string(body))
+	if status != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", status, http.StatusRequestEntityTooLarge)
+	}
+
+	status, _ = postEntries(t, `{"url": "https://a.com"}`)
+	if status != http.StatusBadRequest {
+		t.Errorf("status for an object = %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func patchEntry(t *testing.T, id string, body string) (int, Entry) {
+	t.Helper()
+
+	req := httptest.NewRequest("PATCH", "/api/entries/"+id, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := newApp().Test(req, testConfig)
+	if err != nil {
+		t.Fatalf("PATCH /api/entries/%s failed: %v", id, err)
+	}
+	defer resp.Body.Close()
+
+	var entry Entry
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&entry); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+	return resp.StatusCode, entry
+}
+
+func TestPatchEntry(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t, Entry{URL: "https://a.com/login:alice", User: "secret", Pass: "x"})
+
+	// Only the password changes
+	status, entry := patchEntry(t, "1", `{"pass": " secret2 "}`)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	content := contentID("https://a.com/login:alice", "secret", "secret2")
+	want := Entry{ID: 1, URL: "https://a.com/login:alice", User: "secret", Pass: "secret2", Created: "2025-05-20", Domain: "a.com", Tags: []string{},
+		ContentID: &content, Kind: classify("https://a.com/login:alice", "secret")}
+	if !reflect.DeepEqual(entry, want) {
+		t.Errorf("entry = %+v, want %+v", entry, want)
+	}
+
+	// A corrected URL gets its domain derived again
+	status, entry = patchEntry(t, "1", `{"url": "https://www.B.com/login", "user": "alice"}`)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	content = contentID("https://www.B.com/login", "alice", "secret2")
+	want.URL, want.User, want.Domain, want.Kind = "https://www.B.com/login", "alice", "b.com", classify("https://www.B.com/login", "alice")
+	if !reflect.DeepEqual(entry, want) {
+		t.Errorf("entry = %+v, want %+v", entry, want)
+	}
+
+	if status, _ := patchEntry(t, "99", `{"pass": "x"}`); status != http.StatusNotFound {
+		t.Errorf("status for a missing entry = %d, want 404", status)
+	}
+}
+
+func TestPatchEntryNormalizedPassword(t *testing.T) {
+	setupTestDB(t)
+	c := currentConfig()
+	c.NormalizePasswords = true
+	setConfig(c)
+	insertTestEntries(t, Entry{URL: "https://a.com/login", User: "alice", Pass: "x"})
+
+	// Stored like an imported password, without the quotes and trailing space
+	status, entry := patchEntry(t, "1", `{"pass": "'hunter2' "}`)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if entry.Pass != "hunter2" || entry.ContentID == nil || *entry.ContentID != contentID("https://a.com/login", "alice", "hunter2") {
+		t.Errorf("entry = %+v, want the password hunter2", entry)
+	}
+
+	// Nothing is left of a password that was only quotes
+	if status, _ := patchEntry(t, "1", `{"pass": "\"\""}`); status != http.StatusBadRequest {
+		t.Errorf("status for an empty normalized password = %d, want 400", status)
+	}
+}
+
+func TestPatchEntryBadRequest(t *testing.T) {
+	for _, tt := range []struct{ id, body string }{
+		{"1", `{}`},
+		{"1", ``},
+		{"1", `{"user": "   "}`},
+		{"abc", `{"pass": "x"}`},
+	} {
+		if status, _ := patchEntry(t, tt.id, tt.body); status != http.StatusBadRequest {
+			t.Errorf("PATCH %s with %q: status = %d, want 400", tt.id, tt.body, status)
+		}
+	}
+}
+
+func TestPatchEntrySkippedByImportRules(t *testing.T) {
+	setConfig(Config{RejectValues: []string{"n/a"}, MaxUsernameLen: 5, DomainDenylist: []string{"spam.com"}})
+	defer setConfig(Config{})
+
+	// Refused before the database is used, like an import skips them
+	for _, body := range []string{
+		`{"pass": "N/A"}`,
+		`{"user": "alexander"}`,
+		`{"url": "https://spam.com/login"}`,
+	} {
+		if status, _ := patchEntry(t, "1", body); status != http.StatusBadRequest {
+			t.Errorf("PATCH with %s: status = %d, want 400", body, status)
+		}
+	}
+}
+
+func deleteEntry(t *testing.T, path string) int {
+	t.Helper()
+
+	resp, err := newApp().Test(httptest.NewRequest("DELETE", path, nil), testConfig)
+	if err != nil {
+		t.Fatalf("DELETE %s failed: %v", path, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSoftDeleteEntry(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://a.com/login", User: "alice", Pass: "one"},
+		Entry{URL: "https://a.com/login", User: "bob", Pass: "two"},
+	)
+
+	if status := deleteEntry(t, "/api/entries/1"); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if status := deleteEntry(t, "/api/entries/1"); status != http.StatusNotFound {
+		t.Errorf("status for an entry deleted twice = %d, want 404", status)
+	}
+
+	// A soft-deleted entry is hidden from every read but stays in the table
+	for _, path := range []string{"/api/entries", "/api/search?domain=a.com"} {
+		var result PaginationResponse
+		getJSON(t, path, &result)
+		if result.Total != 1 || len(result.Items) != 1 || result.Items[0].User != "bob" {
+			t.Errorf("%s = %+v, want only bob", path, result)
+		}
+	}
+	if status := getJSON(t, "/api/entries/1", new(map[string]any)); status != http.StatusNotFound {
+		t.Errorf("GET status for a deleted entry = %d, want 404", status)
+	}
+	if status, _ := patchEntry(t, "1", `{"pass": "x"}`); status != http.StatusNotFound {
+		t.Errorf("PATCH status for a deleted entry = %d, want 404", status)
+	}
+	if count := countEntries(t); count != 2 {
+		t.Errorf("entries in the table = %d, want 2", count)
+	}
+
+	req := httptest.NewRequest("POST", "/api/entries/1/restore", nil)
+	var restored Entry
+	if status := doJSON(t, req, &restored); status != http.StatusOK {
+		t.Fatalf("restore status = %d, want 200", status)
+	}
+	if restored.ID != 1 || restored.User != "alice" {
+		t.Errorf("restored entry = %+v, want alice", restored)
+	}
+	if status := getJSON(t, "/api/entries/1", new(map[string]any)); status != http.StatusOK {
+		t.Errorf("GET status for a restored entry = %d, want 200", status)
+	}
+	if status := doJSON(t, httptest.NewRequest("POST", "/api/entries/1/restore", nil), new(map[string]any)); status != http.StatusNotFound {
+		t.Errorf("status for restoring a live entry = %d, want 404", status)
+	}
+
+	var audit AuditResponse
+	getAdminJSON(t, "/api/admin/audit", &audit)
+	var actions []string
+	for _, entry := range audit.Entries {
+		actions = append(actions, entry.Action)
+	}
+	if want := []string{auditRestoreEntry, auditSoftDeleteEntry}; !reflect.DeepEqual(actions, want) {
+		t.Errorf("audit actions = %v, want %v", actions, want)
+	}
+}
+
+func TestSoftDeletedEntryImportedAgain(t *testing.T) {
+	setupTestDB(t)
+	useDedupeScope(t, dedupeGlobal)
+
+	if status, _ := postEntries(t, `[{"url": "https://a.com/login", "user": "alice", "pass": "one"}]`); status != http.StatusOK {
+		t.Fatalf("POST status = %d, want 200", status)
+	}
+	if status := deleteEntry(t, "/api/entries/1"); status != http.StatusOK {
+		t.Fatalf("DELETE status = %d, want 200", status)
+	}
+
+	// The deleted entry doesn't hide a new import of the same credential
+	if status, _ := postEntries(t, `[{"url": "https://a.com/login", "user": "alice", "pass": "one"}]`); status != http.StatusOK {
+		t.Fatalf("POST status = %d, want 200", status)
+	}
+	var result PaginationResponse
+	getJSON(t, "/api/entries", &result)
+	if result.Total != 1 || len(result.Items) != 1 || result.Items[0].ID != 2 {
+		t.Errorf("entries = %+v, want the new copy", result)
+	}
+
+	// Restoring the old copy would duplicate the live one
+	if status := doJSON(t, httptest.NewRequest("POST", "/api/entries/1/restore", nil), new(map[string]any)); status != http.StatusConflict {
+		t.Errorf("restore status = %d, want 409", status)
+	}
+}
+
+func TestHardDeleteEntry(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t, Entry{URL: "https://a.com/login", User: "alice", Pass: "one"})
+
+	if status := deleteEntry(t, "/api/entries/1?hard=true"); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if count := countEntries(t); count != 0 {
+		t.Errorf("entries in the table = %d, want 0", count)
+	}
+	if status := deleteEntry(t, "/api/entries/1?hard=true"); status != http.StatusNotFound {
+		t.Errorf("status for a missing entry = %d, want 404", status)
+	}
+
+	var audit AuditResponse
+	getAdminJSON(t, "/api/admin/audit", &audit)
+	if len(audit.Entries) != 1 || audit.Entries[0].Action != auditDeleteEntry {
+		t.Errorf("audit log = %+v, want one %s entry", audit, auditDeleteEntry)
+	}
+
+	// A soft-deleted entry is removed for good too
+	insertTestEntries(t, Entry{URL: "https://b.com/login", User: "bob", Pass: "two"})
+	if status := deleteEntry(t, "/api/entries/2"); status != http.StatusOK {
+		t.Fatalf("soft delete status = %d, want 200", status)
+	}
+	if status := deleteEntry(t, "/api/entries/2?hard=true"); status != http.StatusOK {
+		t.Errorf("hard delete of a soft-deleted entry status = %d, want 200", status)
+	}
+	if count := countEntries(t); count != 0 {
+		t.Errorf("entries in the table = %d, want 0", count)
+	}
+}
+
+func TestDeleteEntryBadID(t *testing.T) {
+	if status := deleteEntry(t, "/api/entries/abc"); status != http.StatusBadRequest {
+		t.Errorf("DELETE status = %d, want 400", status)
+	}
+	if status := doJSON(t, httptest.NewRequest("POST", "/api/entries/abc/restore", nil), new(map[string]any)); status != http.StatusBadRequest {
+		t.Errorf("restore status = %d, want 400", status)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"strings"
+
+	"hello-world/backend/parser"
+)
+
+// entryCache remembers the most recently seen entries of a file so repeated
+// credentials aren't sent to the database again. It holds hashes rather than
+// the credentials and evicts the least recently seen entry once full.
+type entryCache struct {
+	size    int
+	order   *list.List
+	entries map[[sha256.Size]byte]*list.Element
+	// ignoreCase compares URLs and usernames ignoring case, like DEDUPE_SCOPE
+	ignoreCase bool
+}
+
+// newEntryCache returns a cache holding up to size entries, or nil when size
+// is zero so every entry is treated as unseen
+func newEntryCache(size int, ignoreCase bool) *entryCache {
+	if size <= 0 {
+		return nil
+	}
+	return &entryCache{
+		size:       size,
+		order:      list.New(),
+		entries:    make(map[[sha256.Size]byte]*list.Element, min(size, 1<<16)),
+		ignoreCase: ignoreCase,
+	}
+}
+
+// seen reports whether the entry is in the cache and adds it if it isn't
+func (c *entryCache) seen(entry parser.Entry) bool {
+	if c == nil {
+		return false
+	}
+
+	url, username := entry.URL, entry.Username
+	if c.ignoreCase {
+		url, username = strings.ToLower(url), strings.ToLower(username)
+	}
+	key := sha256.Sum256([]byte(url + "\x00" + username + "\x00" + entry.Password))
+
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		return true
+	}
+
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.([sha256.Size]byte))
+	}
+	c.entries[key] = c.order.PushFront(key)
+	return false
+}
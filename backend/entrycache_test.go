@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hello-world/backend/parser"
+)
+
+func TestEntryCache(t *testing.T) {
+	cache := newEntryCache(2, false)
+
+	a := parser.Entry{URL: "https://a.com", Username: "alice", Password: "one"}
+	b := parser.Entry{URL: "https://b.com", Username: "bob", Password: "two"}
+	c := parser.Entry{URL: "https://c.com", Username: "carol", Password: "three"}
+
+	steps := []struct {
+		entry parser.Entry
+		seen  bool
+	}{
+		{a, false},
+		{a, true},
+		{b, false},
+		{a, true},  // a becomes the most recently seen entry
+		{c, false}, // evicts b
+		{a, true},
+		{b, false},
+		{parser.Entry{URL: "https://A.com", Username: "Alice", Password: "one"}, false},
+	}
+	for i, step := range steps {
+		if got := cache.seen(step.entry); got != step.seen {
+			t.Errorf("step %d: seen(%v) = %v, want %v", i, step.entry, got, step.seen)
+		}
+	}
+
+	// Case is ignored for the URL and username, never the password
+	folded := newEntryCache(10, true)
+	folded.seen(a)
+	if !folded.seen(parser.Entry{URL: "https://A.com", Username: "ALICE", Password: "one"}) {
+		t.Error("expected a case variant of the URL and username to be seen")
+	}
+	if folded.seen(parser.Entry{URL: "https://a.com", Username: "alice", Password: "ONE"}) {
+		t.Error("expected a different password to be unseen")
+	}
+
+	// A disabled cache never reports an entry as seen
+	disabled := newEntryCache(0, false)
+	disabled.seen(a)
+	if disabled.seen(a) {
+		t.Error("expected a disabled cache to report entries as unseen")
+	}
+}
+
+func TestProcessLogFileSkipsRepeatedEntries(t *testing.T) {
+	setupTestDB(t)
+
+	c := currentConfig()
+	c.DedupeCacheSize = defaultDedupeCacheSize
+	setConfig(c)
+
+	content := strings.Repeat("https://a.com:user:pass\nhttps://b.com:user:pass\n", 5)
+	filePath := filepath.Join(t.TempDir(), "repeated.txt")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := processLogFile(context.Background(), filePath, parser.Options{}, nil)
+	if err != nil {
+		t.Fatalf("processLogFile failed: %v", err)
+	}
+	if stats.Inserted != 2 || stats.SkippedInFileDuplicate != 8 {
+		t.Errorf("inserted %d, skipped %d repeats, want 2 and 8", stats.Inserted, stats.SkippedInFileDuplicate)
+	}
+	if got := countEntries(t); got != 2 {
+		t.Errorf("entries = %d, want 2", got)
+	}
+}
+
+// BenchmarkEntryCacheBatches parses a file where every credential appears
+// ten times and reports how many insert batches would be sent
+func BenchmarkEntryCacheBatches(b *testing.B) {
+	var sb strings.Builder
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(&sb, "https://site%d.com:user%d:pass\n", i%1000, i%1000)
+	}
+	content := sb.String()
+	cfg := Config{BatchSize: defaultBatchSize}
+
+	for _, size := range []int{0, defaultDedupeCacheSize} {
+		b.Run(fmt.Sprintf("cache=%d", size), func(b *testing.B) {
+			var batches int
+			for i := 0; i < b.N; i++ {
+				seen := newEntryCache(size, false)
+				queued := 0
+				var stats ParseStats
+				err := scanEntries(strings.NewReader(content), cfg, parser.Options{}, &stats, func(entry parser.Entry) error {
+					if !seen.seen(entry) {
+						queued++
+					}
+					return nil
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+				batches = (queued + cfg.BatchSize - 1) / cfg.BatchSize
+			}
+			b.ReportMetric(float64(batches), "batches/file")
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SearchPlan is the plan PostgreSQL chose for a query of a search
+type SearchPlan struct {
+	SQL  string          `json:"sql"`
+	Plan json.RawMessage `json:"plan"`
+}
+
+// explainSearch runs EXPLAIN ANALYZE on the page and count queries /search
+// would run for f, built by the same buildSearchQuery. The queries really
+// execute, so they're limited by SEARCH_TIMEOUT like a search.
+func explainSearch(ctx context.Context, f searchFilter) (page, count SearchPlan, err error) {
+	f.hashedPasswords = currentConfig().HashPasswords
+	q, err := buildSearchQuery(f)
+	if err != nil {
+		return page, count, err
+	}
+
+	_, pageSize, offset := normalizePagination(f.Page, f.PageSize)
+	page.SQL = searchPageSQL(q)
+	count.SQL = searchCountSQL(q)
+
+	err = withSearchTimeout(ctx, currentConfig().SearchTimeout, func(tx pgx.Tx) error {
+		var err error
+		if page.Plan, err = explainQuery(ctx, tx, page.SQL, append(q.params, pageSize, offset)...); err != nil {
+			return err
+		}
+		count.Plan, err = explainQuery(ctx, tx, count.SQL, q.params...)
+		return err
+	})
+	return page, count, err
+}
+
+// explainQuery returns the JSON plan of a query, with actual timings and
+// buffer usage
+func explainQuery(ctx context.Context, tx pgx.Tx, sql string, args ...any) (json.RawMessage, error) {
+	var plan string
+	if err := tx.QueryRow(ctx, "EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) "+sql, args...).Scan(&plan); err != nil {
+		return nil, err
+	}
+	return json.RawMessage(plan), nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/jackc/pgx/v5"
+
+	"hello-world/backend/parser"
+)
+
+// maxExportDomains is the most domains one export bundle can hold
+const maxExportDomains = 100
+
+// exportDomains returns the domains to export, the normalized values of the
+// comma separated domains parameter or every domain in the database
+func exportDomains(ctx context.Context, param string) ([]string, error) {
+	var domains []string
+	seen := make(map[string]bool)
+	for _, d := range strings.Split(param, ",") {
+		if d = parser.ExtractDomain(d); d != "" && !seen[d] {
+			seen[d] = true
+			domains = append(domains, d)
+		}
+	}
+	if param != "" {
+		return domains, nil
+	}
+
+	// One more than the cap is enough to tell that there are too many
+	rows, err := dbPool.Query(ctx,
+		"SELECT DISTINCT domain FROM entries WHERE domain <> '' AND "+liveEntries+" ORDER BY domain LIMIT $1", maxExportDomains+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d string
+		if err := rows.Scan(&d); err != nil {
+			return nil, err
+		}
+		domains = append(domains, d)
+	}
+	return domains, rows.Err()
+}
+
+// exportFileName returns the name of a domain's CSV file in the bundle,
+// keeping only characters that are safe in file names
+func exportFileName(domain string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, domain)
+	return strings.Trim(name, ".") + ".csv"
+}
+
+// streamDomainExport writes a zip with one CSV file of credentials per domain
+func streamDomainExport(c fiber.Ctx, domains []string) error {
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="credentials-by-domain.zip"`)
+
+	return c.SendStreamWriter(func(w *bufio.Writer) {
+		// Like the NDJSON export, the stream outlasts the request context
+		ctx := context.Background()
+
+		zw := zip.NewWriter(w)
+		for _, domain := range domains {
+			if err := writeDomainCSV(ctx, zw, domain); err != nil {
+				// Headers are already sent, the client gets a truncated zip
+				log.Printf("Domain export failed for %s: %v", domain, err)
+				return
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+
+		if err := zw.Close(); err != nil {
+			log.Printf("Domain export failed: %v", err)
+			return
+		}
+		_ = w.Flush()
+	})
+}
+
+// writeDomainCSV adds the CSV file of a domain's credentials to the zip
+func writeDomainCSV(ctx context.Context, zw *zip.Writer, domain string) error {
+	f, err := zw.Create(exportFileName(domain))
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(f)
+	if err := cw.Write([]string{"url", "username", "password", "created"}); err != nil {
+		return err
+	}
+
+	err = withSearchTimeout(ctx, currentConfig().SearchTimeout, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx,
+			"SELECT url, username, password, created FROM entries WHERE domain = $1 AND "+liveEntries+" ORDER BY id", domain)
+		if err != nil {
+			return fmt.Errorf("failed to query entries: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var url, username, password, created string
+			if err := rows.Scan(&url, &username, &password, &created); err != nil {
+				return fmt.Errorf("failed to scan row: %w", err)
+			}
+			if err := cw.Write([]string{url, username, password, created}); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
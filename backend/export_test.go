@@ -0,0 +1,119 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// getExport downloads an export bundle and returns the rows of each CSV file
+func getExport(t *testing.T, path string) map[string][][]string {
+	t.Helper()
+
+	resp, err := newApp().Test(httptest.NewRequest("GET", path, nil), testConfig)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("response is not a zip: %v", err)
+	}
+
+	files := make(map[string][][]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(rc).ReadAll()
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s is not a CSV file: %v", f.Name, err)
+		}
+		files[f.Name] = rows
+	}
+	return files
+}
+
+func TestExportByDomain(t *testing.T) {
+	setupTestDB(t)
+
+	insertTestEntries(t,
+		Entry{URL: "https://a.com/login", User: "alice", Pass: "one"},
+		Entry{URL: "https://a.com", User: "bob", Pass: "t,wo"},
+		Entry{URL: "https://b.com", User: "carol", Pass: "three"},
+		Entry{URL: "https://c.com", User: "dave", Pass: "four"},
+	)
+
+	files := getExport(t, "/api/export/by-domain?domains=A.com,https://b.com/x,a.com")
+	if len(files) != 2 {
+		t.Fatalf("zip has %d files, want a.com.csv and b.com.csv", len(files))
+	}
+	want := [][]string{
+		{"url", "username", "password", "created"},
+		{"https://a.com/login", "alice", "one", "2025-05-20"},
+		{"https://a.com", "bob", "t,wo", "2025-05-20"},
+	}
+	if !reflect.DeepEqual(files["a.com.csv"], want) {
+		t.Errorf("a.com.csv = %v, want %v", files["a.com.csv"], want)
+	}
+	if rows := len(files["b.com.csv"]); rows != 2 {
+		t.Errorf("b.com.csv has %d rows, want a header and 1 entry", rows)
+	}
+
+	// Without domains every domain is exported
+	files = getExport(t, "/api/export/by-domain")
+	for _, name := range []string{"a.com.csv", "b.com.csv", "c.com.csv"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("full export is missing %s", name)
+		}
+	}
+}
+
+func TestExportByDomainTooMany(t *testing.T) {
+	domains := make([]string, maxExportDomains+1)
+	for i := range domains {
+		domains[i] = fmt.Sprintf("site%d.com", i)
+	}
+
+	path := "/api/export/by-domain?domains=" + strings.Join(domains, ",")
+	resp, err := newApp().Test(httptest.NewRequest("GET", path, nil), testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestExportFileName(t *testing.T) {
+	tests := map[string]string{
+		"a.com":          "a.com.csv",
+		"com.bnb.app":    "com.bnb.app.csv",
+		"../etc/passwd":  "_etc_passwd.csv",
+		"xn--caf-dma.fr": "xn--caf-dma.fr.csv",
+	}
+	for domain, want := range tests {
+		if got := exportFileName(domain); got != want {
+			t.Errorf("exportFileName(%q) = %q, want %q", domain, got, want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+
+	"hello-world/backend/parser"
+)
+
+// TestMain starts a PostgreSQL container for the integration tests unless
+// TEST_DATABASE_URL already points to a database. Run with:
+//
+//	go test -tags integration ./...
+func TestMain(m *testing.M) {
+	if os.Getenv("TEST_DATABASE_URL") != "" {
+		os.Exit(m.Run())
+	}
+
+	ctx := context.Background()
+	container, err := postgres.Run(ctx, "postgres:16-alpine",
+		postgres.WithDatabase("intelxtealer"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		postgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		log.Fatalf("failed to start postgres container: %v", err)
+	}
+
+	databaseURL, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		log.Fatalf("failed to get container connection string: %v", err)
+	}
+	os.Setenv("TEST_DATABASE_URL", databaseURL)
+
+	code := m.Run()
+
+	if err := testcontainers.TerminateContainer(container); err != nil {
+		log.Printf("failed to terminate postgres container: %v", err)
+	}
+	os.Exit(code)
+}
+
+func TestIntegrationProcessLogFile(t *testing.T) {
+	setupTestDB(t)
+
+	cfg := currentConfig()
+	cfg.RejectValues = []string{"UNKNOWN"}
+	setConfig(cfg)
+
+	stats, err := processLogFile(context.Background(), filepath.Join("testdata", "import.txt"), parser.Options{}, nil)
+	if err != nil {
+		t.Fatalf("processLogFile failed: %v", err)
+	}
+	if stats.Inserted != 3 || stats.SkippedShort != 1 || stats.SkippedPlaceholder != 1 {
+		t.Errorf("stats = %+v, want 3 inserted, 1 short and 1 placeholder line skipped", stats)
+	}
+
+	rows, err := dbPool.Query(context.Background(), "SELECT url, username, password, domain FROM entries ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var got []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.URL, &e.User, &e.Pass, &e.Domain); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Entry{
+		{URL: "https://accounts.google.com/signin", User: "alice@gmail.com", Pass: "Sup3rSecret!", Domain: "accounts.google.com"},
+		{URL: "https://www.paypal.com/login", User: "bob", Pass: "hunter2", Domain: "paypal.com"},
+		{URL: "android://Xk2Fy9Q@com.instagram.android/", User: "carol", Pass: "insta2024", Domain: "com.instagram.android"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %+v, want %+v", got, want)
+	}
+}
+
+func TestIntegrationWatcher(t *testing.T) {
+	setupTestDB(t)
+
+	logDir := t.TempDir()
+	w, err := NewLogWatcher(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.pollInterval = 20 * time.Millisecond
+
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	fixture, err := os.ReadFile(filepath.Join("testdata", "import.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(logDir, "dropped.txt"), fixture, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for the watcher to record the file as processed
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var entriesAdded int
+		err := dbPool.QueryRow(context.Background(),
+			"SELECT entries_added FROM processed_log_files WHERE filename = 'dropped.txt'").Scan(&entriesAdded)
+		if err == nil {
+			if entriesAdded != 4 {
+				t.Errorf("entries_added = %d, want 4", entriesAdded)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("dropped file was not ingested")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if got := countEntries(t); got != 4 {
+		t.Errorf("entries = %d, want 4", got)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+// Kinds of credential an entry is classified as, stored in entries.kind
+const (
+	// kindWeb is a website, an http(s) URL or a bare host name
+	kindWeb = "web"
+	// kindAndroid is an app login, android://<hash>@<package>/
+	kindAndroid = "android"
+	// kindFTP is an ftp, ftps or sftp server
+	kindFTP = "ftp"
+	// kindIP is a website or device addressed by its IP address
+	kindIP = "ip"
+	// kindEmailOnly is an email and password without a URL, as in combolists
+	kindEmailOnly = "email-only"
+	// kindNoURL is any other username without a URL
+	kindNoURL = "no-url"
+	// kindOther is a URL with another scheme, e.g. chrome:// or file://
+	kindOther = "other"
+)
+
+// entryKinds lists every kind classify returns
+var entryKinds = []string{kindWeb, kindAndroid, kindFTP, kindIP, kindEmailOnly, kindNoURL, kindOther}
+
+// emailRegexp matches usernames shaped like an email address
+var emailRegexp = regexp.MustCompile(emailPattern)
+
+// classify returns the kind of credential of an entry from the scheme of its
+// URL and whether it's empty or addressed by an IP address
+func classify(url, user string) string {
+	url = strings.TrimSpace(url)
+	if url == "" {
+		if emailRegexp.MatchString(user) {
+			return kindEmailOnly
+		}
+		return kindNoURL
+	}
+
+	rest := url
+	if scheme, after, ok := strings.Cut(url, "://"); ok {
+		rest = after
+		switch strings.ToLower(scheme) {
+		case "android":
+			return kindAndroid
+		case "ftp", "ftps", "sftp":
+			return kindFTP
+		case "http", "https":
+		default:
+			return kindOther
+		}
+	}
+
+	if isIPHost(rest) {
+		return kindIP
+	}
+	return kindWeb
+}
+
+// isIPHost reports whether the authority at the start of a URL without its
+// scheme, e.g. "user@10.0.0.1:8080/admin", is an IP address
+func isIPHost(rest string) bool {
+	host := rest
+	if i := strings.IndexAny(host, "/?#"); i >= 0 {
+		host = host[:i]
+	}
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return net.ParseIP(host) != nil
+}
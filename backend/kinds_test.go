@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"hello-world/backend/parser"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		url, user string
+		want      string
+	}{
+		{"https://example.com/login", "alice", kindWeb},
+		{"http://www.example.com", "alice", kindWeb},
+		{"example.com", "alice", kindWeb},
+		{"HTTPS://EXAMPLE.COM", "alice", kindWeb},
+		{"android://gNDQRvwT2GhkTMztoIx0GgXEEXR6GCnBN3MAHPuOa5w7LcsCcxLQY-1lxuyQqKSLxWjn9GqImVc2M1yoASB7Eg==@com.bnb.paynearby/", "9047161186", kindAndroid},
+		{"ftp://files.example.com", "bob", kindFTP},
+		{"sftp://10.0.0.5:22", "bob", kindFTP},
+		{"http://192.168.1.1:8080/admin", "admin", kindIP},
+		{"https://admin@10.0.0.1/", "admin", kindIP},
+		{"http://[2001:db8::1]:443/", "admin", kindIP},
+		{"172.16.0.1", "admin", kindIP},
+		{"", "alice@gmail.com", kindEmailOnly},
+		{"", "john.doe", kindNoURL},
+		{"chrome://settings", "carol", kindOther},
+	}
+	for _, tt := range tests {
+		if got := classify(tt.url, tt.user); got != tt.want {
+			t.Errorf("classify(%q, %q) = %q, want %q", tt.url, tt.user, got, tt.want)
+		}
+	}
+}
+
+func TestSearchKindFilter(t *testing.T) {
+	if _, err := buildSearchQuery(searchFilter{Kinds: []string{"mobile"}}); err == nil {
+		t.Error("unknown kind was accepted")
+	}
+
+	setupTestDB(t)
+	content := "https://a.com:alice:one\nandroid://abc==@com.app/:bob:two\nhttp://10.0.0.1:8080:carol:three\nhttps://d.com:dave:four\n"
+	if _, err := processReader(context.Background(), strings.NewReader(content), "kinds.txt", parser.Options{}, nil); err != nil {
+		t.Fatalf("processReader failed: %v", err)
+	}
+
+	var result PaginationResponse
+	if status := getJSON(t, "/api/search?kind=android", &result); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if len(result.Items) != 1 || result.Items[0].User != "bob" || result.Items[0].Kind != kindAndroid {
+		t.Errorf("items = %+v, want bob's android entry", result.Items)
+	}
+
+	var stats struct {
+		Total int `json:"total"`
+		Kinds []struct {
+			Kind  string `json:"kind"`
+			Count int    `json:"count"`
+		} `json:"kinds"`
+	}
+	if status := getJSON(t, "/api/stats/kinds", &stats); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	got := map[string]int{}
+	for _, k := range stats.Kinds {
+		got[k.Kind] = k.Count
+	}
+	if want := map[string]int{kindWeb: 2, kindAndroid: 1, kindIP: 1}; !reflect.DeepEqual(got, want) || stats.Total != 4 {
+		t.Errorf("kinds = %v (total %d), want %v", got, stats.Total, want)
+	}
+}
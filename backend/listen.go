@@ -0,0 +1,41 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+)
+
+// listenAddr returns the address the server listens on
+func listenAddr(c Config) string {
+	return net.JoinHostPort(c.Host, c.Port)
+}
+
+// newListener opens the server's listener, serving TLS when a certificate
+// and key are configured
+func newListener(c Config) (net.Listener, error) {
+	if c.TLSCert == "" {
+		return net.Listen("tcp", listenAddr(c))
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.TLSCert, c.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	return tls.Listen("tcp", listenAddr(c), &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	})
+}
+
+// serverURL describes the listen address for the startup log
+func serverURL(c Config) string {
+	scheme, host := "http", c.Host
+	if c.TLSCert != "" {
+		scheme = "https"
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, c.Port))
+}
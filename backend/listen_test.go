@@ -0,0 +1,54 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestListenAddrFromEnv(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+
+	tests := []struct {
+		host, port string
+		addr, url  string
+	}{
+		{"", "", ":3000", "http://localhost:3000"},
+		{"127.0.0.1", "8080", "127.0.0.1:8080", "http://127.0.0.1:8080"},
+		{"::1", "8443", "[::1]:8443", "http://[::1]:8443"},
+		{"", "http", ":3000", "http://localhost:3000"},
+		{"", "70000", ":3000", "http://localhost:3000"},
+	}
+	for _, tt := range tests {
+		t.Setenv("HOST", tt.host)
+		t.Setenv("PORT", tt.port)
+
+		cfg := loadConfig()
+		if got := listenAddr(cfg); got != tt.addr {
+			t.Errorf("HOST=%q PORT=%q: listenAddr = %q, want %q", tt.host, tt.port, got, tt.addr)
+		}
+		if got := serverURL(cfg); got != tt.url {
+			t.Errorf("HOST=%q PORT=%q: serverURL = %q, want %q", tt.host, tt.port, got, tt.url)
+		}
+	}
+}
+
+func TestNewListener(t *testing.T) {
+	ln, err := newListener(Config{Host: "127.0.0.1", Port: "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+
+	// A certificate without a key is ignored when the configuration is loaded
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("TLS_CERT", "server.crt")
+	t.Setenv("TLS_KEY", "")
+	if cfg := loadConfig(); cfg.TLSCert != "" {
+		t.Errorf("TLSCert = %q without TLS_KEY, want TLS disabled", cfg.TLSCert)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+	if _, err := newListener(Config{Host: "127.0.0.1", Port: "0", TLSCert: missing, TLSKey: missing}); err == nil {
+		t.Error("expected an error for missing TLS files")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"hello-world/backend/parser"
+)
+
+// logFileExtensions are always treated as log files
+var logFileExtensions = []string{".txt", ".log"}
+
+// sniffSize is how much of an extensionless file is inspected
+const sniffSize = 512
+
+// isLogFileName reports whether a file name could be a log file: it has one
+// of the log file extensions or no extension at all, which is common for
+// files extracted from archives. Extensionless files still have to pass
+// looksLikeCredentialFile.
+func isLogFileName(name string) bool {
+	ext := filepath.Ext(name)
+	if ext == "" {
+		return true
+	}
+	for _, e := range logFileExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// looksLikeCredentialFile reports whether path should be imported. Files with
+// a log file extension are accepted as they are, extensionless files when
+// their first bytes are UTF-8 text containing a field separator.
+func looksLikeCredentialFile(path string) bool {
+	if !isLogFileName(path) {
+		return false
+	}
+	if filepath.Ext(path) != "" {
+		return true
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	return looksLikeCredentials(file)
+}
+
+// looksLikeCredentials reports whether the first bytes read from r are UTF-8
+// text containing a field separator
+func looksLikeCredentials(r io.Reader) bool {
+	// UTF-16 files are checked after decoding them like the importer does
+	buf := make([]byte, sniffSize)
+	n, err := io.ReadFull(parser.DecodeBOM(r), buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false
+	}
+	buf = buf[:n]
+
+	// Binary files almost always contain NUL bytes
+	if bytes.IndexByte(buf, 0) >= 0 {
+		return false
+	}
+
+	// The sample may end in the middle of a multi-byte character
+	if i := lastRuneStart(buf); n == sniffSize && !utf8.FullRune(buf[i:]) {
+		buf = buf[:i]
+	}
+	if !utf8.Valid(buf) {
+		return false
+	}
+
+	return bytes.ContainsAny(buf, ":|,\t")
+}
+
+// lastRuneStart returns the index where the last, possibly incomplete,
+// character of buf starts
+func lastRuneStart(buf []byte) int {
+	i := max(len(buf)-1, 0)
+	for i > 0 && !utf8.RuneStart(buf[i]) {
+		i--
+	}
+	return i
+}
+
+// listLogFiles returns the paths of the log files in dir, sorted by name
+func listLogFiles(dir string) ([]string, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range dirEntries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if looksLikeCredentialFile(path) {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLooksLikeCredentialFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// The sample ends in the middle of the two byte ü
+	prefix := "https://a.com:user:"
+	longText := prefix + strings.Repeat("p", sniffSize-1-len(prefix)) + "ü\n"
+
+	files := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{"dump.txt", "https://a.com:user:pass\n", true},
+		{"dump.log", "https://a.com:user:pass\n", true},
+		{"empty.log", "", true},
+		{"passwords", "https://a.com:user:pass\n", true},
+		{"combolist", "alice@example.com|hunter2\n", true},
+		{"unicode", longText, true},
+		{"utf16", "\xff\xfeu\x00:\x00p\x00\n\x00", true},
+		{"readme", "just some prose without separators\n", false},
+		{"empty", "", false},
+		{"image", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR:", false},
+		{"latin1", "user:p\xe4ss\n", false},
+		{"archive.zip", "https://a.com:user:pass\n", false},
+	}
+
+	for _, f := range files {
+		t.Run(f.name, func(t *testing.T) {
+			path := filepath.Join(dir, f.name)
+			if err := os.WriteFile(path, []byte(f.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if got := looksLikeCredentialFile(path); got != f.want {
+				t.Errorf("looksLikeCredentialFile(%s) = %v, want %v", f.name, got, f.want)
+			}
+		})
+	}
+}
+
+func TestListLogFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.txt":   "https://a.com:user:pass\n",
+		"b.log":   "https://b.com:user:pass\n",
+		"c":       "https://c.com:user:pass\n",
+		"d.bin":   "https://d.com:user:pass\n",
+		"e":       "\x00\x01\x02\x03",
+		"f.jsonl": `{"url": "https://f.com"}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "nested"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := listLogFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+	if want := []string{"a.txt", "b.log", "c"}; !slices.Equal(names, want) {
+		t.Errorf("log files = %v, want %v", names, want)
+	}
+}
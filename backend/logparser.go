@@ -1,30 +1,103 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strings"
+	"sync"
 	"time"
-	"unicode/utf8"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"hello-world/backend/parser"
 )
 
 // ParseLogDirectory parses all log files in the specified directory
-// and adds their contents to the database, skipping already processed files
-func ParseLogDirectory(logDir string) error {
+// and adds their contents to the database, skipping already processed files.
+// Up to maxConcurrentFiles files are processed at once, each holding one
+// pooled connection; 0 uses MAX_CONCURRENT_FILES. Each call is recorded as an
+// import run.
+func ParseLogDirectory(logDir string, maxConcurrentFiles int) error {
+	// Record the run and its outcome in import_runs
+	var runID *int
+	if recordsImports(currentConfig()) {
+		var err error
+		runID, err = startImportRun(context.Background())
+		if err != nil {
+			log.Printf("Warning: Failed to record import run: %v", err)
+		}
+	}
+
+	return importLogDirectory(logDir, runID, maxConcurrentFiles)
+}
+
+// reprocessAll purges the database and imports every file in logDir again as
+// the already started import run runID
+func reprocessAll(logDir string, runID int, actor string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	entriesRemoved, filesRemoved, err := purgeAll(ctx, auditReprocessAll, actor)
+	if err != nil {
+		if dbErr := finishImportRun(runID, importRunFailed, 0, 0); dbErr != nil {
+			log.Printf("Warning: Failed to update import run %d: %v", runID, dbErr)
+		}
+		return fmt.Errorf("failed to purge database: %w", err)
+	}
+	log.Printf("Reprocessing %s: purged %d entries and %d processed file records", logDir, entriesRemoved, filesRemoved)
+
+	return importLogDirectory(logDir, &runID, 0)
+}
+
+// importLogDirectory does the work of ParseLogDirectory, recording the
+// outcome in the import run runID unless it's nil
+func importLogDirectory(logDir string, runID *int, maxConcurrentFiles int) (err error) {
 	// Create a context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
 
+	runStart := time.Now()
+	filesProcessed := 0
+	totalEntries := 0
+	totalSkipped := 0
+	defer func() {
+		status := importRunCompleted
+		if ctx.Err() != nil {
+			status = importRunCancelled
+		} else if err != nil {
+			status = importRunFailed
+		}
+
+		if runID != nil {
+			if dbErr := finishImportRun(*runID, status, filesProcessed, totalEntries); dbErr != nil {
+				log.Printf("Warning: Failed to update import run %d: %v", *runID, dbErr)
+			}
+		}
+
+		// Notify the webhook once files were imported or the run failed
+		if filesProcessed > 0 || err != nil {
+			notifyWebhook(currentConfig(), webhookPayload{
+				Event:          webhookEventImportRun,
+				File:           logDir,
+				EntriesAdded:   totalEntries,
+				Skipped:        totalSkipped,
+				DurationMs:     time.Since(runStart).Milliseconds(),
+				FilesProcessed: filesProcessed,
+				Status:         status,
+			})
+		}
+	}()
+
 	// Get all files in the log directory
-	files, err := filepath.Glob(filepath.Join(logDir, "*.txt"))
+	files, err := listLogFiles(logDir)
 	if err != nil {
 		return fmt.Errorf("failed to read log directory: %w", err)
 	}
@@ -35,10 +108,14 @@ func ParseLogDirectory(logDir string) error {
 
 	log.Printf("Found %d log files to check", len(files))
 
-	// Get list of already processed files from the database
+	// Get list of already processed files from the database. Files skipped
+	// for their size are checked again in case MAX_FILE_SIZE was raised.
+	// Without records every file is imported again.
+	cfg := currentConfig()
 	processedFiles := make(map[string]bool)
-	rows, err := dbPool.Query(ctx, "SELECT filename FROM processed_log_files")
-	if err != nil {
+	if !recordsImports(cfg) {
+		log.Printf("Processed files aren't recorded with SINK=%s, importing every file", cfg.Sink)
+	} else if rows, err := dbPool.Query(ctx, "SELECT filename FROM processed_log_files WHERE status = $1", fileStatusProcessed); err != nil {
 		log.Printf("Warning: Failed to query processed files: %v", err)
 	} else {
 		defer rows.Close()
@@ -59,12 +136,23 @@ func ParseLogDirectory(logDir string) error {
 		log.Printf("Found %d previously processed files in database", len(processedFiles))
 	}
 
-	// Filter out already processed files
+	// Filter out already processed files unless their content changed
 	var filesToProcess []string
 	for _, file := range files {
 		fileName := filepath.Base(file)
 		if !processedFiles[fileName] {
 			filesToProcess = append(filesToProcess, file)
+			continue
+		}
+
+		changed, err := fileChanged(ctx, file)
+		if err != nil {
+			log.Printf("Warning: Failed to check %s for changes: %v", fileName, err)
+			continue
+		}
+		if changed {
+			log.Printf("File %s changed since it was processed, reprocessing", fileName)
+			filesToProcess = append(filesToProcess, file)
 		}
 	}
 
@@ -73,317 +161,902 @@ func ParseLogDirectory(logDir string) error {
 		return nil
 	}
 
-	log.Printf("Processing %d new log files", len(filesToProcess))
-	totalEntries := 0
+	if maxConcurrentFiles <= 0 {
+		maxConcurrentFiles = cfg.MaxConcurrentFiles
+	}
+	log.Printf("Processing %d new log files, %d at a time", len(filesToProcess), max(maxConcurrentFiles, 1))
 
-	// Process each new file
-	for _, file := range filesToProcess {
+	// Process the new files, the totals are shared by the concurrent imports
+	var mu sync.Mutex
+	forEachFile(ctx, filesToProcess, maxConcurrentFiles, func(file string) {
 		fileName := filepath.Base(file)
-		count, err := processLogFile(ctx, file)
+		if skipTooLargeFile(ctx, file, cfg.MaxFileSize, runID) {
+			return
+		}
+
+		stats, err := processLogFile(ctx, file, parser.Options{}, runID)
 		if err != nil {
 			log.Printf("Error processing file %s: %v", fileName, err)
-			continue
+			return
 		}
 
-		// Record the processed file in the database
-		_, dbErr := dbPool.Exec(ctx,
-			"INSERT INTO processed_log_files (filename, entries_added) VALUES ($1, $2) "+
-				"ON CONFLICT (filename) DO UPDATE SET processed_at = NOW(), entries_added = $2",
-			fileName, count)
-
-		if dbErr != nil {
-			log.Printf("Warning: Failed to record processed file in database: %v", dbErr)
+		if stats.DuplicateOf != "" {
+			log.Printf("Skipped %s: same content as %s", fileName, stats.DuplicateOf)
+			return
 		}
 
-		totalEntries += count
-		log.Printf("Processed %s: %d entries added", fileName, count)
+		mu.Lock()
+		filesProcessed++
+		totalEntries += stats.Inserted
+		totalSkipped += stats.Skipped()
+		mu.Unlock()
+		log.Printf("Processed %s: %d entries added, %d lines skipped", fileName, stats.Inserted, stats.Skipped())
+	})
+	if ctx.Err() != nil {
+		return fmt.Errorf("import cancelled: %w", ctx.Err())
 	}
 
 	log.Printf("Total entries added to database: %d", totalEntries)
 	return nil
 }
 
-// processLogFile reads a single log file and processes each line
-func processLogFile(ctx context.Context, filePath string) (int, error) {
-	// Open the file
-	file, err := os.Open(filePath)
+// forEachFile calls process for every file, running at most maxConcurrent
+// calls at once, and returns when they are done. No further files are
+// started once ctx is done.
+func forEachFile(ctx context.Context, files []string, maxConcurrent int, process func(file string)) {
+	sem := make(chan struct{}, max(maxConcurrent, 1))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for _, file := range files {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		if ctx.Err() != nil {
+			<-sem
+			return
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			process(file)
+		}()
+	}
+}
+
+// execer runs a statement on the pool or inside a transaction
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// recordProcessedFile stores the outcome of processing a file in processed_log_files
+// and recentImports. runID links the file to the import run that processed it
+// and may be nil.
+func recordProcessedFile(ctx context.Context, db execer, fileName string, stats ParseStats, duration time.Duration, runID *int) error {
+	// Listed by GET /recent even when the database write below fails
+	addRecentImport(fileName, stats, duration)
+
+	// Files whose hash couldn't be computed are stored without one
+	var hash any
+	if stats.SHA256 != "" {
+		hash = stats.SHA256
+	}
+
+	_, err := db.Exec(ctx,
+		"INSERT INTO processed_log_files (filename, entries_added, duration_ms, lines_skipped, run_id, sha256, status, duplicates_skipped) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) "+
+			"ON CONFLICT (filename) DO UPDATE SET processed_at = NOW(), entries_added = $2, duration_ms = $3, lines_skipped = $4, run_id = $5, sha256 = $6, status = $7, duplicates_skipped = $8",
+		fileName, stats.Inserted, duration.Milliseconds(), stats.Skipped(), runID, hash, fileStatusProcessed, stats.DuplicatesSkipped())
+	return err
+}
+
+// addRecentImport lists an imported file in GET /recent
+func addRecentImport(fileName string, stats ParseStats, duration time.Duration) {
+	recentImports.add(RecentImport{
+		Filename:   fileName,
+		Entries:    stats.Inserted,
+		DurationMs: duration.Milliseconds(),
+		ImportedAt: time.Now(),
+	})
+}
+
+// recordsImports reports whether processed files and import runs are
+// recorded in the database. SINK=file keeps nothing there so it works
+// without a database, which only a server started with SINK=file may lack.
+func recordsImports(cfg Config) bool {
+	return cfg.Sink == sinkPostgres && dbPool != nil
+}
+
+// Statuses of a processed_log_files row
+const (
+	fileStatusProcessed = "processed"
+	fileStatusTooLarge  = "skipped_too_large"
+)
+
+// fileTooLarge reports whether a file is larger than maxSize bytes, a
+// maxSize of 0 means there is no limit
+func fileTooLarge(filePath string, maxSize int64) bool {
+	if maxSize <= 0 {
+		return false
+	}
+	info, err := os.Stat(filePath)
+	return err == nil && info.Size() > maxSize
+}
+
+// skipTooLargeFile records a file larger than maxSize as skipped so a single
+// huge file doesn't hold up the import, and reports whether it was skipped.
+// It can still be imported with POST /process-file and force.
+func skipTooLargeFile(ctx context.Context, filePath string, maxSize int64, runID *int) bool {
+	if !fileTooLarge(filePath, maxSize) {
+		return false
+	}
+
+	fileName := filepath.Base(filePath)
+	log.Printf("Warning: Skipping %s: larger than MAX_FILE_SIZE (%d bytes), use /process-file with force=true to import it", fileName, maxSize)
+	if !recordsImports(currentConfig()) {
+		return true
+	}
+
+	// A file that was imported before it grew keeps its processed row
+	_, err := dbPool.Exec(ctx,
+		"INSERT INTO processed_log_files (filename, entries_added, duration_ms, lines_skipped, run_id, status) VALUES ($1, 0, 0, 0, $2, $3) "+
+			"ON CONFLICT (filename) DO UPDATE SET processed_at = NOW(), run_id = $2 WHERE processed_log_files.status = $3",
+		fileName, runID, fileStatusTooLarge)
 	if err != nil {
-		return 0, fmt.Errorf("failed to open file: %w", err)
+		log.Printf("Warning: Failed to record skipped file in database: %v", err)
 	}
-	defer file.Close()
+	return true
+}
 
-	log.Printf("Processing file: %s", filepath.Base(filePath))
+// fileChanged reports whether a processed file's content differs from when it
+// was processed. Files recorded before hashes were stored count as unchanged,
+// and so do all files when imports aren't recorded.
+func fileChanged(ctx context.Context, filePath string) (bool, error) {
+	if !recordsImports(currentConfig()) {
+		return false, nil
+	}
+	var stored *string
+	err := dbPool.QueryRow(ctx,
+		"SELECT sha256 FROM processed_log_files WHERE filename = $1",
+		filepath.Base(filePath)).Scan(&stored)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && stored == nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
 
-	// Create a prepared statement for better performance
-	const insertSQL = "INSERT INTO entries (url, username, password, created) VALUES ($1, $2, $3, $4)"
+	hash, err := hashFile(filePath)
+	if err != nil {
+		return false, err
+	}
+	return hash != *stored, nil
+}
 
-	// Acquire a connection from the pool for this operation
-	conn, err := dbPool.Acquire(ctx)
+// hashFile returns the hex encoded SHA-256 of a file's content
+func hashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
 	if err != nil {
-		return 0, fmt.Errorf("failed to acquire database connection: %w", err)
+		return "", err
 	}
-	defer conn.Release()
-	// Prepare the statement - the name "insert_entry" is used in batch.Queue later
-	_, err = conn.Conn().Prepare(ctx, "insert_entry", insertSQL)
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// Import run statuses
+const (
+	importRunRunning   = "running"
+	importRunCompleted = "completed"
+	importRunFailed    = "failed"
+	importRunCancelled = "cancelled"
+)
+
+// ImportRun is a single invocation of ParseLogDirectory
+type ImportRun struct {
+	ID             int        `json:"id"`
+	StartedAt      time.Time  `json:"startedAt"`
+	FinishedAt     *time.Time `json:"finishedAt"`
+	FilesProcessed int        `json:"filesProcessed"`
+	EntriesAdded   int        `json:"entriesAdded"`
+	Status         string     `json:"status"`
+}
+
+// startImportRun records the start of an import run and returns its ID
+func startImportRun(ctx context.Context) (*int, error) {
+	var id int
+	err := dbPool.QueryRow(ctx,
+		"INSERT INTO import_runs (status) VALUES ($1) RETURNING id", importRunRunning).Scan(&id)
 	if err != nil {
-		return 0, fmt.Errorf("failed to prepare statement: %w", err)
+		return nil, err
 	}
+	return &id, nil
+}
 
-	// Create a scanner to read the file line by line
-	scanner := bufio.NewScanner(file)
+// finishImportRun records the outcome of an import run. It uses its own
+// context so cancelled runs are still recorded.
+func finishImportRun(id int, status string, filesProcessed, entriesAdded int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	// Buffer size optimization for large files
-	const maxCapacity = 512 * 1024 // 512KB
-	buf := make([]byte, maxCapacity)
-	scanner.Buffer(buf, maxCapacity)
+	_, err := dbPool.Exec(ctx,
+		"UPDATE import_runs SET finished_at = NOW(), status = $2, files_processed = $3, entries_added = $4 WHERE id = $1",
+		id, status, filesProcessed, entriesAdded)
+	return err
+}
 
-	// Use a custom split function that can handle problematic bytes
-	scanner.Split(func(data []byte, atEOF bool) (advance int, token []byte, err error) {
-		// Skip null bytes and try to find the next newline
-		start := 0
-		for start < len(data) && data[start] == 0 {
-			start++
-		}
+// ParseStats summarizes how the lines of a log file were handled: the parse
+// statistics plus what happened when the entries were imported
+type ParseStats struct {
+	parser.Stats
+	// Inserted is the number of entries written to the database
+	Inserted int `json:"inserted"`
+	// SkippedDuplicate counts entries already in the database, only with DEDUPE_SCOPE
+	SkippedDuplicate int `json:"skippedDuplicate"`
+	// SkippedInFileDuplicate counts entries repeated within the file
+	SkippedInFileDuplicate int `json:"skippedInFileDuplicate"`
+	// SkippedDomain counts entries dropped by DOMAIN_ALLOWLIST or DOMAIN_DENYLIST
+	SkippedDomain int `json:"skippedDomain"`
+	// SkippedRejected counts entries the database refused to insert, such as
+	// values too large for an index
+	SkippedRejected int `json:"skippedRejected"`
+	// SHA256 is the hex encoded hash of the file content
+	SHA256 string `json:"sha256,omitempty"`
+	// DuplicateOf names the processed file with identical content when the
+	// input was skipped as a copy of it
+	DuplicateOf string `json:"duplicateOf,omitempty"`
+}
+
+// Skipped returns the total number of lines that were not imported
+func (s ParseStats) Skipped() int {
+	return s.Stats.Skipped() + s.SkippedDomain + s.SkippedRejected + s.DuplicatesSkipped()
+}
+
+// DuplicatesSkipped returns the number of parsed entries that were not
+// inserted because they were already in the database or earlier in the file
+func (s ParseStats) DuplicatesSkipped() int {
+	return s.SkippedDuplicate + s.SkippedInFileDuplicate
+}
+
+// Parsed returns the number of entries parsed from the file, whether or not
+// they were inserted
+func (s ParseStats) Parsed() int {
+	return s.Inserted + s.SkippedRejected + s.DuplicatesSkipped()
+}
 
-		if start == len(data) {
-			if atEOF {
-				return 0, nil, nil
+// scanEntries parses r with the parser package and calls fn for every entry,
+// counting read and skipped lines in stats. The reject and domain lists come
+// from cfg. Both the import and the dry-run paths share this so they agree on
+// what parses.
+func scanEntries(r io.Reader, cfg Config, opts parser.Options, stats *ParseStats, fn func(entry parser.Entry) error) error {
+	opts.RejectValues = cfg.RejectValues
+	opts.StripControl = cfg.SanitizeStripControl
+	opts.NormalizePasswords = cfg.NormalizePasswords
+	opts.NormalizeUsername = cfg.NormalizeUsername
+	opts.Limits = fieldLimits(cfg)
+	opts.KeepRaw = cfg.RawLine
+	opts.SkipLine = cfg.SkipLineRegex
+	if len(cfg.DomainAllowlist) > 0 || len(cfg.DomainDenylist) > 0 {
+		next := fn
+		fn = func(entry parser.Entry) error {
+			if !domainAllowed(cfg, parser.ExtractDomain(entry.URL)) {
+				stats.SkippedDomain++
+				return nil
 			}
-			// Request more data
-			return 0, nil, nil
+			return next(entry)
 		}
-
-		// Look for newline after skipping null bytes
-		if i := bytes.IndexByte(data[start:], '\n'); i >= 0 {
-			// We have a full line
-			return start + i + 1, dropInvalidUtf8(data[start : start+i]), nil
+	}
+	if cfg.HashPasswords {
+		// Hashed after the reject list saw the plaintext
+		next := fn
+		fn = func(entry parser.Entry) error {
+			entry.Password = storedPassword(cfg, entry.Password)
+			return next(entry)
 		}
+	}
+	return parser.Scan(r, opts, &stats.Stats, fn)
+}
 
-		// If we're at EOF, return the remaining data
-		if atEOF {
-			return len(data), dropInvalidUtf8(data[start:]), nil
-		}
+// processLogFile reads a single log file and processes each line. When the
+// database connection drops the import is attempted again, up to
+// insertRetryAttempts times, resuming after the last committed batch. Files
+// without checkpoints would insert their committed batches again, so they're
+// only attempted once. runID is the import run the entries are recorded with
+// and may be nil.
+func processLogFile(ctx context.Context, filePath string, opts parser.Options, runID *int) (ParseStats, error) {
+	attempts := insertRetryAttempts
+	if !retryResumes(filePath, currentConfig()) {
+		attempts = 1
+	}
 
-		// Request more data
-		return 0, nil, nil
+	var stats ParseStats
+	err := retryTransient(ctx, attempts, insertRetryDelay, func() error {
+		var err error
+		stats, err = processLogFileOnce(ctx, filePath, opts, runID)
+		return err
 	})
+	return stats, err
+}
 
-	// Create a batch
-	batch := &pgx.Batch{}
+// retryResumes reports whether an import of filePath that failed midway can
+// be attempted again without storing its entries twice. ATOMIC_IMPORT rolls
+// back what was inserted and SINK=file only appends whole imports, while
+// batches committed by importInBatches are only skipped from a checkpoint.
+// A file that can't be opened fails the same way on every attempt.
+func retryResumes(filePath string, cfg Config) bool {
+	if cfg.AtomicImport || cfg.Sink == sinkFile {
+		return true
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return true
+	}
+	defer file.Close()
 
-	entryCount := 0
-	batchSize := 0
-	maxBatchSize := 1000 // Process in batches of 1000 entries
-	currentTime := time.Now().Format("2006-01-02")
+	_, _, checkpointed, err := checkpointInput(file)
+	return checkpointed && err == nil
+}
 
-	// For each line in the file
-	for scanner.Scan() {
-		// Get line and sanitize it to handle invalid UTF-8 characters
-		line := sanitizeString(scanner.Text())
+// createdDate returns the date entries read from r are stamped with, the
+// modification time of a file with CREATED_SOURCE=file_mtime and today
+// otherwise. Readers that aren't files have no modification time.
+func createdDate(r io.Reader, sourceName, source string) string {
+	created := time.Now()
+	if file, ok := r.(interface{ Stat() (os.FileInfo, error) }); ok && source == createdSourceFileMtime {
+		if info, err := file.Stat(); err == nil {
+			created = info.ModTime()
+		} else {
+			log.Printf("Warning: Failed to stat %s, using the import time: %v", sourceName, err)
+		}
+	}
+	return created.Format("2006-01-02")
+}
 
-		// Skip empty lines
-		if len(strings.TrimSpace(line)) == 0 {
-			continue
+// processLogFileOnce makes a single attempt at importing a log file
+func processLogFileOnce(ctx context.Context, filePath string, opts parser.Options, runID *int) (ParseStats, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return ParseStats{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return processReader(ctx, file, filepath.Base(filePath), opts, runID)
+}
+
+// errNoDatabase is returned by imports into Postgres when the server started
+// without a database, which only SINK=file allows
+var errNoDatabase = errors.New("no database connection, only SINK=file imports work without one")
+
+// processReader imports the log lines read from r and records the input in
+// processed_log_files. sourceName is the file name of the input, which
+// selects the parse rule; content already processed under another name is
+// reported in DuplicateOf. Entries are recorded with sourceName, the import
+// run runID, if any, and their line number, and written to the sink selected
+// by SINK. The reader is consumed, so unlike processLogFile it isn't retried.
+// Progress is reported to the callback set on ctx with withProgress.
+//
+// Entries are committed a batch at a time by importInBatches. With
+// ATOMIC_IMPORT nothing may be kept before the end, so importInTransaction
+// imports the input in one transaction instead. SINK=file doesn't use the
+// database, importToFile neither records the input nor compares it with the
+// processed files.
+func processReader(ctx context.Context, r io.Reader, sourceName string, opts parser.Options, runID *int) (ParseStats, error) {
+	log.Printf("Processing file: %s", sourceName)
+
+	// Settings are read once so a reload doesn't change them mid-file
+	cfg := currentConfig()
+	if opts.Rule == nil {
+		opts.Rule = matchParseRule(cfg.ParseRules, sourceName)
+	}
+
+	if cfg.Sink == sinkFile {
+		return importToFile(ctx, cfg, r, sourceName, opts, runID)
+	}
+	if dbPool == nil {
+		return ParseStats{}, errNoDatabase
+	}
+	if cfg.AtomicImport {
+		return importInTransaction(ctx, cfg, r, sourceName, opts, runID)
+	}
+	return importInBatches(ctx, cfg, r, sourceName, opts, runID)
+}
+
+// importInBatches imports r committing every batch, and records the input
+// with the last batch. When checkpointInput accepts r each batch is
+// committed together with a checkpoint in file_progress, and an import of
+// the same content under sourceName that was interrupted resumes after its
+// last checkpoint. Such inputs are hashed before they're imported, so a copy
+// of a processed file is skipped. Other inputs can't be read again: a read
+// error keeps the entries read before it but leaves the input unrecorded,
+// and they're hashed as they're parsed, so a copy is imported like any other
+// input and only logged at the end.
+func importInBatches(ctx context.Context, cfg Config, r io.Reader, sourceName string, opts parser.Options, runID *int) (ParseStats, error) {
+	var stats ParseStats
+	start := time.Now()
+
+	input, inputStart, checkpointed, err := checkpointInput(r)
+	if err != nil {
+		return stats, fmt.Errorf("failed to read %s: %w", sourceName, err)
+	}
+	hasher := &inputHasher{hash: sha256.New()}
+	// committed counts the entries of the batches committed before
+	var committed ParseStats
+	if checkpointed {
+		// Skip files whose content was already imported under another name
+		stats.SHA256, err = hashInput(input, inputStart)
+		if err != nil {
+			return stats, fmt.Errorf("failed to read %s: %w", sourceName, err)
+		}
+		stats.DuplicateOf, err = findDuplicateContent(ctx, dbPool, stats.SHA256, sourceName)
+		if err != nil {
+			return stats, err
+		}
+		if stats.DuplicateOf != "" {
+			// Nothing is inserted, the copy is recorded so it's recognized later
+			if err := recordProcessedFile(ctx, dbPool, sourceName, stats, time.Since(start), runID); err != nil {
+				log.Printf("Warning: Failed to record processed file in database: %v", err)
+			}
+			return stats, nil
 		}
 
-		// Parse the line
-		parts := splitLogLine(line)
-		if len(parts) < 3 {
-			// Skip invalid lines without logging to avoid spam
-			continue
+		// A checkpoint of other content under the same name is overwritten
+		progress, err := loadFileProgress(ctx, dbPool, sourceName)
+		if err != nil {
+			return stats, fmt.Errorf("failed to load the import checkpoint: %w", err)
+		}
+		var resumed bool
+		hasher, resumed, err = resumeInput(input, inputStart, progress)
+		if err != nil {
+			return stats, fmt.Errorf("failed to resume %s: %w", sourceName, err)
+		}
+		if resumed {
+			log.Printf("Resuming %s after line %d", sourceName, progress.End.Line)
+			committed = progress.Stats
+			stats = progress.Stats
+			opts.Resume = &progress.End
+			opts.ComboMode = progress.Stats.ComboMode
 		}
+	}
 
-		// Sanitize each part to ensure no invalid UTF-8 characters
-		url := sanitizeString(parts[0])
-		username := sanitizeString(parts[1])
-		password := sanitizeString(parts[2])
-
-		// Queue the prepared statement in the batch
-		batch.Queue("insert_entry", url, username, password, currentTime)
-		batchSize++
-		entryCount++
-
-		// Execute batch when it reaches the maximum size
-		if batchSize >= maxBatchSize {
-			// Send batch and get results
-			br := conn.Conn().SendBatch(ctx, batch)
-			_, err := br.Exec()
-			if err != nil {
-				_ = br.Close()
-				return entryCount - batchSize, fmt.Errorf("batch execution failed: %w", err)
-			}
-			_ = br.Close() // Close the batch results
+	conn, tx, insertName, err := beginImport(ctx, cfg)
+	if err != nil {
+		return stats, err
+	}
+	defer conn.Release()
+	defer tx.Rollback(ctx)
+
+	sink := &postgresSink{ctx: ctx, tx: tx, insertName: insertName}
+	w := newImportWriter(ctx, cfg, tx, sink, r, sourceName, runID, committed)
 
-			if entryCount%10000 == 0 {
-				log.Printf("Processed %d entries so far", entryCount)
+	scanErr := w.scan(io.TeeReader(r, hasher), cfg, opts, &stats, func(end parser.Position) error {
+		if err := w.insertProxies(); err != nil {
+			return err
+		}
+		if checkpointed {
+			w.count(&stats)
+			checkpoint := fileProgress{SHA256: hasher.sum(), HashedBytes: hasher.size, End: end, Stats: stats}
+			if err := saveFileProgress(ctx, tx, sourceName, checkpoint); err != nil {
+				return fmt.Errorf("failed to save the import checkpoint: %w", err)
 			}
+		}
+		// The chained transaction keeps the sink's transaction usable
+		if _, err := tx.Exec(ctx, "COMMIT AND CHAIN"); err != nil {
+			return fmt.Errorf("failed to commit entries: %w", err)
+		}
+		return nil
+	})
+	if w.err != nil {
+		return stats, w.err
+	}
+	// The entries after the last checkpoint are read again when the import
+	// resumes, so they're rolled back
+	if checkpointed && scanErr != nil {
+		return stats, scanErr
+	}
 
-			// Create a new batch
-			batch = &pgx.Batch{}
-			batchSize = 0
+	if err := w.flush(); err != nil {
+		return stats, err
+	}
+	if scanErr != nil {
+		// Report read errors after the entries parsed so far have been saved
+		w.count(&stats)
+		if err := tx.Commit(ctx); err != nil {
+			return stats, fmt.Errorf("failed to commit entries: %w", err)
 		}
+		return stats, scanErr
+	}
+	if err := w.insertProxies(); err != nil {
+		return stats, err
 	}
+	w.count(&stats)
 
-	// Execute remaining entries in the final batch
-	if batchSize > 0 {
-		br := conn.Conn().SendBatch(ctx, batch)
-		_, err := br.Exec()
+	stats.SHA256 = hasher.sum()
+	if !checkpointed {
+		copyOf, err := findDuplicateContent(ctx, tx, stats.SHA256, sourceName)
 		if err != nil {
-			_ = br.Close()
-			return entryCount - batchSize, fmt.Errorf("final batch execution failed: %w", err)
+			return stats, err
+		}
+		if copyOf != "" {
+			log.Printf("Imported %s with the same content as %s, it could only be hashed while importing it", sourceName, copyOf)
 		}
-		_ = br.Close()
 	}
 
-	// Check for scanner errors
-	if err := scanner.Err(); err != nil {
-		return entryCount, fmt.Errorf("error reading file: %w", err)
+	// Recorded with the last batch, so a failed commit resumes from the checkpoint
+	if err := recordProcessedFile(ctx, tx, sourceName, stats, time.Since(start), runID); err != nil {
+		return stats, fmt.Errorf("failed to record processed file: %w", err)
 	}
-
-	return entryCount, nil
+	if checkpointed {
+		if err := deleteFileProgress(ctx, tx, sourceName); err != nil {
+			return stats, fmt.Errorf("failed to delete the import checkpoint: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return stats, fmt.Errorf("failed to commit entries: %w", err)
+	}
+	return stats, nil
 }
 
-// splitLogLine splits a log line into its components
-// Handles format like "https://auralia.cloud/login:Bengalar:Robert2024!"
-func splitLogLine(line string) []string { // Handle Android scheme URLs (e.g., android://base64@com.app/:username:password)
-	reAndroid := regexp.MustCompile(`^(android://[^@]+@[^/:]+(?:/[^:]*)?):([^:]+):(.+)$`)
+// importInTransaction imports r with ATOMIC_IMPORT, recording the input in
+// the transaction of its entries so either both are stored or nothing is. A
+// read error rolls back the entries parsed until then, and a copy of a
+// processed file is only recorded. The transaction stays open for the whole
+// input, so the entries of a huge file are invisible until the end and
+// vacuum can't clean up behind it meanwhile.
+func importInTransaction(ctx context.Context, cfg Config, r io.Reader, sourceName string, opts parser.Options, runID *int) (ParseStats, error) {
+	var stats ParseStats
+	start := time.Now()
 
-	matchesAndroid := reAndroid.FindStringSubmatch(line)
-	if len(matchesAndroid) >= 4 {
-		return []string{
-			matchesAndroid[1], // Android URL (group 1)
-			matchesAndroid[2], // Username (group 2)
-			matchesAndroid[3], // Password (group 3)
-		}
+	conn, tx, insertName, err := beginImport(ctx, cfg)
+	if err != nil {
+		return stats, err
 	}
+	defer conn.Release()
+	defer tx.Rollback(ctx)
 
-	// Special case for URLs with port numbers (e.g., https://example.com:8080:username:password)
-	reWithPort := regexp.MustCompile(`^(https?://[^/:]+(?::\d+)?(?:/[^:]*)?):([^:]+):(.+)$`)
+	sink := &postgresSink{ctx: ctx, tx: tx, insertName: insertName}
+	w := newImportWriter(ctx, cfg, tx, sink, r, sourceName, runID, ParseStats{})
 
-	matches := reWithPort.FindStringSubmatch(line)
-	if len(matches) >= 4 {
-		//log.Printf("Debug - URL: %s, Username: %s, Password: %s", matches[1], matches[2], matches[3])
-		// We have a match with the expected format
-		return []string{
-			matches[1], // URL (group 1)
-			matches[2], // Username (group 2)
-			matches[3], // Password (group 3)
-		}
+	// Hash the content as it's read so the file is only read once
+	hasher := sha256.New()
+	scanErr := w.scan(io.TeeReader(r, hasher), cfg, opts, &stats, nil)
+	if w.err != nil {
+		return stats, w.err
+	}
+	// Roll back the entries sent so far rather than keep part of the input
+	if scanErr != nil {
+		return stats, fmt.Errorf("nothing imported from %s: %w", sourceName, scanErr)
 	}
 
-	// Try a more flexible pattern that handles cases where the URL may contain colons
-	// This pattern assumes the URL is everything between http(s):// and the last slash before username
-	reAlternate := regexp.MustCompile(`^(https?://[^/]+(?:/[^:]*)?):([^:]+):(.+)$`)
+	if err := w.flush(); err != nil {
+		return stats, err
+	}
+	if err := w.insertProxies(); err != nil {
+		return stats, err
+	}
 
-	matches = reAlternate.FindStringSubmatch(line)
-	if len(matches) >= 4 {
-		return []string{
-			matches[1], // URL with path (group 1)
-			matches[2], // Username (group 2)
-			matches[3], // Password (group 3)
+	// Skip files whose content was already imported under another name
+	stats.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	stats.DuplicateOf, err = findDuplicateContent(ctx, tx, stats.SHA256, sourceName)
+	if err != nil {
+		return stats, err
+	}
+	if stats.DuplicateOf != "" {
+		// Nothing is inserted, the copy is recorded so it's recognized later
+		tx.Rollback(ctx)
+		if err := recordProcessedFile(ctx, dbPool, sourceName, stats, time.Since(start), runID); err != nil {
+			log.Printf("Warning: Failed to record processed file in database: %v", err)
 		}
+		return stats, nil
 	}
-	// If regex attempts fail, fall back to simpler methods
 
-	// Try to detect http/https/android URLs with colons and split appropriately
-	if strings.HasPrefix(strings.ToLower(line), "http") || strings.HasPrefix(strings.ToLower(line), "android") {
-		// Find position of "://" which indicates protocol separator
-		protoIdx := strings.Index(line, "://")
-		if protoIdx > 0 {
-			// Find the first colon after the protocol separator
-			protocol := line[:protoIdx+3] // includes "://"
-			remainder := line[protoIdx+3:]
+	w.count(&stats)
+	if err := recordProcessedFile(ctx, tx, sourceName, stats, time.Since(start), runID); err != nil {
+		return stats, fmt.Errorf("failed to record processed file: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return stats, fmt.Errorf("failed to commit entries: %w", err)
+	}
+	return stats, nil
+}
 
-			// For android URLs, handle the @ symbol
-			if strings.HasPrefix(strings.ToLower(line), "android") {
-				atIdx := strings.Index(remainder, "@")
-				if atIdx > 0 {
-					baseToken := remainder[:atIdx+1] // includes "@"
-					remainder = remainder[atIdx+1:]
-					protocol = protocol + baseToken
-				}
-			}
+// importToFile imports r into SINK_FILE without touching the database: the
+// input isn't recorded in processed_log_files nor compared with the processed
+// files, and proxy list lines aren't stored. The entries are only appended to the
+// sink file once r was read, so an import that fails leaves it untouched. A
+// read error keeps the entries parsed until then, unless ATOMIC_IMPORT is set.
+func importToFile(ctx context.Context, cfg Config, r io.Reader, sourceName string, opts parser.Options, runID *int) (ParseStats, error) {
+	var stats ParseStats
+	start := time.Now()
 
-			// Find the next two colons which should separate URL from username and password
-			parts := strings.SplitN(remainder, ":", 3)
+	sink, err := openFileSink(cfg.SinkFile)
+	if err != nil {
+		return stats, err
+	}
+	defer sink.Close()
+	// Proxies are stored in the database
+	cfg.ProxyIngestion = false
+	w := newImportWriter(ctx, cfg, nil, sink, r, sourceName, runID, ParseStats{})
 
-			if len(parts) >= 3 {
-				url := protocol + parts[0]
-				username := parts[1]
-				password := parts[2]
+	hasher := sha256.New()
+	scanErr := w.scan(io.TeeReader(r, hasher), cfg, opts, &stats, nil)
+	if w.err != nil {
+		return stats, w.err
+	}
+	if err := w.flush(); err != nil {
+		return stats, err
+	}
+	w.count(&stats)
 
-				return []string{url, username, password}
+	if scanErr != nil && cfg.AtomicImport {
+		stats.Inserted = 0
+		return stats, fmt.Errorf("nothing imported from %s: %w", sourceName, scanErr)
+	}
+	if err := sink.Publish(); err != nil {
+		return stats, fmt.Errorf("failed to publish entries: %w", err)
+	}
+	// Report read errors after the entries parsed so far have been saved
+	if scanErr != nil {
+		return stats, scanErr
+	}
+
+	stats.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	addRecentImport(sourceName, stats, time.Since(start))
+	return stats, nil
+}
+
+// beginImport acquires a connection for an import, prepares the insert used
+// by the Postgres sink on it and begins the import's transaction. It returns
+// the name of the prepared statement.
+func beginImport(ctx context.Context, cfg Config) (*pgxpool.Conn, pgx.Tx, string, error) {
+	// Create a prepared statement for better performance. With DEDUPE_SCOPE
+	// entries already in the database are dropped by the unique index.
+	insertName := "insert_entry_" + cfg.DedupeScope
+	insertSQL := "INSERT INTO entries (url, username, password, created, domain, run_id, line_no, source_file, content_id, kind, raw_line) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)" +
+		dedupeConflict(cfg.DedupeScope)
+
+	conn, err := dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to acquire database connection: %w", err)
+	}
+	if _, err := conn.Conn().Prepare(ctx, insertName, insertSQL); err != nil {
+		conn.Release()
+		return nil, nil, "", fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		conn.Release()
+		return nil, nil, "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return conn, tx, insertName, nil
+}
+
+// importWriter sends the entries parsed by an import to its sink in batches
+// of BATCH_SIZE, dropping credentials repeated within the file, and with
+// PROXY_INGESTION inserts proxy list lines into the proxies table in tx,
+// which is nil for SINK=file. committed counts the entries an interrupted
+// import committed before it resumed.
+type importWriter struct {
+	ctx        context.Context
+	tx         pgx.Tx
+	sink       Sink
+	seen       *entryCache
+	sourceName string
+	runID      *int
+	created    string
+	batchSize  int
+	committed  ParseStats
+
+	batch   []EntryDetail
+	proxies []parser.Proxy
+	// sent counts the entries written to the sink
+	sent int
+	// err is the write error that stopped the scan, if any
+	err error
+}
+
+func newImportWriter(ctx context.Context, cfg Config, tx pgx.Tx, sink Sink, r io.Reader, sourceName string, runID *int, committed ParseStats) *importWriter {
+	return &importWriter{
+		ctx:        ctx,
+		tx:         tx,
+		sink:       sink,
+		seen:       newEntryCache(cfg.DedupeCacheSize, cfg.DedupeScope != dedupeNone),
+		sourceName: sourceName,
+		runID:      runID,
+		created:    createdDate(r, sourceName, cfg.CreatedSource),
+		batchSize:  cfg.BatchSize,
+		committed:  committed,
+	}
+}
+
+// scan parses r and writes its entries, calling onBatch, unless it's nil,
+// after every full batch with where the batch's last entry ends. It returns
+// the error that stopped the parser; when writing failed it's also in w.err.
+func (w *importWriter) scan(r io.Reader, cfg Config, opts parser.Options, stats *ParseStats, onBatch func(end parser.Position) error) error {
+	if cfg.ProxyIngestion {
+		opts.OnProxy = func(proxy parser.Proxy) error {
+			w.proxies = append(w.proxies, proxy)
+			if len(w.proxies) < w.batchSize {
+				return nil
 			}
+			w.err = w.insertProxies()
+			return w.err
 		}
 	}
 
-	// Fallback to simple colon-separated splitting
-	parts := strings.Split(line, ":")
-	if len(parts) >= 3 {
-		// Simple case with exactly 3 parts
-		return []string{
-			strings.TrimSpace(parts[0]),  // URL
-			strings.TrimSpace(parts[1]),  // Username
-			strings.Join(parts[2:], ":"), // Password (may contain colons)
+	err := scanEntries(r, cfg, opts, stats, func(entry parser.Entry) error {
+		if w.seen.seen(entry) {
+			stats.SkippedInFileDuplicate++
+			return nil
+		}
+		w.batch = append(w.batch, newEntryDetail(entry, w.created, w.runID, w.sourceName))
+		if len(w.batch) < w.batchSize {
+			return nil
 		}
-	}
 
-	// Try simple space-based splitting as a last resort
-	parts = strings.Fields(line)
-	if len(parts) >= 3 {
-		return []string{
-			parts[0],                     // URL
-			parts[1],                     // Username
-			strings.Join(parts[2:], " "), // Password (may contain spaces)
+		if err := w.sink.Write(w.batch); err != nil {
+			w.err = fmt.Errorf("batch execution failed: %w", err)
+			return w.err
 		}
+		w.sent += len(w.batch)
+		w.batch = w.batch[:0]
+		if onBatch != nil {
+			if err := onBatch(entry.End); err != nil {
+				w.err = err
+				return err
+			}
+		}
+		reportProgress(w.ctx, w.entries())
+		return nil
+	})
+	// Logged once per file, logs with null bytes usually have them on every line
+	if stats.NullByteLines > 0 {
+		log.Printf("Removed null bytes from %d lines of %s", stats.NullByteLines, w.sourceName)
 	}
+	return err
+}
 
-	// Return whatever we have
-	return parts
+// flush writes the entries of the last, partial batch
+func (w *importWriter) flush() error {
+	if len(w.batch) == 0 {
+		return nil
+	}
+	if err := w.sink.Write(w.batch); err != nil {
+		return fmt.Errorf("final batch execution failed: %w", err)
+	}
+	w.sent += len(w.batch)
+	w.batch = w.batch[:0]
+	reportProgress(w.ctx, w.entries())
+	return nil
 }
 
-// sanitizeString removes null bytes and ensures valid UTF-8 characters
-func sanitizeString(input string) string {
-	// Check for null bytes
-	if strings.Contains(input, "\x00") {
-		// Log that we found null bytes (only once per execution to avoid spam)
-		log.Printf("Found and removed null bytes in input")
+// insertProxies inserts the proxies collected since the last call
+func (w *importWriter) insertProxies() error {
+	if len(w.proxies) == 0 {
+		return nil
+	}
+	if err := insertProxies(w.ctx, w.tx, w.proxies, w.created); err != nil {
+		return fmt.Errorf("failed to insert proxies: %w", err)
 	}
+	w.proxies = w.proxies[:0]
+	return nil
+}
 
-	// Remove null bytes which cause PostgreSQL UTF-8 encoding errors
-	sanitized := strings.ReplaceAll(input, "\x00", "")
+// entries returns the number of entries sent to the sink, counting those
+// committed before the import resumed
+func (w *importWriter) entries() int {
+	return w.committed.Inserted + w.committed.SkippedRejected + w.committed.SkippedDuplicate + w.sent
+}
 
-	// Handle other invalid UTF-8 sequences by replacing them
-	// This creates a new string with invalid UTF-8 sequences replaced
-	if !utf8.ValidString(sanitized) {
-		// Replace invalid UTF-8 sequences with the Unicode replacement character
-		var result []rune
-		for _, r := range sanitized {
-			if r == utf8.RuneError {
-				// Skip invalid runes
-				continue
-			}
-			result = append(result, r)
-		}
-		sanitized = string(result)
+// count sets the insert counts of stats from the sink
+func (w *importWriter) count(stats *ParseStats) {
+	inserted, rejected := w.sent, 0
+	if counter, ok := w.sink.(insertCounter); ok {
+		inserted, rejected = counter.Inserted(), counter.Rejected()
 	}
+	stats.Inserted = w.committed.Inserted + inserted
+	stats.SkippedRejected = w.committed.SkippedRejected + rejected
+	stats.SkippedDuplicate = w.committed.SkippedDuplicate + w.sent - inserted - rejected
+}
 
-	return sanitized
+// findDuplicateContent returns the name of a processed file other than
+// sourceName whose content has the SHA-256 hash, or "" when there's none
+func findDuplicateContent(ctx context.Context, db queryRower, hash, sourceName string) (string, error) {
+	var fileName string
+	err := db.QueryRow(ctx,
+		"SELECT filename FROM processed_log_files WHERE sha256 = $1 AND filename <> $2 LIMIT 1",
+		hash, sourceName).Scan(&fileName)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to check for duplicate content: %w", err)
+	}
+	return fileName, nil
 }
 
-// dropInvalidUtf8 removes invalid UTF-8 sequences from a byte slice
-func dropInvalidUtf8(data []byte) []byte {
-	if utf8.Valid(data) {
-		return data
+// newEntryDetail returns the record of a parsed entry written to the sink
+func newEntryDetail(entry parser.Entry, created string, runID *int, sourceName string) EntryDetail {
+	line := entry.Line
+	content := contentID(entry.URL, entry.Username, entry.Password)
+	detail := EntryDetail{
+		Entry: Entry{
+			URL:       entry.URL,
+			User:      entry.Username,
+			Pass:      entry.Password,
+			Created:   created,
+			Domain:    parser.ExtractDomain(entry.URL),
+			Tags:      []string{},
+			RunID:     runID,
+			LineNo:    &line,
+			ContentID: &content,
+			Kind:      classify(entry.URL, entry.Username),
+		},
+		SourceFile: &sourceName,
 	}
+	if entry.Raw != nil {
+		raw := string(entry.Raw)
+		detail.RawLine = &raw
+	}
+	return detail
+}
 
-	result := make([]byte, 0, len(data))
-	for len(data) > 0 {
-		r, size := utf8.DecodeRune(data)
-		if r != utf8.RuneError {
-			result = append(result, data[:size]...)
+// sendEntryBatch sends a batch of inserts and returns the number of rows
+// actually inserted, which is lower than the batch size when entries were
+// dropped as duplicates
+func sendEntryBatch(ctx context.Context, tx pgx.Tx, batch *pgx.Batch) (int, error) {
+	br := tx.SendBatch(ctx, batch)
+	defer br.Close()
+
+	inserted := 0
+	for i := 0; i < batch.Len(); i++ {
+		tag, err := br.Exec()
+		if err != nil {
+			return inserted, err
 		}
-		data = data[size:]
+		inserted += int(tag.RowsAffected())
+	}
+	return inserted, br.Close()
+}
+
+// dryRunSampleSize is the number of parsed entries returned by a dry run
+const dryRunSampleSize = 20
+
+// dryRunLogFile parses a log file without touching the database and reports
+// how many lines would be imported, the parse statistics, and a sample of the
+// first parsed entries
+func dryRunLogFile(filePath string, opts parser.Options) (int, ParseStats, []Entry, error) {
+	var stats ParseStats
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, stats, nil, fmt.Errorf("failed to open file: %w", err)
 	}
-	return result
+	defer file.Close()
+
+	cfg := currentConfig()
+	if opts.Rule == nil {
+		opts.Rule = matchParseRule(cfg.ParseRules, filepath.Base(filePath))
+	}
+	if cfg.ProxyIngestion {
+		opts.OnProxy = func(parser.Proxy) error { return nil }
+	}
+
+	parsed := 0
+	sample := []Entry{}
+	err = scanEntries(file, cfg, opts, &stats, func(entry parser.Entry) error {
+		parsed++
+		if len(sample) < dryRunSampleSize {
+			sample = append(sample, Entry{URL: entry.URL, User: entry.Username, Pass: entry.Password})
+		}
+		return nil
+	})
+
+	return parsed, stats, sample, err
 }
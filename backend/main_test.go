@@ -0,0 +1,1000 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"hello-world/backend/parser"
+)
+
+// setupTestDB connects to the database in TEST_DATABASE_URL and empties the
+// tables. Tests that need PostgreSQL are skipped when it isn't set.
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set, skipping database test")
+	}
+
+	setConfig(Config{
+		DatabaseURL: databaseURL,
+		LogDir:      t.TempDir(),
+		BatchSize:   defaultBatchSize,
+
+		DBMaxConns:        defaultDBMaxConns,
+		DBMinConns:        defaultDBMinConns,
+		DBMaxConnLifetime: defaultDBMaxConnLifetime,
+		DBMaxConnIdleTime: defaultDBMaxConnIdleTime,
+		DBMigrate:         true,
+		RequestTimeout:    defaultRequestTimeout,
+
+		AdminUser:     testAdminUser,
+		AdminPassword: testAdminPassword,
+	})
+	if err := initDB(); err != nil {
+		t.Fatalf("initDB failed: %v", err)
+	}
+
+	_, err := dbPool.Exec(context.Background(), "TRUNCATE entries, processed_log_files, file_progress, import_runs, proxies, audit_log RESTART IDENTITY")
+	if err != nil {
+		t.Fatalf("failed to reset tables: %v", err)
+	}
+
+	t.Cleanup(func() {
+		dbPool.Close()
+		dbPool = nil
+		setConfig(Config{})
+	})
+}
+
+// insertTestEntries adds entries directly to the database, with the content
+// ID an import would give them
+func insertTestEntries(t *testing.T, entries ...Entry) {
+	t.Helper()
+
+	for _, entry := range entries {
+		if entry.Created == "" {
+			entry.Created = "2025-05-20"
+		}
+		_, err := dbPool.Exec(context.Background(),
+			"INSERT INTO entries (url, username, password, created, domain, content_id) VALUES ($1, $2, $3, $4, $5, $6)",
+			entry.URL, entry.User, entry.Pass, entry.Created, parser.ExtractDomain(entry.URL), contentID(entry.URL, entry.User, entry.Pass))
+		if err != nil {
+			t.Fatalf("failed to insert test entry: %v", err)
+		}
+	}
+}
+
+// testConfig allows slow database-backed handlers more than Fiber's default 1s
+var testConfig = fiber.TestConfig{Timeout: 30 * time.Second, FailOnTimeout: true}
+
+// getJSON performs a GET request against the app and decodes the JSON response
+func getJSON(t *testing.T, path string, out any) int {
+	t.Helper()
+	return doJSON(t, httptest.NewRequest("GET", path, nil), out)
+}
+
+// Admin credentials set by setupTestDB
+const (
+	testAdminUser     = "admin"
+	testAdminPassword = "s3cret"
+)
+
+// adminRequest returns a request to an /api/admin route with the test admin credentials
+func adminRequest(method, path string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(testAdminUser, testAdminPassword)
+	return req
+}
+
+// getAdminJSON is getJSON for the /api/admin routes
+func getAdminJSON(t *testing.T, path string, out any) int {
+	t.Helper()
+	return doJSON(t, adminRequest("GET", path, nil), out)
+}
+
+// doJSON sends req to the app, decodes the JSON response into out and
+// returns the status code
+func doJSON(t *testing.T, req *http.Request, out any) int {
+	t.Helper()
+
+	path := req.URL.RequestURI()
+	resp, err := newApp().Test(req, testConfig)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", req.Method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatalf("failed to decode response from %s: %v", path, err)
+	}
+	return resp.StatusCode
+}
+
+func TestSearchByDomain(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://paypal.com/login", User: "alice", Pass: "one"},
+		Entry{URL: "https://www.paypal.com/signin", User: "bob", Pass: "two"},
+		Entry{URL: "https://accounts.paypal.com", User: "carol", Pass: "three"},
+		Entry{URL: "https://paypalphish.com/login", User: "mallory", Pass: "four"},
+		Entry{URL: "https://notpaypal.org", User: "trudy", Pass: "five"},
+	)
+
+	tests := []struct {
+		path  string
+		users []string
+	}{
+		{"/api/search?domain=paypal.com", []string{"bob", "alice"}},
+		{"/api/search?domain=paypal.com&includeSubdomains=true", []string{"carol", "bob", "alice"}},
+		{"/api/search?domain=paypalphish.com", []string{"mallory"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			var result PaginationResponse
+			if status := getJSON(t, tt.path, &result); status != 200 {
+				t.Fatalf("status = %d, want 200", status)
+			}
+
+			if result.Total != len(tt.users) {
+				t.Errorf("total = %d, want %d", result.Total, len(tt.users))
+			}
+
+			var users []string
+			for _, item := range result.Items {
+				users = append(users, item.User)
+			}
+			if len(users) != len(tt.users) {
+				t.Fatalf("users = %v, want %v", users, tt.users)
+			}
+			for i := range users {
+				if users[i] != tt.users[i] {
+					t.Errorf("users = %v, want %v", users, tt.users)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestDuplicatesBatchedRemoval(t *testing.T) {
+	setupTestDB(t)
+
+	// Delete in small batches so the test needs several statements
+	defer func(size int) { duplicateDeleteBatchSize = size }(duplicateDeleteBatchSize)
+	duplicateDeleteBatchSize = 7
+
+	// 50 copies of one credential and 20 of another, plus a unique entry
+	_, err := dbPool.Exec(context.Background(), `
+		INSERT INTO entries (url, username, password, created, domain)
+		SELECT 'https://a.com', 'alice', 'one', '2025-05-20', 'a.com' FROM generate_series(1, 50);
+		INSERT INTO entries (url, username, password, created, domain)
+		SELECT 'https://b.com', 'bob', 'two', '2025-05-20', 'b.com' FROM generate_series(1, 20);
+		INSERT INTO entries (url, username, password, created, domain)
+		VALUES ('https://c.com', 'carol', 'three', '2025-05-20', 'c.com');
+	`)
+	if err != nil {
+		t.Fatalf("failed to insert duplicates: %v", err)
+	}
+	// Duplicates are found by content ID, set at startup for rows without one
+	if err := backfillEntryColumns(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var report DuplicatesResponse
+	getJSON(t, "/api/duplicates?pageSize=5&page=2", &report)
+	if report.DuplicatesFound != 68 || report.Total != 68 {
+		t.Errorf("duplicatesFound = %d, total = %d, want 68", report.DuplicatesFound, report.Total)
+	}
+	if len(report.Items) != 5 || report.Page != 2 || report.TotalPages != 14 {
+		t.Errorf("got %d items on page %d of %d, want 5 on page 2 of 14", len(report.Items), report.Page, report.TotalPages)
+	}
+
+	var filtered DuplicatesResponse
+	getJSON(t, "/api/duplicates?domain=b.com", &filtered)
+	if filtered.DuplicatesFound != 19 {
+		t.Errorf("duplicatesFound for b.com = %d, want 19", filtered.DuplicatesFound)
+	}
+
+	var removal struct {
+		DuplicatesFound   int     `json:"duplicatesFound"`
+		DuplicatesRemoved int     `json:"duplicatesRemoved"`
+		Duplicates        []Entry `json:"duplicates"`
+	}
+	getJSON(t, "/api/duplicates?remove=true", &removal)
+	if removal.DuplicatesFound != 68 || removal.DuplicatesRemoved != 68 || len(removal.Duplicates) != 68 {
+		t.Errorf("found %d, removed %d, returned %d duplicates, want 68", removal.DuplicatesFound, removal.DuplicatesRemoved, len(removal.Duplicates))
+	}
+
+	var remaining int
+	if err := dbPool.QueryRow(context.Background(), "SELECT COUNT(*) FROM entries").Scan(&remaining); err != nil {
+		t.Fatal(err)
+	}
+	if remaining != 3 {
+		t.Errorf("remaining entries = %d, want 3", remaining)
+	}
+}
+
+func TestDuplicatesRemovalManyIDs(t *testing.T) {
+	setupTestDB(t)
+
+	// More duplicate IDs than fit in a single statement's parameters
+	const copies = 70001
+	_, err := dbPool.Exec(context.Background(), `
+		INSERT INTO entries (url, username, password, created, domain, content_id)
+		SELECT 'https://a.com', 'alice', 'one', '2025-05-20', 'a.com', $1 FROM generate_series(1, 70001)
+	`, contentID("https://a.com", "alice", "one"))
+	if err != nil {
+		t.Fatalf("failed to insert duplicates: %v", err)
+	}
+
+	var removal struct {
+		DuplicatesRemoved int     `json:"duplicatesRemoved"`
+		Duplicates        []Entry `json:"duplicates"`
+	}
+	getJSON(t, "/api/duplicates?remove=true", &removal)
+	if removal.DuplicatesRemoved != copies-1 {
+		t.Errorf("removed %d duplicates, want %d", removal.DuplicatesRemoved, copies-1)
+	}
+	// Only a sample of the removed rows is returned
+	if len(removal.Duplicates) != maxPageSize {
+		t.Errorf("returned %d removed duplicates, want %d", len(removal.Duplicates), maxPageSize)
+	}
+
+	var remaining int
+	if err := dbPool.QueryRow(context.Background(), "SELECT COUNT(*) FROM entries").Scan(&remaining); err != nil {
+		t.Fatal(err)
+	}
+	if remaining != 1 {
+		t.Errorf("remaining entries = %d, want 1", remaining)
+	}
+}
+
+func TestDuplicatesKeys(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://a.com", User: "alice", Pass: "one"},
+		Entry{URL: "https://a.com", User: "alice", Pass: "two"},
+		Entry{URL: "https://a.com/login", User: "alice", Pass: "one"},
+		Entry{URL: "https://b.com", User: "alice", Pass: "one"},
+		Entry{URL: "https://a.com", User: "alice", Pass: "one"},
+		// Case variants of the URL and username are duplicates too
+		Entry{URL: "https://A.com", User: "Alice", Pass: "one"},
+		Entry{URL: "https://a.com", User: "alice", Pass: "ONE"},
+	)
+
+	tests := []struct {
+		key string
+		ids []int
+	}{
+		{"url_user_pass", []int{5, 6}},
+		{"domain_user", []int{2, 3, 5, 6, 7}},
+		{"user_pass", []int{3, 4, 5, 6}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			var report DuplicatesResponse
+			if status := getJSON(t, "/api/duplicates?key="+tt.key, &report); status != 200 {
+				t.Fatalf("status = %d, want 200", status)
+			}
+
+			ids := []int{}
+			for _, item := range report.Items {
+				ids = append(ids, item.ID)
+			}
+			slices.Sort(ids)
+			if !slices.Equal(ids, tt.ids) {
+				t.Errorf("duplicate ids = %v, want %v", ids, tt.ids)
+			}
+		})
+	}
+
+	var errResp map[string]any
+	if status := getJSON(t, "/api/duplicates?key=password", &errResp); status != 400 {
+		t.Errorf("status for unknown key = %d, want 400", status)
+	}
+}
+
+func TestEntriesNDJSON(t *testing.T) {
+	setupTestDB(t)
+
+	const count = 2500
+	_, err := dbPool.Exec(context.Background(), `
+		INSERT INTO entries (url, username, password, created, domain)
+		SELECT 'https://a.com', 'user' || i, 'pass', '2025-05-20', 'a.com' FROM generate_series(1, 2500) AS i
+	`)
+	if err != nil {
+		t.Fatalf("failed to insert entries: %v", err)
+	}
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/api/entries?format=ndjson", nil),
+		func() *http.Request {
+			r := httptest.NewRequest("GET", "/api/entries", nil)
+			r.Header.Set("Accept", "application/x-ndjson")
+			return r
+		}(),
+	} {
+		resp, err := newApp().Test(req, testConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("Content-Type = %q, want application/x-ndjson", ct)
+		}
+
+		lines := 0
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var entry Entry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				t.Fatalf("line %d is not an entry: %v", lines+1, err)
+			}
+			lines++
+		}
+		resp.Body.Close()
+
+		if lines != count {
+			t.Errorf("got %d lines, want %d", lines, count)
+		}
+	}
+}
+
+func TestResponseCompression(t *testing.T) {
+	setupTestDB(t)
+
+	_, err := dbPool.Exec(context.Background(), `
+		INSERT INTO entries (url, username, password, created, domain)
+		SELECT 'https://a.com', 'user' || i, 'pass', '2025-05-20', 'a.com' FROM generate_series(1, 1500) AS i
+	`)
+	if err != nil {
+		t.Fatalf("failed to insert entries: %v", err)
+	}
+
+	get := func(path string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := newApp().Test(req, testConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if enc := resp.Header.Get("Content-Encoding"); enc != "gzip" {
+			t.Fatalf("Content-Encoding of %s = %q, want gzip", path, enc)
+		}
+		return resp
+	}
+
+	resp := get("/api/entries?pageSize=100")
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var page PaginationResponse
+	if err := json.NewDecoder(zr).Decode(&page); err != nil {
+		t.Fatalf("failed to decode compressed page: %v", err)
+	}
+	resp.Body.Close()
+	if page.Total != 1500 || len(page.Items) != 100 {
+		t.Errorf("page has %d of %d entries, want 100 of 1500", len(page.Items), page.Total)
+	}
+
+	// The streamed export decompresses to every line
+	resp = get("/api/entries?format=ndjson")
+	zr, err = gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := 0
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		lines++
+	}
+	resp.Body.Close()
+	if lines != 1500 {
+		t.Errorf("got %d compressed lines, want 1500", lines)
+	}
+}
+
+func TestResponseCompressionThreshold(t *testing.T) {
+	large := strings.Repeat("credential ", 100)
+
+	for _, tt := range []struct {
+		level    string
+		path     string
+		encoding string
+	}{
+		{compressLevelDefault, "/api/test/large", "gzip"},
+		// Small bodies aren't worth compressing
+		{compressLevelDefault, "/api/test/small", ""},
+		{compressLevelOff, "/api/test/large", ""},
+	} {
+		setConfig(Config{RequestTimeout: time.Minute, CompressLevel: tt.level})
+
+		app := newApp()
+		app.Get("/api/test/large", func(c fiber.Ctx) error {
+			return c.JSON(fiber.Map{"data": large})
+		})
+		app.Get("/api/test/small", func(c fiber.Ctx) error {
+			return c.JSON(fiber.Map{"data": "small"})
+		})
+
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := app.Test(req, testConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if enc := resp.Header.Get("Content-Encoding"); enc != tt.encoding {
+			t.Errorf("Content-Encoding of %s with level %s = %q, want %q", tt.path, tt.level, enc, tt.encoding)
+		}
+	}
+	setConfig(Config{})
+}
+
+func TestRequireDatabase(t *testing.T) {
+	setConfig(Config{RequestTimeout: time.Minute})
+	noDatabase = true
+	defer func() {
+		noDatabase = false
+		setConfig(Config{})
+	}()
+
+	for _, tt := range []struct {
+		path   string
+		status int
+	}{
+		{"/api/entries", fiber.StatusServiceUnavailable},
+		{"/api/admin/audit", fiber.StatusServiceUnavailable},
+		// Served from memory
+		{"/api/recent", fiber.StatusOK},
+		{"/api/hello", fiber.StatusOK},
+	} {
+		resp, err := newApp().Test(httptest.NewRequest("GET", tt.path, nil), testConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s without a database = %d, want %d", tt.path, resp.StatusCode, tt.status)
+		}
+	}
+}
+
+func TestImportRuns(t *testing.T) {
+	setupTestDB(t)
+
+	logDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(logDir, "run.txt"), []byte("https://a.com:user:pass\nhttps://b.com:user:pass\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ParseLogDirectory(logDir, 0); err != nil {
+		t.Fatalf("ParseLogDirectory failed: %v", err)
+	}
+
+	var result struct {
+		Imports []ImportRun `json:"imports"`
+		Count   int         `json:"count"`
+	}
+	if status := getJSON(t, "/api/imports", &result); status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+
+	if result.Count != 1 {
+		t.Fatalf("count = %d, want 1", result.Count)
+	}
+	run := result.Imports[0]
+	if run.Status != importRunCompleted || run.FilesProcessed != 1 || run.EntriesAdded != 2 || run.FinishedAt == nil {
+		t.Errorf("run = %+v, want a completed run with 1 file and 2 entries", run)
+	}
+
+	var runID *int
+	err := dbPool.QueryRow(context.Background(),
+		"SELECT run_id FROM processed_log_files WHERE filename = 'run.txt'").Scan(&runID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runID == nil || *runID != run.ID {
+		t.Errorf("processed file run_id = %v, want %d", runID, run.ID)
+	}
+}
+
+func TestPasswordStats(t *testing.T) {
+	setupTestDB(t)
+
+	insertTestEntries(t,
+		Entry{URL: "https://a.com", User: "u1", Pass: "123456"},
+		Entry{URL: "https://b.com", User: "u2", Pass: "123456"},
+		Entry{URL: "https://c.com", User: "u3", Pass: "password1"},
+		Entry{URL: "https://d.com", User: "u4", Pass: "abc"},
+	)
+
+	var result struct {
+		Total          int     `json:"total"`
+		AverageLength  float64 `json:"averageLength"`
+		MedianLength   float64 `json:"medianLength"`
+		NumericPercent float64 `json:"numericPercent"`
+		LengthBuckets  []struct {
+			Bucket string `json:"bucket"`
+			Count  int    `json:"count"`
+		} `json:"lengthBuckets"`
+		TopPasswords []struct {
+			Password string `json:"password"`
+			Count    int    `json:"count"`
+		} `json:"topPasswords"`
+	}
+	if status := getJSON(t, "/api/stats/passwords?top=1", &result); status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+
+	if result.Total != 4 || result.AverageLength != 6 || result.MedianLength != 6 || result.NumericPercent != 50 {
+		t.Errorf("aggregates = %+v, want total 4, average 6, median 6 and 50%% numeric", result)
+	}
+
+	buckets := map[string]int{}
+	for _, b := range result.LengthBuckets {
+		buckets[b.Bucket] = b.Count
+	}
+	if buckets["0-5"] != 1 || buckets["6-7"] != 2 || buckets["8-11"] != 1 {
+		t.Errorf("length buckets = %v", result.LengthBuckets)
+	}
+
+	if len(result.TopPasswords) != 1 || result.TopPasswords[0].Password != "123456" || result.TopPasswords[0].Count != 2 {
+		t.Errorf("top passwords = %+v, want only 123456 with 2 uses", result.TopPasswords)
+	}
+}
+
+func TestInitDBSeeding(t *testing.T) {
+	setupTestDB(t)
+
+	reinit := func(seed bool) int {
+		t.Helper()
+
+		cfg := currentConfig()
+		cfg.DBSeed = seed
+		setConfig(cfg)
+
+		dbPool.Close()
+		if err := initDB(); err != nil {
+			t.Fatalf("initDB failed: %v", err)
+		}
+		return countEntries(t)
+	}
+
+	if got := reinit(false); got != 0 {
+		t.Errorf("entries with seeding disabled = %d, want 0", got)
+	}
+	if got := reinit(true); got != 5 {
+		t.Errorf("entries with seeding enabled = %d, want 5", got)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://a.com", User: "alice", Pass: "one"},
+		Entry{URL: "https://b.com", User: "bob", Pass: "two"},
+	)
+	if err := recordProcessedFile(context.Background(), dbPool, "dump.txt", ParseStats{Inserted: 2}, time.Second, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := newLogWatcher(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	w.processedFiles["dump.txt"] = true
+	logWatcher = w
+	defer func() { logWatcher = nil }()
+
+	purge := func(body string) (int, map[string]any) {
+		t.Helper()
+
+		resp, err := newApp().Test(adminRequest("POST", "/api/admin/purge", strings.NewReader(body)), testConfig)
+		if err != nil {
+			t.Fatalf("POST /api/admin/purge failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var result map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp.StatusCode, result
+	}
+
+	// Anything but the exact confirmation token is refused
+	for _, body := range []string{"", `{}`, `{"confirm": "delete-all"}`, `{"confirm": true}`} {
+		if status, _ := purge(body); status != http.StatusBadRequest {
+			t.Errorf("purge with %q: status = %d, want %d", body, status, http.StatusBadRequest)
+		}
+	}
+	if got := countEntries(t); got != 2 {
+		t.Fatalf("entries after refused purges = %d, want 2", got)
+	}
+
+	status, result := purge(`{"confirm": "DELETE-ALL"}`)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+	if result["entriesRemoved"] != float64(2) || result["processedFilesRemoved"] != float64(1) {
+		t.Errorf("result = %v, want 2 entries and 1 processed file removed", result)
+	}
+
+	if got := countEntries(t); got != 0 {
+		t.Errorf("entries after purge = %d, want 0", got)
+	}
+	if len(w.processedFiles) != 0 {
+		t.Errorf("watcher still remembers %d processed files", len(w.processedFiles))
+	}
+}
+
+func TestRandomEntries(t *testing.T) {
+	setupTestDB(t)
+	for i := 0; i < 5; i++ {
+		insertTestEntries(t,
+			Entry{URL: "https://paypal.com/login", User: fmt.Sprintf("user%d", i), Pass: "one"},
+			Entry{URL: "https://github.com/login", User: fmt.Sprintf("dev%d", i), Pass: "two"},
+		)
+	}
+
+	tests := []struct {
+		path   string
+		count  int
+		domain string
+	}{
+		{"/api/entries/random?count=3", 3, ""},
+		{"/api/entries/random?count=1000", 10, ""},
+		{"/api/entries/random?domain=paypal.com", 5, "paypal.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			var result struct {
+				Items []Entry `json:"items"`
+				Count int     `json:"count"`
+			}
+			if status := getJSON(t, tt.path, &result); status != 200 {
+				t.Fatalf("status = %d, want 200", status)
+			}
+			if result.Count != tt.count || len(result.Items) != tt.count {
+				t.Errorf("count = %d with %d items, want %d", result.Count, len(result.Items), tt.count)
+			}
+			for _, item := range result.Items {
+				if tt.domain != "" && item.Domain != tt.domain {
+					t.Errorf("entry %d has domain %q, want %q", item.ID, item.Domain, tt.domain)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestTimeoutDeadline(t *testing.T) {
+	setConfig(Config{RequestTimeout: time.Minute})
+	defer setConfig(Config{})
+
+	app := newApp()
+	app.Get("/api/test/deadline", func(c fiber.Ctx) error {
+		deadline, ok := c.Context().Deadline()
+		if !ok {
+			return c.SendString("none")
+		}
+		return c.SendString(time.Until(deadline).Round(time.Minute).String())
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/test/deadline", nil), testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "1m0s" {
+		t.Errorf("request deadline in %s, want 1m0s", body)
+	}
+}
+
+func TestRequestTimeoutCancelsQuery(t *testing.T) {
+	setupTestDB(t)
+
+	cfg := currentConfig()
+	cfg.RequestTimeout = 200 * time.Millisecond
+	setConfig(cfg)
+
+	app := newApp()
+	app.Get("/api/test/slow", func(c fiber.Ctx) error {
+		if _, err := dbPool.Exec(c.Context(), "SELECT pg_sleep(10)"); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+		}
+		return c.SendString("finished")
+	})
+
+	start := time.Now()
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/test/slow", nil), testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("slow query ran for %s after the request timed out", elapsed)
+	}
+
+	// The connection is released and usable again
+	if got := countEntries(t); got != 0 {
+		t.Errorf("entries = %d, want 0", got)
+	}
+}
+
+func TestReprocessAll(t *testing.T) {
+	setupTestDB(t)
+
+	logDir := currentConfig().LogDir
+	if err := os.WriteFile(filepath.Join(logDir, "dump.txt"), []byte("https://a.com:user:pass\nhttps://b.com:user:pass\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ParseLogDirectory(logDir, 0); err != nil {
+		t.Fatalf("ParseLogDirectory failed: %v", err)
+	}
+	// An entry that doesn't come from the log files is dropped by reprocessing
+	insertTestEntries(t, Entry{URL: "https://stale.com", User: "old", Pass: "parse"})
+
+	reprocess := func(body string) (int, map[string]any) {
+		t.Helper()
+
+		resp, err := newApp().Test(adminRequest("POST", "/api/admin/reprocess-all", strings.NewReader(body)), testConfig)
+		if err != nil {
+			t.Fatalf("POST /api/admin/reprocess-all failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var result map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp.StatusCode, result
+	}
+
+	if status, _ := reprocess(`{"confirm": "yes"}`); status != http.StatusBadRequest {
+		t.Errorf("status without confirmation = %d, want %d", status, http.StatusBadRequest)
+	}
+
+	status, result := reprocess(`{"confirm": "DELETE-ALL"}`)
+	if status != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", status, http.StatusAccepted)
+	}
+	importID, ok := result["importId"].(float64)
+	if !ok {
+		t.Fatalf("response has no importId: %v", result)
+	}
+
+	// Poll the import run until it finishes
+	var runStatus string
+	var entriesAdded int
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		err := dbPool.QueryRow(context.Background(),
+			"SELECT status, entries_added FROM import_runs WHERE id = $1", int(importID)).Scan(&runStatus, &entriesAdded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runStatus != importRunRunning {
+			break
+		}
+	}
+
+	if runStatus != importRunCompleted || entriesAdded != 2 {
+		t.Errorf("import run status = %s with %d entries, want %s with 2", runStatus, entriesAdded, importRunCompleted)
+	}
+	if got := countEntries(t); got != 2 {
+		t.Errorf("entries after reprocessing = %d, want 2", got)
+	}
+}
+
+func TestEmailDomainStats(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://a.com", User: "alice@gmail.com", Pass: "one"},
+		Entry{URL: "https://b.com", User: "bob@Gmail.com", Pass: "two"},
+		Entry{URL: "https://c.com", User: "carol@hotmail.com", Pass: "three"},
+		Entry{URL: "https://d.com", User: "dave@yahoo.com", Pass: "four"},
+		Entry{URL: "https://e.com", User: "erin", Pass: "five"},
+		Entry{URL: "https://f.com", User: "not@an email", Pass: "six"},
+	)
+
+	var result struct {
+		TotalEmails  int `json:"totalEmails"`
+		EmailDomains []struct {
+			Domain  string  `json:"domain"`
+			Count   int     `json:"count"`
+			Percent float64 `json:"percent"`
+		} `json:"emailDomains"`
+	}
+	if status := getJSON(t, "/api/stats/email-domains?limit=2", &result); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+
+	if result.TotalEmails != 4 {
+		t.Errorf("totalEmails = %d, want 4", result.TotalEmails)
+	}
+	if len(result.EmailDomains) != 2 {
+		t.Fatalf("got %d domains, want 2", len(result.EmailDomains))
+	}
+	if d := result.EmailDomains[0]; d.Domain != "gmail.com" || d.Count != 2 || d.Percent != 50 {
+		t.Errorf("top domain = %+v, want gmail.com with 2 (50%%)", d)
+	}
+	if d := result.EmailDomains[1]; d.Domain != "hotmail.com" || d.Count != 1 {
+		t.Errorf("second domain = %+v, want hotmail.com with 1", d)
+	}
+}
+
+func TestTimelineStats(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://a.com", User: "alice", Pass: "one", Created: "2025-05-19"},
+		Entry{URL: "https://b.com", User: "bob", Pass: "two", Created: "2025-05-19"},
+		Entry{URL: "https://c.com", User: "carol", Pass: "three", Created: "2025-05-20"},
+		Entry{URL: "https://d.com", User: "dave", Pass: "four", Created: "2025-05-26"},
+		Entry{URL: "https://e.com", User: "erin", Pass: "five", Created: "2025-06-02"},
+		// Values that only look like dates are left out
+		Entry{URL: "https://f.com", User: "frank", Pass: "six", Created: "2025-13-45"},
+		Entry{URL: "https://g.com", User: "grace", Pass: "seven", Created: "2025-02-30"},
+		Entry{URL: "https://h.com", User: "heidi", Pass: "eight", Created: "0000-01-01"},
+	)
+
+	type point struct {
+		Date  string `json:"date"`
+		Count int    `json:"count"`
+	}
+	tests := []struct {
+		query string
+		want  []point
+	}{
+		{"", []point{{"2025-05-19", 2}, {"2025-05-20", 1}, {"2025-05-26", 1}, {"2025-06-02", 1}}},
+		{"?bucket=week", []point{{"2025-05-19", 3}, {"2025-05-26", 1}, {"2025-06-02", 1}}},
+		{"?bucket=month", []point{{"2025-05-01", 4}, {"2025-06-01", 1}}},
+		{"?bucket=day&from=2025-05-20&to=2025-05-26", []point{{"2025-05-20", 1}, {"2025-05-26", 1}}},
+	}
+	for _, tt := range tests {
+		var result struct {
+			Timeline []point `json:"timeline"`
+		}
+		if status := getJSON(t, "/api/stats/timeline"+tt.query, &result); status != 200 {
+			t.Fatalf("GET %s: status = %d, want 200", tt.query, status)
+		}
+		if !reflect.DeepEqual(result.Timeline, tt.want) {
+			t.Errorf("GET %s: timeline = %+v, want %+v", tt.query, result.Timeline, tt.want)
+		}
+	}
+
+	for _, query := range []string{"?bucket=year", "?bucket=day';--", "?from=20-05-2025"} {
+		var result map[string]any
+		if status := getJSON(t, "/api/stats/timeline"+query, &result); status != 400 {
+			t.Errorf("GET %s: status = %d, want 400", query, status)
+		}
+	}
+}
+
+func TestAccountsPerDomainStats(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://a.com/login", User: "alice", Pass: "one"},
+		Entry{URL: "https://a.com/signin", User: "alice", Pass: "two"},
+		Entry{URL: "https://www.a.com", User: "ALICE", Pass: "three"},
+		Entry{URL: "https://a.com", User: "bob", Pass: "one"},
+		Entry{URL: "https://b.com", User: "carol", Pass: "one"},
+		Entry{URL: "https://b.com", User: "carol", Pass: "two"},
+		Entry{URL: "", User: "dave@c.com", Pass: "one"},
+	)
+
+	var result DomainAccountsResponse
+	if status := getJSON(t, "/api/stats/accounts-per-domain", &result); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	want := []DomainAccounts{{Domain: "a.com", DistinctUsers: 2}, {Domain: "b.com", DistinctUsers: 1}}
+	if !reflect.DeepEqual(result.Domains, want) || result.Total != 2 {
+		t.Errorf("domains = %+v (total %d), want %+v", result.Domains, result.Total, want)
+	}
+
+	if status := getJSON(t, "/api/stats/accounts-per-domain?page=2&pageSize=1", &result); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if !reflect.DeepEqual(result.Domains, want[1:]) || result.HasNext {
+		t.Errorf("second page = %+v, want %+v", result.Domains, want[1:])
+	}
+}
+
+func TestSchemeStats(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://a.com/login", User: "alice", Pass: "one"},
+		Entry{URL: "HTTPS://b.com", User: "bob", Pass: "one"},
+		Entry{URL: "http://c.com", User: "carol", Pass: "one"},
+		Entry{URL: "android://abc==@com.app/", User: "dave", Pass: "one"},
+		Entry{URL: "ftp://files.d.com", User: "erin", Pass: "one"},
+		Entry{URL: "e.com/path?next=https://f.com", User: "frank", Pass: "one"},
+		Entry{URL: "://g.com", User: "grace", Pass: "one"},
+		Entry{URL: "", User: "heidi@h.com", Pass: "one"},
+	)
+
+	type schemeCount struct {
+		Scheme string `json:"scheme"`
+		Count  int    `json:"count"`
+	}
+	var result struct {
+		Total   int           `json:"total"`
+		Schemes []schemeCount `json:"schemes"`
+	}
+	if status := getJSON(t, "/api/stats/schemes", &result); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	want := []schemeCount{{"none", 3}, {"https", 2}, {"android", 1}, {"ftp", 1}, {"http", 1}}
+	if !reflect.DeepEqual(result.Schemes, want) || result.Total != 8 {
+		t.Errorf("schemes = %+v (total %d), want %+v", result.Schemes, result.Total, want)
+	}
+}
+
+func TestSourceStats(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+
+	for name, content := range map[string]string{
+		"big.txt":   "https://a.com:alice:one\nhttps://b.com:bob:two\nhttps://c.com:carol:three\n",
+		"small.txt": "https://d.com:dave:four\nhttps://e.com:erin:five\n",
+	} {
+		if _, err := processReader(ctx, strings.NewReader(content), name, parser.Options{}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Deleting entries leaves entries_added as it was at import time
+	if _, err := dbPool.Exec(ctx, "DELETE FROM entries WHERE username IN ('alice', 'bob')"); err != nil {
+		t.Fatal(err)
+	}
+
+	var result SourcesResponse
+	if status := getJSON(t, "/api/stats/sources", &result); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	three, two := 3, 2
+	want := []SourceCount{
+		{SourceFile: "small.txt", Entries: 2, EntriesAdded: &two},
+		{SourceFile: "big.txt", Entries: 1, EntriesAdded: &three},
+	}
+	if !reflect.DeepEqual(result.Sources, want) || result.Total != 2 {
+		t.Errorf("sources = %+v (total %d), want %+v", result.Sources, result.Total, want)
+	}
+
+	if status := getJSON(t, "/api/stats/sources?page=2&pageSize=1", &result); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if len(result.Sources) != 1 || result.Sources[0].SourceFile != "big.txt" || result.HasNext {
+		t.Errorf("second page = %+v, want big.txt only", result)
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// migration is a single versioned schema change
+type migration struct {
+	version     int
+	description string
+	up          string
+}
+
+// migrations lists every schema change in the order they're applied. Applied
+// versions are recorded in schema_migrations, so existing steps must never be
+// edited; add a new step instead. Steps use IF NOT EXISTS so they also apply
+// cleanly to databases created before migrations were introduced.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create entries and processed_log_files",
+		up: `
+			CREATE TABLE IF NOT EXISTS entries (
+				id SERIAL PRIMARY KEY,
+				url TEXT NOT NULL,
+				username TEXT NOT NULL,
+				password TEXT NOT NULL,
+				created TEXT NOT NULL
+			);
+			CREATE TABLE IF NOT EXISTS processed_log_files (
+				id SERIAL PRIMARY KEY,
+				filename TEXT NOT NULL UNIQUE,
+				processed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+				entries_added INT NOT NULL DEFAULT 0
+			);
+		`,
+	},
+	{
+		version:     2,
+		description: "add entries.domain",
+		up: `
+			ALTER TABLE entries ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT '';
+			UPDATE entries SET domain = COALESCE(LOWER(CASE
+				WHEN url ILIKE 'android://%' THEN SUBSTRING(url FROM '@([^/:@]+)')
+				ELSE SUBSTRING(url FROM '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^@/]*@)?(?:www\.)?([^:/?#@]+)')
+			END), '')
+			WHERE domain = '';
+			CREATE INDEX IF NOT EXISTS idx_entries_domain ON entries (domain);
+		`,
+	},
+	{
+		version:     3,
+		description: "create import_runs",
+		up: `
+			CREATE TABLE IF NOT EXISTS import_runs (
+				id SERIAL PRIMARY KEY,
+				started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+				finished_at TIMESTAMP,
+				files_processed INT NOT NULL DEFAULT 0,
+				entries_added INT NOT NULL DEFAULT 0,
+				status TEXT NOT NULL DEFAULT 'running'
+			);
+		`,
+	},
+	{
+		version:     4,
+		description: "add processed_log_files import statistics",
+		up: `
+			ALTER TABLE processed_log_files
+				ADD COLUMN IF NOT EXISTS duration_ms BIGINT NOT NULL DEFAULT 0,
+				ADD COLUMN IF NOT EXISTS lines_skipped INT NOT NULL DEFAULT 0,
+				ADD COLUMN IF NOT EXISTS run_id INT REFERENCES import_runs (id) ON DELETE SET NULL;
+		`,
+	},
+	{
+		version:     5,
+		description: "add processed_log_files.sha256",
+		up: `
+			ALTER TABLE processed_log_files ADD COLUMN IF NOT EXISTS sha256 TEXT;
+			CREATE INDEX IF NOT EXISTS idx_processed_log_files_sha256 ON processed_log_files (sha256);
+		`,
+	},
+	{
+		version:     6,
+		description: "track the progress of partly imported files",
+		up: `
+			CREATE TABLE IF NOT EXISTS file_progress (
+				filename TEXT PRIMARY KEY,
+				sha256 TEXT NOT NULL,
+				byte_offset BIGINT NOT NULL,
+				line_no INT NOT NULL,
+				stats JSONB NOT NULL,
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+		`,
+	},
+	{
+		version:     7,
+		description: "add entries.tags",
+		up: `
+			ALTER TABLE entries ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+			CREATE INDEX IF NOT EXISTS idx_entries_tags ON entries USING GIN (tags);
+		`,
+	},
+	{
+		version:     8,
+		description: "create proxies table",
+		up: `
+			CREATE TABLE IF NOT EXISTS proxies (
+				id SERIAL PRIMARY KEY,
+				host TEXT NOT NULL,
+				port INTEGER NOT NULL,
+				username TEXT NOT NULL DEFAULT '',
+				password TEXT NOT NULL DEFAULT '',
+				created TEXT NOT NULL
+			);
+		`,
+	},
+	{
+		version:     9,
+		description: "add trigram indexes for substring search",
+		up: `
+			CREATE EXTENSION IF NOT EXISTS pg_trgm;
+			CREATE INDEX IF NOT EXISTS idx_entries_domain_trgm ON entries USING GIN (LOWER(domain) gin_trgm_ops);
+			CREATE INDEX IF NOT EXISTS idx_entries_url_trgm ON entries USING GIN (LOWER(url) gin_trgm_ops);
+			CREATE INDEX IF NOT EXISTS idx_entries_username_trgm ON entries USING GIN (LOWER(username) gin_trgm_ops);
+		`,
+	},
+	{
+		version:     10,
+		description: "add processed_log_files.status",
+		up: `
+			ALTER TABLE processed_log_files ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'processed';
+		`,
+	},
+	{
+		version:     11,
+		description: "add processed_log_files.duplicates_skipped",
+		up: `
+			ALTER TABLE processed_log_files ADD COLUMN IF NOT EXISTS duplicates_skipped INT NOT NULL DEFAULT 0;
+		`,
+	},
+	{
+		// Hash indexes have no size limit on the key, unlike B-trees, and
+		// /check only compares values for equality
+		version:     12,
+		description: "add username and password indexes for exact lookups",
+		up: `
+			CREATE INDEX IF NOT EXISTS idx_entries_username ON entries USING HASH (username);
+			CREATE INDEX IF NOT EXISTS idx_entries_password ON entries USING HASH (password);
+		`,
+	},
+	{
+		version:     13,
+		description: "create audit_log table",
+		up: `
+			CREATE TABLE IF NOT EXISTS audit_log (
+				id SERIAL PRIMARY KEY,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				action TEXT NOT NULL,
+				actor TEXT NOT NULL DEFAULT '',
+				affected_count INT NOT NULL DEFAULT 0,
+				details JSONB NOT NULL DEFAULT '{}'
+			);
+		`,
+	},
+	{
+		version:     14,
+		description: "add entries.run_id and entries.line_no",
+		up: `
+			ALTER TABLE entries
+				ADD COLUMN IF NOT EXISTS run_id INT REFERENCES import_runs (id) ON DELETE SET NULL,
+				ADD COLUMN IF NOT EXISTS line_no INT;
+		`,
+	},
+	{
+		version:     15,
+		description: "add entries.source_file",
+		up: `
+			ALTER TABLE entries ADD COLUMN IF NOT EXISTS source_file TEXT;
+			CREATE INDEX IF NOT EXISTS idx_entries_source_file ON entries (source_file);
+		`,
+	},
+	{
+		version:     16,
+		description: "add entries.content_id",
+		up: `
+			ALTER TABLE entries ADD COLUMN IF NOT EXISTS content_id TEXT;
+			CREATE INDEX IF NOT EXISTS idx_entries_content_id ON entries USING HASH (content_id);
+		`,
+	},
+	{
+		version:     17,
+		description: "add entries.kind",
+		up: `
+			ALTER TABLE entries ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT '';
+			CREATE INDEX IF NOT EXISTS idx_entries_kind ON entries (kind);
+		`,
+	},
+	{
+		version:     18,
+		description: "add entries.raw_line",
+		up: `
+			ALTER TABLE entries ADD COLUMN IF NOT EXISTS raw_line BYTEA;
+		`,
+	},
+	{
+		version:     19,
+		description: "index processed_log_files.processed_at",
+		up: `
+			CREATE INDEX IF NOT EXISTS idx_processed_log_files_processed_at ON processed_log_files (processed_at, id);
+		`,
+	},
+	{
+		version:     20,
+		description: "add entries.deleted_at",
+		up: `
+			ALTER TABLE entries ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+			CREATE INDEX IF NOT EXISTS idx_entries_live ON entries (id) WHERE deleted_at IS NULL;
+		`,
+	},
+	{
+		version:     21,
+		description: "index soft-deleted entries",
+		up: `
+			CREATE INDEX IF NOT EXISTS idx_entries_deleted ON entries (id) WHERE deleted_at IS NOT NULL;
+		`,
+	},
+	{
+		version:     22,
+		description: "add file_progress.hashed_bytes",
+		up: `
+			ALTER TABLE file_progress ADD COLUMN IF NOT EXISTS hashed_bytes BIGINT NOT NULL DEFAULT 0;
+		`,
+	},
+}
+
+// migrationLockID is the advisory lock held while applying a migration, so
+// several instances starting at once don't apply the same step twice
+const migrationLockID = 7226591
+
+// runMigrations applies every migration that hasn't been recorded in
+// schema_migrations yet. Each one runs in its own transaction.
+func runMigrations(ctx context.Context) error {
+	_, err := dbPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	for _, m := range migrations {
+		if err := applyMigration(ctx, m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration runs a single migration unless it was already applied
+func applyMigration(ctx context.Context, m migration) error {
+	return pgx.BeginFunc(ctx, dbPool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+			return err
+		}
+
+		var applied bool
+		err := tx.QueryRow(ctx,
+			"SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", m.version).Scan(&applied)
+		if err != nil || applied {
+			return err
+		}
+
+		log.Printf("Applying migration %d: %s", m.version, m.description)
+		if _, err := tx.Exec(ctx, m.up); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx,
+			"INSERT INTO schema_migrations (version, description) VALUES ($1, $2)", m.version, m.description)
+		return err
+	})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMigrationVersionsIncrease(t *testing.T) {
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version <= migrations[i-1].version {
+			t.Errorf("migration %d listed after %d, versions must increase", migrations[i].version, migrations[i-1].version)
+		}
+	}
+}
+
+func TestRunMigrationsTwice(t *testing.T) {
+	setupTestDB(t)
+
+	type applied struct {
+		count  int
+		latest string
+	}
+	readApplied := func() applied {
+		t.Helper()
+
+		var a applied
+		err := dbPool.QueryRow(context.Background(),
+			"SELECT COUNT(*), COALESCE(MAX(applied_at)::TEXT, '') FROM schema_migrations").Scan(&a.count, &a.latest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+
+	before := readApplied()
+	if before.count != len(migrations) {
+		t.Fatalf("applied migrations = %d, want %d", before.count, len(migrations))
+	}
+
+	if err := runMigrations(context.Background()); err != nil {
+		t.Fatalf("second runMigrations failed: %v", err)
+	}
+
+	if after := readApplied(); after != before {
+		t.Errorf("schema_migrations changed from %+v to %+v on the second run", before, after)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	// defaultPageSize is used when pageSize is missing or out of range
+	defaultPageSize = 10
+	// maxPageSize is the largest page a client can request
+	maxPageSize = 200
+)
+
+// parsePagination reads the page and pageSize query parameters. Values that
+// aren't numbers are an error, numbers out of range fall back to the defaults.
+func parsePagination(c fiber.Ctx) (page, pageSize, offset int, err error) {
+	page, err = strconv.Atoi(c.Query("page", "1"))
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid page %q", c.Query("page"))
+	}
+
+	pageSize, err = strconv.Atoi(c.Query("pageSize", strconv.Itoa(defaultPageSize)))
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid pageSize %q", c.Query("pageSize"))
+	}
+
+	page, pageSize, offset = normalizePagination(page, pageSize)
+	return page, pageSize, offset, nil
+}
+
+// normalizePagination replaces an out of range page or pageSize with the
+// defaults and returns the offset of the page
+func normalizePagination(page, pageSize int) (int, int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > maxPageSize {
+		pageSize = defaultPageSize // Ensure reasonable limits
+	}
+
+	return page, pageSize, (page - 1) * pageSize
+}
+
+// newPaginationResponse wraps one page of entries with its pagination metadata
+func newPaginationResponse(items []Entry, total, page, pageSize int) PaginationResponse {
+	return PaginationResponse{
+		Items:      items,
+		Pagination: newPagination(total, page, pageSize),
+	}
+}
+
+// newPagination describes a page of pageSize items out of total
+func newPagination(total, page, pageSize int) Pagination {
+	totalPages := (total + pageSize - 1) / pageSize // Ceiling division
+	hasNext := page < totalPages
+	hasPrevious := page > 1
+
+	nextPage := page + 1
+	if !hasNext {
+		nextPage = page
+	}
+	prevPage := page - 1
+	if !hasPrevious {
+		prevPage = page
+	}
+
+	return Pagination{
+		Total:       total,
+		Page:        page,
+		PageSize:    pageSize,
+		TotalPages:  totalPages,
+		HasNext:     hasNext,
+		HasPrevious: hasPrevious,
+		NextPage:    nextPage,
+		PrevPage:    prevPage,
+		Offset:      (page - 1) * pageSize,
+	}
+}
+
+// pageBounds returns the slice bounds of a page within n items
+func pageBounds(n, offset, pageSize int) (start, end int) {
+	start = min(offset, n)
+	end = min(start+pageSize, n)
+	return start, end
+}
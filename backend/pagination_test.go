@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestPageBounds(t *testing.T) {
+	tests := []struct {
+		n, offset, pageSize int
+		start, end          int
+	}{
+		{25, 0, 10, 0, 10},
+		{25, 20, 10, 20, 25},
+		{25, 30, 10, 25, 25},
+		{0, 0, 10, 0, 0},
+	}
+
+	for _, tt := range tests {
+		start, end := pageBounds(tt.n, tt.offset, tt.pageSize)
+		if start != tt.start || end != tt.end {
+			t.Errorf("pageBounds(%d, %d, %d) = %d, %d, want %d, %d",
+				tt.n, tt.offset, tt.pageSize, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestWatcherStatusFilesPage(t *testing.T) {
+	logDir := t.TempDir()
+	setConfig(Config{LogDir: logDir})
+	defer setConfig(Config{})
+
+	for i := 0; i < 25; i++ {
+		if err := os.WriteFile(filepath.Join(logDir, fmt.Sprintf("file%02d.txt", i)), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var status struct {
+		FileCount int        `json:"fileCount"`
+		Files     []string   `json:"files"`
+		FilesPage Pagination `json:"filesPage"`
+	}
+	if code := getJSON(t, "/api/watcher-status?page=3&pageSize=10", &status); code != 200 {
+		t.Fatalf("status = %d, want 200", code)
+	}
+
+	if status.FileCount != 25 || status.FilesPage.Total != 25 || status.FilesPage.TotalPages != 3 {
+		t.Errorf("fileCount = %d, page = %+v, want 25 files over 3 pages", status.FileCount, status.FilesPage)
+	}
+
+	var names []string
+	for _, f := range status.Files {
+		names = append(names, filepath.Base(f))
+	}
+	if want := []string{"file20.txt", "file21.txt", "file22.txt", "file23.txt", "file24.txt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("files = %v, want %v", names, want)
+	}
+}
+
+func TestProcessedFilesPage(t *testing.T) {
+	setupTestDB(t)
+
+	for i := 0; i < 7; i++ {
+		if err := recordProcessedFile(context.Background(), dbPool, fmt.Sprintf("file%d.txt", i), ParseStats{Inserted: i}, time.Second, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var result ProcessedFilesResponse
+	if code := getJSON(t, "/api/processed-files?page=2&pageSize=3", &result); code != 200 {
+		t.Fatalf("status = %d, want 200", code)
+	}
+
+	if result.Count != 7 || result.Total != 7 || result.TotalPages != 3 || !result.HasNext || !result.HasPrevious {
+		t.Errorf("pagination = count %d, %+v, want 7 files over 3 pages", result.Count, result.Pagination)
+	}
+
+	// The most recently processed files come first
+	var names []string
+	for _, f := range result.ProcessedFiles {
+		names = append(names, f.Filename)
+	}
+	if want := []string{"file3.txt", "file2.txt", "file1.txt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("files = %v, want %v", names, want)
+	}
+}
+
+func TestProcessedFilesWindow(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+
+	// Four files a day from January 1st to 15th
+	_, err := dbPool.Exec(ctx, `
+		INSERT INTO processed_log_files (filename, processed_at)
+		SELECT 'file' || i || '.txt', TIMESTAMP '2024-01-01' + i * INTERVAL '6 hours'
+		FROM generate_series(0, 59) AS i
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result ProcessedFilesResponse
+	if code := getJSON(t, "/api/processed-files?from=2024-01-03&to=2024-01-05&pageSize=100", &result); code != 200 {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if result.Count != 12 || len(result.ProcessedFiles) != 12 {
+		t.Fatalf("files = %d (count %d), want the 12 of January 3rd to 5th", len(result.ProcessedFiles), result.Count)
+	}
+	first, last := result.ProcessedFiles[0], result.ProcessedFiles[11]
+	if first.Filename != "file19.txt" || last.Filename != "file8.txt" {
+		t.Errorf("window = %s to %s, want file19.txt to file8.txt", first.Filename, last.Filename)
+	}
+
+	if code := getJSON(t, "/api/processed-files?from=2024-01-14", &result); code != 200 || result.Count != 8 {
+		t.Errorf("from January 14th: status %d, count %d, want 8", code, result.Count)
+	}
+
+	// The listing reads the index in order rather than sorting the table
+	err = pgx.BeginFunc(ctx, dbPool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SET LOCAL enable_seqscan = off"); err != nil {
+			return err
+		}
+		rows, err := tx.Query(ctx, "EXPLAIN SELECT filename FROM processed_log_files ORDER BY processed_at DESC, id DESC LIMIT 20")
+		if err != nil {
+			return err
+		}
+		var plan []string
+		for rows.Next() {
+			var line string
+			if err := rows.Scan(&line); err != nil {
+				return err
+			}
+			plan = append(plan, line)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if !strings.Contains(strings.Join(plan, "\n"), "idx_processed_log_files_processed_at") {
+			t.Errorf("plan doesn't use the processed_at index:\n%s", strings.Join(plan, "\n"))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
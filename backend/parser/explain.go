@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"strings"
+)
+
+// Reasons a line is skipped, named after the Stats counters
+const (
+	ReasonEmpty       = "empty"
+	ReasonShort       = "short"
+	ReasonInvalidUTF8 = "invalid_utf8"
+	ReasonPlaceholder = "placeholder"
+	ReasonProxy       = "proxy"
+	ReasonIncomplete  = "incomplete"
+	ReasonHeader      = "header"
+	ReasonTooLong     = "too_long"
+)
+
+// LineResult describes how a single line is parsed
+type LineResult struct {
+	Line string `json:"line"`
+	// Parts is what the line split into: url, username and password when
+	// it's complete
+	Parts []string `json:"parts"`
+	// Branch is the SplitLine heuristic that matched, the parser of the rule,
+	// "combo" for combolists or "proxy" for proxy list lines
+	Branch string `json:"branch"`
+	// Accepted reports whether the line would be imported as an entry
+	Accepted bool `json:"accepted"`
+	// Reason tells why a line that isn't accepted would be skipped
+	Reason string `json:"reason,omitempty"`
+}
+
+// ExplainLine parses the first line of line with Scan, so the result matches
+// an import of a file holding that line, and reports how it split. Rules for
+// multi-line formats see a single line, which is reported as incomplete.
+func ExplainLine(line string, opts Options) LineResult {
+	line, _, _ = strings.Cut(line, "\n")
+	result := LineResult{Line: line, Parts: []string{}}
+
+	var stats Stats
+	var entry *Entry
+	opts.OnProxy = nil
+	_ = Scan(strings.NewReader(line), opts, &stats, func(e Entry) error {
+		entry = &e
+		return nil
+	})
+
+	// Split the line as Scan saw it to report the parts of rejected lines
+	cleaned := SanitizeString(string(dropInvalidUtf8([]byte(strings.TrimSuffix(line, "\r")))))
+	if opts.StripControl {
+		cleaned = StripControl(cleaned)
+	}
+	switch {
+	case stats.SkippedProxy > 0:
+		result.Branch = ReasonProxy
+	case stats.ComboMode:
+		result.Branch = "combo"
+		result.Parts = splitComboLine(cleaned)
+	case opts.Rule != nil && opts.Rule.Parser != Auto:
+		result.Branch = opts.Rule.Parser
+	default:
+		result.Parts, result.Branch = SplitLineReason(cleaned)
+	}
+	if entry != nil {
+		result.Parts = []string{entry.URL, entry.Username, entry.Password}
+	}
+	if result.Parts == nil {
+		result.Parts = []string{}
+	}
+
+	switch {
+	case entry != nil:
+		result.Accepted = true
+	case stats.Total == 0:
+		result.Reason = ReasonEmpty
+	case stats.SkippedProxy > 0:
+		result.Reason = ReasonProxy
+	case stats.SkippedHeader > 0:
+		result.Reason = ReasonHeader
+	case stats.SkippedPlaceholder > 0:
+		result.Reason = ReasonPlaceholder
+	case stats.SkippedTooLong > 0:
+		result.Reason = ReasonTooLong
+	case stats.SkippedInvalidUTF8 > 0:
+		result.Reason = ReasonInvalidUTF8
+	case stats.SkippedShort > 0:
+		result.Reason = ReasonShort
+	default:
+		result.Reason = ReasonIncomplete
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExplainLine(t *testing.T) {
+	tests := []struct {
+		line string
+		opts Options
+		want LineResult
+	}{
+		{
+			line: "https://site.com:8080/login:alice:secret",
+			want: LineResult{Parts: []string{"https://site.com:8080/login", "alice", "secret"}, Branch: BranchPort, Accepted: true},
+		},
+		{
+			line: "android://abc==@com.app/:bob:pw",
+			want: LineResult{Parts: []string{"android://abc==@com.app/", "bob", "pw"}, Branch: BranchAndroid, Accepted: true},
+		},
+		{
+			line: "https://[2001:db8::1]/login:carol:pw",
+			want: LineResult{Parts: []string{"https://[2001:db8::1]/login", "carol", "pw"}, Branch: BranchAuthority, Accepted: true},
+		},
+		{
+			line: "site.com dave pass word",
+			want: LineResult{Parts: []string{"site.com", "dave", "pass word"}, Branch: BranchSpace, Accepted: true},
+		},
+		{
+			line: "https://site.com:UNKNOWN:pw",
+			opts: Options{RejectValues: []string{"unknown"}},
+			want: LineResult{Parts: []string{"https://site.com", "UNKNOWN", "pw"}, Branch: BranchPort, Reason: ReasonPlaceholder},
+		},
+		{
+			line: "URL:USERNAME:PASSWORD",
+			want: LineResult{Parts: []string{"URL", "USERNAME", "PASSWORD"}, Branch: BranchColon, Reason: ReasonHeader},
+		},
+		{
+			line: "justoneword",
+			want: LineResult{Parts: []string{"justoneword"}, Branch: BranchNone, Reason: ReasonShort},
+		},
+		{
+			line: "1.2.3.4:8080:user:pass",
+			want: LineResult{Parts: []string{}, Branch: ReasonProxy, Reason: ReasonProxy},
+		},
+		{
+			line: "alice@gmail.com:hunter2",
+			opts: Options{ComboMode: true},
+			want: LineResult{Parts: []string{"", "alice@gmail.com", "hunter2"}, Branch: "combo", Accepted: true},
+		},
+		{
+			line: "Username: alice",
+			opts: Options{Rule: &Rule{Pattern: "*", Parser: Labeled}},
+			want: LineResult{Parts: []string{}, Branch: Labeled, Reason: ReasonIncomplete},
+		},
+		{
+			line: "   ",
+			want: LineResult{Parts: []string{}, Branch: BranchNone, Reason: ReasonEmpty},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			tt.want.Line = tt.line
+			if got := ExplainLine(tt.line, tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExplainLine(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
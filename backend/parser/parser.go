@@ -0,0 +1,539 @@
+// Package parser splits stealer logs and combolists into url, username and
+// password entries. It has no database dependency, so the backend's parsing
+// can be reused by other tools.
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Entry is a credential parsed from a log line
+type Entry struct {
+	URL      string
+	Username string
+	Password string
+	// Line is the line number the entry starts on, counting from 1
+	Line int
+	// Raw holds the lines of the entry as read, before they're sanitized,
+	// joined by newlines. It's only set with Options.KeepRaw.
+	Raw []byte
+	// End is where the last line of the entry ends, to resume the log after
+	// it with Options.Resume
+	End Position
+}
+
+// Position is a line boundary of a log
+type Position struct {
+	// Offset counts the bytes of the log up to the position. It's 0 for
+	// UTF-16 logs, whose offsets don't match the decoded lines.
+	Offset int64
+	// Line is the number of the line ending at the position
+	Line int
+}
+
+// Stats summarizes how the lines of a log were parsed
+type Stats struct {
+	// Total is the number of non-empty lines read
+	Total int `json:"total"`
+	// SkippedShort counts lines that didn't split into url, username and password
+	SkippedShort int `json:"skippedShort"`
+	// SkippedInvalidUTF8 counts unparseable lines that contained invalid UTF-8
+	SkippedInvalidUTF8 int `json:"skippedInvalidUTF8"`
+	// SkippedPlaceholder counts entries dropped by the reject list
+	SkippedPlaceholder int `json:"skippedPlaceholder"`
+	// SkippedProxy counts proxy list lines, unless OnProxy receives them
+	SkippedProxy int `json:"skippedProxy"`
+	// SkippedHeader is 1 when the first line is a header like URL:USER:PASS
+	SkippedHeader int `json:"skippedHeader"`
+	// SkippedTooLong counts entries over the username or password length limit
+	SkippedTooLong int `json:"skippedTooLong"`
+	// SkippedByRegex counts lines matched by Options.SkipLine
+	SkippedByRegex int `json:"skippedByRegex"`
+	// Proxies is the number of proxy list lines passed to OnProxy
+	Proxies int `json:"proxies"`
+	// NullByteLines counts lines null bytes were removed from
+	NullByteLines int `json:"nullByteLines"`
+	// ComboMode reports whether the log was parsed as an email:password combolist
+	ComboMode bool `json:"comboMode"`
+	// Parser is the name of the parser selected by the rule
+	Parser string `json:"parser"`
+}
+
+// Skipped returns the total number of lines that didn't parse into an entry
+func (s Stats) Skipped() int {
+	return s.SkippedShort + s.SkippedInvalidUTF8 + s.SkippedPlaceholder + s.SkippedProxy + s.SkippedHeader + s.SkippedTooLong +
+		s.SkippedByRegex
+}
+
+// Options controls how the lines of a single log are parsed
+type Options struct {
+	// ComboMode parses email:password lines that have no URL. It's enabled
+	// automatically when the start of a log looks like a combolist.
+	ComboMode bool
+	// Rule picks the parser, nil to use the heuristics
+	Rule *Rule
+	// RejectValues are placeholder usernames and passwords, compared
+	// ignoring case, whose entries are skipped
+	RejectValues []string
+	// OnProxy receives the proxy list lines found by the heuristics. They're
+	// counted as skipped when it's nil.
+	OnProxy func(proxy Proxy) error
+	// StripControl removes control characters other than tabs from lines
+	// and collapses runs of whitespace before they're parsed
+	StripControl bool
+	// NormalizePasswords trims trailing whitespace from passwords and strips
+	// the quotes some dumps wrap them in, see NormalizePassword
+	NormalizePasswords bool
+	// NormalizeUsername is UsernameTrim or UsernameLower to clean up
+	// usernames, see NormalizeUsername
+	NormalizeUsername string
+	// Limits skips entries with overly long usernames or passwords
+	Limits FieldLimits
+	// SkipLine drops the lines it matches, such as banners and comments,
+	// before they're parsed. Lines are matched after sanitizing.
+	SkipLine *regexp.Regexp
+	// KeepRaw copies the original lines of every entry to Entry.Raw
+	KeepRaw bool
+	// CSVColumns maps the columns for the csv parser, which otherwise
+	// expects url,username,password, see ParseCSVHeader
+	CSVColumns *CSVColumns
+	// Resume continues a log read by an earlier Scan from the End of one of
+	// its entries, the reader starting right after it. Line numbers and
+	// offsets go on from there, and the log isn't checked for a combolist
+	// or a header again, so ComboMode must be set as the earlier Scan
+	// reported it.
+	Resume *Position
+}
+
+// FieldLimits are the longest username and password accepted, in characters.
+// Longer values are usually binary junk from a misparsed line. Zero disables
+// a limit.
+type FieldLimits struct {
+	MaxUsernameLen int
+	MaxPasswordLen int
+}
+
+// Exceeded reports whether username or password is longer than its limit
+func (l FieldLimits) Exceeded(username, password string) bool {
+	return (l.MaxUsernameLen > 0 && utf8.RuneCountInString(username) > l.MaxUsernameLen) ||
+		(l.MaxPasswordLen > 0 && utf8.RuneCountInString(password) > l.MaxPasswordLen)
+}
+
+const (
+	// ComboSniffSize is how much of a log is inspected to detect a combolist
+	ComboSniffSize = 64 * 1024
+	// comboSniffLines is the number of lines that must all look like user:pass
+	comboSniffLines = 50
+)
+
+// ParseStream parses every line of r with the default heuristics and returns
+// the entries along with the parse statistics
+func ParseStream(r io.Reader) ([]Entry, Stats, error) {
+	var stats Stats
+	entries := []Entry{}
+	err := Scan(r, Options{}, &stats, func(entry Entry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, stats, err
+}
+
+// Scan reads log lines from r and calls fn for every line that parses into
+// an entry, counting read and skipped lines in stats. It stops at the first
+// error returned by fn or opts.OnProxy.
+func Scan(r io.Reader, opts Options, stats *Stats, fn func(entry Entry) error) error {
+	// lineNo counts every line read, firstLine is where the entry being
+	// parsed started since multi-line formats complete it on a later line.
+	// headerLine is the first non-empty line, the only one taken as a header.
+	lineNo, firstLine, headerLine := 0, 0, 0
+	// offset is where the decoded text starts in the log, read counts the
+	// bytes of text read so far. UTF-16 logs have no offsets.
+	var offset, read int64
+	trackOffsets := true
+	input := bufio.NewReader(r)
+	if opts.Resume != nil {
+		lineNo, headerLine, offset = opts.Resume.Line, -1, opts.Resume.Offset
+	} else if head, _ := input.Peek(len(utf8BOM)); bytes.HasPrefix(head, utf8BOM) {
+		offset = int64(len(utf8BOM))
+	} else {
+		trackOffsets = !IsUTF16(head)
+	}
+
+	// Look at the start of the file to detect combolists, unless a rule picks the parser
+	reader := bufio.NewReaderSize(DecodeBOM(input), ComboSniffSize)
+	if !opts.ComboMode && opts.Rule == nil && opts.Resume == nil {
+		head, _ := reader.Peek(ComboSniffSize)
+		opts.ComboMode = LooksLikeComboList(head)
+	}
+	stats.ComboMode = opts.ComboMode
+
+	parse := newLineParser(opts)
+	stats.Parser = Auto
+	if opts.Rule != nil {
+		stats.Parser = opts.Rule.Parser
+	}
+
+	// Create a scanner to read the file line by line
+	scanner := bufio.NewScanner(reader)
+
+	// Buffer size optimization for large files
+	const maxCapacity = 512 * 1024 // 512KB
+	buf := make([]byte, maxCapacity)
+	scanner.Buffer(buf, maxCapacity)
+
+	// Use a custom split function that can handle problematic bytes, and
+	// count the bytes of the lines it returns
+	scanner.Split(func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		defer func() { read += int64(advance) }()
+
+		// Skip null bytes and try to find the next newline
+		start := 0
+		for start < len(data) && data[start] == 0 {
+			start++
+		}
+
+		if start == len(data) {
+			if atEOF {
+				return 0, nil, nil
+			}
+			// Request more data
+			return 0, nil, nil
+		}
+
+		// Look for newline after skipping null bytes
+		if i := bytes.IndexByte(data[start:], '\n'); i >= 0 {
+			// We have a full line, without the CR of Windows line endings
+			return start + i + 1, bytes.TrimSuffix(data[start:start+i], []byte{'\r'}), nil
+		}
+
+		// If we're at EOF, return the remaining data
+		if atEOF {
+			return len(data), bytes.TrimSuffix(data[start:], []byte{'\r'}), nil
+		}
+
+		// Request more data
+		return 0, nil, nil
+	})
+
+	// rawLines collects the original lines of the entry with KeepRaw
+	var rawLines []byte
+
+	// For each line in the file
+	for scanner.Scan() {
+		lineNo++
+		// Get line and sanitize it to handle invalid UTF-8 characters
+		raw := scanner.Bytes()
+		invalidUTF8 := !utf8.Valid(raw)
+		if bytes.IndexByte(raw, 0) >= 0 {
+			stats.NullByteLines++
+		}
+		line := SanitizeString(string(dropInvalidUtf8(raw)))
+		if opts.StripControl {
+			line = StripControl(line)
+		}
+
+		// Skip empty lines, unless they only look empty because of invalid bytes
+		if len(strings.TrimSpace(line)) == 0 {
+			if invalidUTF8 {
+				stats.Total++
+				stats.SkippedInvalidUTF8++
+			}
+			continue
+		}
+		stats.Total++
+		if opts.SkipLine != nil && opts.SkipLine.MatchString(line) {
+			stats.SkippedByRegex++
+			continue
+		}
+		if headerLine == 0 {
+			headerLine = lineNo
+		}
+
+		// Proxy lists aren't credentials. In a combolist only IP addresses are
+		// taken as proxies since "john.doe:1234" is a username and password.
+		if opts.Rule == nil || opts.Rule.Parser == Auto {
+			if proxy, ok := ParseProxyLine(line); ok && (!opts.ComboMode || isIPv4(proxy.Host)) {
+				if opts.OnProxy == nil {
+					stats.SkippedProxy++
+					continue
+				}
+				stats.Proxies++
+				if err := opts.OnProxy(proxy); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		// Parse the line, lines of a multi-line entry aren't counted as skipped
+		if firstLine == 0 {
+			firstLine = lineNo
+			rawLines = rawLines[:0]
+		}
+		if opts.KeepRaw {
+			if len(rawLines) > 0 {
+				rawLines = append(rawLines, '\n')
+			}
+			rawLines = append(rawLines, raw...)
+		}
+		parts, pending := parse(line)
+		if pending {
+			continue
+		}
+		entryLine := firstLine
+		firstLine = 0
+		if len(parts) < 3 {
+			// Skip invalid lines without logging to avoid spam
+			if invalidUTF8 {
+				stats.SkippedInvalidUTF8++
+			} else {
+				stats.SkippedShort++
+			}
+			continue
+		}
+
+		// Sanitize each part to ensure no invalid UTF-8 characters
+		entry := Entry{
+			URL:      SanitizeString(parts[0]),
+			Username: SanitizeString(parts[1]),
+			Password: SanitizeString(parts[2]),
+			Line:     entryLine,
+			End:      Position{Line: lineNo},
+		}
+		if trackOffsets {
+			entry.End.Offset = offset + read
+		}
+		if opts.KeepRaw {
+			entry.Raw = bytes.Clone(rawLines)
+		}
+		if entryLine == headerLine && IsHeader(entry) {
+			stats.SkippedHeader++
+			continue
+		}
+		if opts.NormalizePasswords {
+			entry.Password = NormalizePassword(entry.Password)
+		}
+		entry.Username = NormalizeUsername(entry.Username, opts.NormalizeUsername)
+
+		// Skip placeholder values from the reject list
+		if IsRejected(opts.RejectValues, entry.Username) || IsRejected(opts.RejectValues, entry.Password) {
+			stats.SkippedPlaceholder++
+			continue
+		}
+		if opts.Limits.Exceeded(entry.Username, entry.Password) {
+			stats.SkippedTooLong++
+			continue
+		}
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	// Check for scanner errors
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+
+	return nil
+}
+
+// LooksLikeComboList reports whether the first lines of a file consistently
+// have exactly two colon-separated fields and no URL, e.g. "user@mail.com:pass"
+func LooksLikeComboList(head []byte) bool {
+	lines := strings.Split(string(head), "\n")
+
+	// The last line may have been cut off by the sniff size
+	if len(head) == ComboSniffSize && len(lines) > 1 {
+		lines = lines[:len(lines)-1]
+	}
+
+	sampled := 0
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		user, pass, ok := strings.Cut(line, ":")
+		if !ok || user == "" || pass == "" || strings.Contains(pass, ":") || strings.Contains(line, "://") {
+			return false
+		}
+
+		sampled++
+		if sampled >= comboSniffLines {
+			break
+		}
+	}
+
+	return sampled > 0
+}
+
+// splitComboLine splits an email:password combolist line, leaving the URL empty
+func splitComboLine(line string) []string {
+	username, password, ok := strings.Cut(line, ":")
+	username = strings.TrimSpace(username)
+	if !ok || username == "" || password == "" {
+		return nil
+	}
+
+	return []string{"", username, password}
+}
+
+// utf8BOM is the byte order mark DecodeBOM drops from UTF-8 text
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// IsUTF16 reports whether a log starting with head begins with a UTF-16 byte
+// order mark, so DecodeBOM converts it
+func IsUTF16(head []byte) bool {
+	return bytes.HasPrefix(head, []byte{0xFF, 0xFE}) || bytes.HasPrefix(head, []byte{0xFE, 0xFF})
+}
+
+// DecodeBOM converts UTF-16 text to UTF-8 when r starts with a UTF-16 byte
+// order mark, as written by some Windows stealers. A UTF-8 byte order mark is
+// dropped and anything else is passed through unchanged.
+func DecodeBOM(r io.Reader) io.Reader {
+	return transform.NewReader(r, unicode.BOMOverride(encoding.Nop.NewDecoder()))
+}
+
+// headerTokens are the column names of header lines, compared ignoring case
+var headerTokens = []string{"url", "host", "username", "user", "login", "email", "password", "pass"}
+
+// IsHeader reports whether every field of entry is empty or a column name
+// such as "URL" or "password", as in a URL:USERNAME:PASSWORD header line
+func IsHeader(entry Entry) bool {
+	names := 0
+	for _, field := range []string{entry.URL, entry.Username, entry.Password} {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !slices.Contains(headerTokens, strings.ToLower(field)) {
+			return false
+		}
+		names++
+	}
+	return names >= 2
+}
+
+// NormalizePassword removes trailing whitespace, including a carriage
+// return, and one pair of matching double or single quotes around password
+func NormalizePassword(password string) string {
+	password = strings.TrimRight(password, " \t\r\n\v\f")
+	if len(password) >= 2 {
+		first, last := password[0], password[len(password)-1]
+		if first == last && (first == '"' || first == '\'') {
+			password = password[1 : len(password)-1]
+		}
+	}
+	return password
+}
+
+// Username normalizations of Options.NormalizeUsername
+const (
+	UsernameTrim  = "trim"
+	UsernameLower = "lower"
+)
+
+// NormalizeUsername removes whitespace around username with UsernameTrim,
+// and also lowercases it with UsernameLower. Other modes leave it unchanged.
+func NormalizeUsername(username, mode string) string {
+	switch mode {
+	case UsernameTrim:
+		return strings.TrimSpace(username)
+	case UsernameLower:
+		return strings.ToLower(strings.TrimSpace(username))
+	}
+	return username
+}
+
+// SanitizeString removes null bytes and ensures valid UTF-8 characters
+func SanitizeString(input string) string {
+	// Remove null bytes which cause PostgreSQL UTF-8 encoding errors
+	sanitized := strings.ReplaceAll(input, "\x00", "")
+
+	// Handle other invalid UTF-8 sequences by replacing them
+	// This creates a new string with invalid UTF-8 sequences replaced
+	if !utf8.ValidString(sanitized) {
+		// Replace invalid UTF-8 sequences with the Unicode replacement character
+		var result []rune
+		for _, r := range sanitized {
+			if r == utf8.RuneError {
+				// Skip invalid runes
+				continue
+			}
+			result = append(result, r)
+		}
+		sanitized = string(result)
+	}
+
+	return sanitized
+}
+
+// dropInvalidUtf8 removes invalid UTF-8 sequences from a byte slice
+func dropInvalidUtf8(data []byte) []byte {
+	if utf8.Valid(data) {
+		return data
+	}
+
+	result := make([]byte, 0, len(data))
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r != utf8.RuneError {
+			result = append(result, data[:size]...)
+		}
+		data = data[size:]
+	}
+	return result
+}
+
+// IsRejected reports whether a username or password is on the reject list
+func IsRejected(rejectValues []string, value string) bool {
+	for _, rejected := range rejectValues {
+		if strings.EqualFold(value, rejected) {
+			return true
+		}
+	}
+	return false
+}
+
+// StripControl removes C0 control characters except tabs, which delimit
+// fields, and collapses each run of spaces and tabs into a single character:
+// a tab when the run contains one and a space otherwise
+func StripControl(input string) string {
+	var b strings.Builder
+	b.Grow(len(input))
+
+	var run rune
+	for _, r := range input {
+		if r == ' ' || r == '\t' {
+			if run != '\t' {
+				run = r
+			}
+			continue
+		}
+		if r < 0x20 {
+			continue
+		}
+		if run != 0 {
+			b.WriteRune(run)
+			run = 0
+		}
+		b.WriteRune(r)
+	}
+	if run != 0 {
+		b.WriteRune(run)
+	}
+	return b.String()
+}
@@ -0,0 +1,449 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParseStream(t *testing.T) {
+	content := strings.Join([]string{
+		"https://example.com/login:alice:secret1",
+		"only:two",
+		"",
+		"1.2.3.4:8080:user:pass",
+		"\xff\xfe\xfd",
+		"https://example.net:bob:s\xffecret3\r",
+	}, "\n")
+
+	entries, stats, err := ParseStream(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("ParseStream returned error: %v", err)
+	}
+
+	// Line numbers count the skipped and empty lines in between
+	expectedEntries := []Entry{
+		{URL: "https://example.com/login", Username: "alice", Password: "secret1", Line: 1, End: Position{Offset: 40, Line: 1}},
+		{URL: "https://example.net", Username: "bob", Password: "secret3", Line: 6, End: Position{Offset: int64(len(content)), Line: 6}},
+	}
+	if !reflect.DeepEqual(entries, expectedEntries) {
+		t.Errorf("entries = %+v, want %+v", entries, expectedEntries)
+	}
+
+	expectedStats := Stats{
+		Total:              5,
+		SkippedShort:       1,
+		SkippedInvalidUTF8: 1,
+		SkippedProxy:       1,
+		Parser:             Auto,
+	}
+	if stats != expectedStats {
+		t.Errorf("stats = %+v, want %+v", stats, expectedStats)
+	}
+	if stats.Skipped() != 3 {
+		t.Errorf("Skipped() = %d, want 3", stats.Skipped())
+	}
+}
+
+func TestParseStreamComboListAndBOM(t *testing.T) {
+	// UTF-16LE with a byte order mark, as written by some Windows stealers
+	utf16 := "\xff\xfe"
+	for _, r := range "alice@gmail.com:hunter2\r\nbob@yahoo.com:p@ss\r\n" {
+		utf16 += string([]byte{byte(r), 0})
+	}
+
+	entries, stats, err := ParseStream(strings.NewReader(utf16))
+	if err != nil {
+		t.Fatalf("ParseStream returned error: %v", err)
+	}
+	if !stats.ComboMode {
+		t.Error("expected combolist to be detected")
+	}
+	// Offsets of the decoded lines aren't offsets of the log
+	expected := []Entry{
+		{Username: "alice@gmail.com", Password: "hunter2", Line: 1, End: Position{Line: 1}},
+		{Username: "bob@yahoo.com", Password: "p@ss", Line: 2, End: Position{Line: 2}},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("entries = %+v, want %+v", entries, expected)
+	}
+}
+
+func TestScanResume(t *testing.T) {
+	scan := func(content string, opts Options) ([]Entry, Stats) {
+		t.Helper()
+		var entries []Entry
+		var stats Stats
+		err := Scan(strings.NewReader(content), opts, &stats, func(entry Entry) error {
+			entries = append(entries, entry)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return entries, stats
+	}
+
+	for _, tt := range []struct {
+		name, content string
+		opts          Options
+	}{
+		{"colon", "URL:USER:PASS\nhttps://a.com:alice:one\r\n\nhttps://b.com:bob:two\nhttps://c.com:carol:three", Options{}},
+		{"bom", "\xef\xbb\xbfhttps://a.com:alice:one\nhttps://b.com:bob:two\n", Options{}},
+		{"labeled", "URL: https://a.com\nUSER: alice\nPASS: one\nURL: https://b.com\nUSER: bob\nPASS: two\n", Options{Rule: &Rule{Parser: Labeled}}},
+		{"combolist", strings.Repeat("alice@mail.com:one\n", 3) + "bob@mail.com:two\n", Options{}},
+	} {
+		entries, stats := scan(tt.content, tt.opts)
+		if len(entries) < 2 {
+			t.Fatalf("%s: parsed %d entries, want at least 2", tt.name, len(entries))
+		}
+
+		// Reading on from the end of the first entry yields the others
+		// unchanged, with the line numbers of the whole log
+		end := entries[0].End
+		opts := tt.opts
+		opts.Resume, opts.ComboMode = &end, stats.ComboMode
+		rest, _ := scan(tt.content[end.Offset:], opts)
+		if !reflect.DeepEqual(rest, entries[1:]) {
+			t.Errorf("%s: resumed entries = %+v, want %+v", tt.name, rest, entries[1:])
+		}
+	}
+
+	// A resumed log isn't checked for a header again
+	entries, stats := scan("https://a.com:alice:one\nurl:user:pass\n", Options{Resume: &Position{Offset: 100, Line: 9}})
+	want := []Entry{
+		{URL: "https://a.com", Username: "alice", Password: "one", Line: 10, End: Position{Offset: 124, Line: 10}},
+		{URL: "url", Username: "user", Password: "pass", Line: 11, End: Position{Offset: 138, Line: 11}},
+	}
+	if !reflect.DeepEqual(entries, want) || stats.SkippedHeader != 0 {
+		t.Errorf("entries = %+v, want %+v", entries, want)
+	}
+}
+
+func TestScanOptions(t *testing.T) {
+	content := "https://a.com:UNKNOWN:one\nhttps://b.com:bob:two\n5.6.7.8:3128\n"
+
+	var stats Stats
+	var entries []Entry
+	var proxies []Proxy
+	opts := Options{
+		RejectValues: []string{"unknown"},
+		OnProxy: func(p Proxy) error {
+			proxies = append(proxies, p)
+			return nil
+		},
+	}
+	err := Scan(strings.NewReader(content), opts, &stats, func(entry Entry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Scan returned error: %v", err)
+	}
+
+	if want := []Entry{{URL: "https://b.com", Username: "bob", Password: "two", Line: 2, End: Position{Offset: 48, Line: 2}}}; !reflect.DeepEqual(entries, want) {
+		t.Errorf("entries = %+v, want %+v", entries, want)
+	}
+	if want := []Proxy{{Host: "5.6.7.8", Port: 3128}}; !reflect.DeepEqual(proxies, want) {
+		t.Errorf("proxies = %+v, want %+v", proxies, want)
+	}
+	if stats.SkippedPlaceholder != 1 || stats.Proxies != 1 {
+		t.Errorf("stats = %+v, want 1 placeholder and 1 proxy", stats)
+	}
+
+	// An error from the callback stops the scan
+	stop := errors.New("stop")
+	calls := 0
+	err = Scan(strings.NewReader(content), Options{}, &Stats{}, func(entry Entry) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Scan error = %v after %d calls, want %v after 1", err, calls, stop)
+	}
+}
+
+func TestSanitizeString(t *testing.T) {
+	tests := map[string]string{
+		"plain":            "plain",
+		"nul\x00bytes\x00": "nulbytes",
+		"bad\xffutf8":      "badutf8",
+		"ünïcödé":          "ünïcödé",
+	}
+	for input, want := range tests {
+		if got := SanitizeString(input); got != want {
+			t.Errorf("SanitizeString(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestStripControl(t *testing.T) {
+	tests := map[string]string{
+		"plain":                 "plain",
+		"esc\x1b[0mape":         "esc[0mape",
+		"bell\x07 and  spaces":  "bell and spaces",
+		"tab\t \tdelimited":     "tab\tdelimited",
+		"  trailing \x0b  ":     " trailing ",
+		"ünïcödé\x01":           "ünïcödé",
+		"https://a.com\x0c:b:c": "https://a.com:b:c",
+	}
+	for input, want := range tests {
+		if got := StripControl(input); got != want {
+			t.Errorf("StripControl(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestScanHeaderLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		headers int
+	}{
+		{"with header", "URL:USERNAME:PASSWORD\nhttps://a.com:alice:one\n", []string{"alice"}, 1},
+		{"header after blank lines", "\n\nurl:user:pass\nhttps://a.com:alice:one\n", []string{"alice"}, 1},
+		{"combolist header", "email:password\nalice@a.com:one\nbob@b.com:two\n", []string{"alice@a.com", "bob@b.com"}, 1},
+		{"without header", "https://a.com:alice:one\nhttps://b.com:bob:two\n", []string{"alice", "bob"}, 0},
+		{"not the first line", "https://a.com:alice:one\nurl:user:pass\n", []string{"alice", "user"}, 0},
+		{"a single name", "https://a.com:user:hunter2\n", []string{"user"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var users []string
+			var stats Stats
+			err := Scan(strings.NewReader(tt.content), Options{}, &stats, func(entry Entry) error {
+				users = append(users, entry.Username)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(users, tt.want) {
+				t.Errorf("usernames = %q, want %q", users, tt.want)
+			}
+			if stats.SkippedHeader != tt.headers || stats.Skipped() != tt.headers {
+				t.Errorf("stats = %+v, want %d skipped header", stats, tt.headers)
+			}
+		})
+	}
+}
+
+func TestScanFieldLimits(t *testing.T) {
+	limits := FieldLimits{MaxUsernameLen: 8, MaxPasswordLen: 10}
+	content := strings.Join([]string{
+		"https://a.com:" + strings.Repeat("u", 8) + ":" + strings.Repeat("p", 10),
+		"https://b.com:" + strings.Repeat("u", 9) + ":pass",
+		"https://c.com:user:" + strings.Repeat("p", 11),
+		"https://d.com:ünïcödé!:" + strings.Repeat("é", 10),
+	}, "\n")
+
+	for _, tt := range []struct {
+		limits  FieldLimits
+		want    []string
+		tooLong int
+	}{
+		{limits, []string{"https://a.com", "https://d.com"}, 2},
+		{FieldLimits{}, []string{"https://a.com", "https://b.com", "https://c.com", "https://d.com"}, 0},
+	} {
+		var urls []string
+		var stats Stats
+		err := Scan(strings.NewReader(content), Options{Limits: tt.limits}, &stats, func(entry Entry) error {
+			urls = append(urls, entry.URL)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(urls, tt.want) || stats.SkippedTooLong != tt.tooLong || stats.Skipped() != tt.tooLong {
+			t.Errorf("limits %+v: urls = %q, stats = %+v, want %q and %d too long", tt.limits, urls, stats, tt.want, tt.tooLong)
+		}
+	}
+}
+
+func TestNormalizePassword(t *testing.T) {
+	tests := map[string]string{
+		"plain":         "plain",
+		`"Hunter2"`:     "Hunter2",
+		"'Hunter2'":     "Hunter2",
+		"hunter2\r":     "hunter2",
+		"hunter2  \t":   "hunter2",
+		"\"quoted\" \r": "quoted",
+		`"mismatched'`:  `"mismatched'`,
+		`"`:             `"`,
+		`a"b"`:          `a"b"`,
+		" leading":      " leading",
+	}
+	for input, want := range tests {
+		if got := NormalizePassword(input); got != want {
+			t.Errorf("NormalizePassword(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestScanNormalizePasswords(t *testing.T) {
+	content := "https://a.com:alice:\"Hunter2\"\nhttps://b.com:bob:two \r\n"
+
+	for _, tt := range []struct {
+		normalize bool
+		want      []string
+	}{
+		{false, []string{`"Hunter2"`, "two "}},
+		{true, []string{"Hunter2", "two"}},
+	} {
+		var passwords []string
+		var stats Stats
+		err := Scan(strings.NewReader(content), Options{NormalizePasswords: tt.normalize}, &stats, func(entry Entry) error {
+			passwords = append(passwords, entry.Password)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(passwords, tt.want) {
+			t.Errorf("NormalizePasswords=%t: passwords = %q, want %q", tt.normalize, passwords, tt.want)
+		}
+	}
+}
+
+func TestNormalizeUsername(t *testing.T) {
+	for _, tt := range []struct {
+		username, mode, want string
+	}{
+		{"  Bob ", "", "  Bob "},
+		{"  Bob ", "off", "  Bob "},
+		{"  Bob ", UsernameTrim, "Bob"},
+		{"  Bob ", UsernameLower, "bob"},
+		{"\tAlice@Example.COM\r", UsernameLower, "alice@example.com"},
+	} {
+		if got := NormalizeUsername(tt.username, tt.mode); got != tt.want {
+			t.Errorf("NormalizeUsername(%q, %q) = %q, want %q", tt.username, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestScanNormalizeUsername(t *testing.T) {
+	// The colon parser keeps the spaces around usernames
+	content := "https://a.com:  Bob :Secret\nhttps://a.com:bob:Secret\n"
+
+	for _, tt := range []struct {
+		mode string
+		want []Entry
+	}{
+		{"", []Entry{
+			{URL: "https://a.com", Username: "  Bob ", Password: "Secret", Line: 1, End: Position{Offset: 28, Line: 1}},
+			{URL: "https://a.com", Username: "bob", Password: "Secret", Line: 2, End: Position{Offset: 53, Line: 2}},
+		}},
+		{UsernameLower, []Entry{
+			{URL: "https://a.com", Username: "bob", Password: "Secret", Line: 1, End: Position{Offset: 28, Line: 1}},
+			{URL: "https://a.com", Username: "bob", Password: "Secret", Line: 2, End: Position{Offset: 53, Line: 2}},
+		}},
+	} {
+		var entries []Entry
+		var stats Stats
+		opts := Options{Rule: &Rule{Pattern: "*", Parser: Colon}, NormalizeUsername: tt.mode}
+		err := Scan(strings.NewReader(content), opts, &stats, func(entry Entry) error {
+			entries = append(entries, entry)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(entries, tt.want) {
+			t.Errorf("NormalizeUsername=%q: entries = %+v, want %+v", tt.mode, entries, tt.want)
+		}
+	}
+}
+
+func TestScanKeepRaw(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		content string
+		opts    Options
+		want    []string
+	}{
+		{"off", "https://a.com:alice:one\n", Options{}, []string{""}},
+		{"before sanitizing", "https://a.com:al\x00ice: one \r\n", Options{KeepRaw: true}, []string{"https://a.com:al\x00ice: one "}},
+		{
+			"multi-line", "URL: https://b.com\n\nUsername: bob\nPassword: two\n",
+			Options{Rule: &Rule{Pattern: "*", Parser: Labeled}, KeepRaw: true},
+			[]string{"URL: https://b.com\nUsername: bob\nPassword: two"},
+		},
+	} {
+		var raw []string
+		var stats Stats
+		err := Scan(strings.NewReader(tt.content), tt.opts, &stats, func(entry Entry) error {
+			raw = append(raw, string(entry.Raw))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(raw, tt.want) {
+			t.Errorf("%s: raw lines = %q, want %q", tt.name, raw, tt.want)
+		}
+	}
+}
+
+func TestScanSkipLine(t *testing.T) {
+	content := "# header comment\nhttps://a.com:alice:one\n  // https://b.com:bob:two\nhttps://c.com:carol:three\n"
+	opts := Options{SkipLine: regexp.MustCompile(`^\s*(#|//)`)}
+
+	var users []string
+	var stats Stats
+	err := Scan(strings.NewReader(content), opts, &stats, func(entry Entry) error {
+		users = append(users, entry.Username)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"alice", "carol"}; !reflect.DeepEqual(users, want) {
+		t.Errorf("users = %v, want %v", users, want)
+	}
+	if stats.SkippedByRegex != 2 || stats.Total != 4 || stats.Skipped() != 2 {
+		t.Errorf("stats = %+v, want 2 of 4 lines skipped by the regex", stats)
+	}
+}
+
+func TestScanStripControlAndNullBytes(t *testing.T) {
+	content := "https://a.com:al\x00ice:one\nhttps://b.com:\x1bbob:two\x00\nhttps://c.com:carol:th\x07ree\n"
+
+	// Null bytes are counted rather than logged for every line
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	for _, tt := range []struct {
+		stripControl bool
+		want         []string
+	}{
+		{false, []string{"alice:one", "\x1bbob:two", "carol:th\x07ree"}},
+		{true, []string{"alice:one", "bob:two", "carol:three"}},
+	} {
+		var stats Stats
+		var got []string
+		err := Scan(strings.NewReader(content), Options{StripControl: tt.stripControl}, &stats, func(entry Entry) error {
+			got = append(got, entry.Username+":"+entry.Password)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("StripControl %t: entries = %q, want %q", tt.stripControl, got, tt.want)
+		}
+		if stats.NullByteLines != 2 {
+			t.Errorf("NullByteLines = %d, want 2", stats.NullByteLines)
+		}
+	}
+
+	if logs.Len() > 0 {
+		t.Errorf("Scan logged %q, want nothing", logs.String())
+	}
+}
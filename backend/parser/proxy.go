@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Proxy is a proxy from a proxy list, e.g. "1.2.3.4:8080:user:pass"
+type Proxy struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// ParseProxyLine recognizes proxy list lines that would otherwise be parsed
+// as credentials: "ip:port:user:pass" and a bare "host:port". Only IP
+// addresses are accepted as the host of a proxy with credentials, since
+// "site.com:8080:user:pass" is a regular entry for a site on a custom port.
+func ParseProxyLine(line string) (Proxy, bool) {
+	fields := strings.Split(strings.TrimSpace(line), ":")
+	if len(fields) != 2 && len(fields) < 4 {
+		return Proxy{}, false
+	}
+
+	port, ok := parseProxyPort(fields[1])
+	if !ok {
+		return Proxy{}, false
+	}
+	p := Proxy{Host: fields[0], Port: port}
+
+	if len(fields) == 2 {
+		if !isIPv4(p.Host) && !isProxyHostname(p.Host) {
+			return Proxy{}, false
+		}
+		return p, true
+	}
+
+	// The password may contain colons
+	if !isIPv4(p.Host) || fields[2] == "" {
+		return Proxy{}, false
+	}
+	p.Username = fields[2]
+	p.Password = strings.Join(fields[3:], ":")
+	return p, true
+}
+
+// parseProxyPort parses a TCP port written as plain digits
+func parseProxyPort(s string) (int, bool) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, false
+	}
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, false
+	}
+	return port, true
+}
+
+// isIPv4 reports whether s is a dotted IPv4 address
+func isIPv4(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() != nil && strings.Count(s, ".") == 3
+}
+
+// isProxyHostname reports whether s looks like a DNS name such as
+// "proxy.example.com", which rules out emails and usernames
+func isProxyHostname(s string) bool {
+	labels := strings.Split(s, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	// The top-level domain is never numeric
+	tld := labels[len(labels)-1]
+	return strings.TrimLeft(tld, "0123456789") != ""
+}
@@ -0,0 +1,37 @@
+package parser
+
+import "testing"
+
+func TestParseProxyLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		want  Proxy
+		proxy bool
+	}{
+		{"1.2.3.4:8080:user:pass", Proxy{Host: "1.2.3.4", Port: 8080, Username: "user", Password: "pass"}, true},
+		{"1.2.3.4:8080:user:pa:ss", Proxy{Host: "1.2.3.4", Port: 8080, Username: "user", Password: "pa:ss"}, true},
+		{"1.2.3.4:3128", Proxy{Host: "1.2.3.4", Port: 3128}, true},
+		{"proxy.example.com:1080", Proxy{Host: "proxy.example.com", Port: 1080}, true},
+		{" 10.0.0.1:80 ", Proxy{Host: "10.0.0.1", Port: 80}, true},
+		{"example.com:8080:user:pass", Proxy{}, false},
+		{"https://1.2.3.4:8080:user:pass", Proxy{}, false},
+		{"1.2.3.4:99999", Proxy{}, false},
+		{"1.2.3.4:0", Proxy{}, false},
+		{"1.2.3.4:+80", Proxy{}, false},
+		{"1.2.3.4:8080:user", Proxy{}, false},
+		{"1.2.3.4:8080::pass", Proxy{}, false},
+		{"1.2.3:8080", Proxy{}, false},
+		{"alice@mail.com:1234", Proxy{}, false},
+		{"alice:1234", Proxy{}, false},
+		{"site.123:80", Proxy{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, ok := ParseProxyLine(tt.line)
+			if ok != tt.proxy || got != tt.want {
+				t.Errorf("ParseProxyLine(%q) = %+v, %v, want %+v, %v", tt.line, got, ok, tt.want, tt.proxy)
+			}
+		})
+	}
+}
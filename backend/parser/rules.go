@@ -0,0 +1,303 @@
+package parser
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Parser names accepted in a rule
+const (
+	Auto    = "auto"
+	Colon   = "colon"
+	Pipe    = "pipe"
+	Labeled = "labeled"
+	JSON    = "json"
+	CSV     = "csv"
+	Regex   = "regex"
+)
+
+// Rule selects the parser used for files whose name matches Pattern
+type Rule struct {
+	// Pattern is a filepath.Match glob matched against the file name
+	Pattern string `json:"pattern"`
+	// Parser is one of colon, pipe, labeled, json, csv, regex or auto
+	Parser string `json:"parser"`
+	// Regex is used by the regex parser and needs username and password
+	// named groups, plus an optional url group
+	Regex string `json:"regex,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Compile validates the rule and compiles its regex. It must be called
+// before a rule with the regex parser is used.
+func (r *Rule) Compile() error {
+	if _, err := filepath.Match(r.Pattern, ""); err != nil || r.Pattern == "" {
+		return fmt.Errorf("invalid pattern %q", r.Pattern)
+	}
+
+	switch r.Parser {
+	case Auto, Colon, Pipe, Labeled, JSON, CSV:
+		return nil
+	case Regex:
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+		if re.SubexpIndex("username") < 0 || re.SubexpIndex("password") < 0 {
+			return fmt.Errorf("regex must have username and password named groups")
+		}
+		r.re = re
+		return nil
+	default:
+		return fmt.Errorf("unknown parser %q", r.Parser)
+	}
+}
+
+// lineParser splits a line into url, username and password. pending is true
+// when the line was consumed into an entry that spans several lines.
+type lineParser func(line string) (parts []string, pending bool)
+
+// newLineParser returns the parser for the rule of opts. Without a rule, or
+// for the auto parser, the SplitLine heuristics are used.
+func newLineParser(opts Options) lineParser {
+	rule, comboMode := opts.Rule, opts.ComboMode
+	if rule == nil {
+		rule = &Rule{Parser: Auto}
+	}
+
+	switch rule.Parser {
+	case Colon:
+		return stateless(splitColonLine)
+	case Pipe:
+		return stateless(splitPipeLine)
+	case Labeled:
+		return newLabeledParser()
+	case JSON:
+		return stateless(parseJSONLine)
+	case CSV:
+		if opts.CSVColumns != nil {
+			columns := *opts.CSVColumns
+			return stateless(func(line string) []string {
+				return parseCSVColumns(line, columns)
+			})
+		}
+		return stateless(parseCSVLine)
+	case Regex:
+		return stateless(func(line string) []string {
+			return parseWithRegexTemplate(rule.re, line)
+		})
+	default:
+		return stateless(func(line string) []string {
+			// Combolist lines have no URL
+			if comboMode && !strings.Contains(line, "://") {
+				return splitComboLine(line)
+			}
+			return SplitLine(line)
+		})
+	}
+}
+
+// stateless adapts a single line parser to a lineParser
+func stateless(split func(line string) []string) lineParser {
+	return func(line string) ([]string, bool) {
+		return split(line), false
+	}
+}
+
+// splitColonLine splits on the last two colons, so the URL may contain
+// colons but the username and password can't
+func splitColonLine(line string) []string {
+	passIdx := strings.LastIndex(line, ":")
+	if passIdx < 0 {
+		return nil
+	}
+	userIdx := strings.LastIndex(line[:passIdx], ":")
+	if userIdx < 0 {
+		return nil
+	}
+	return []string{strings.TrimSpace(line[:userIdx]), line[userIdx+1 : passIdx], line[passIdx+1:]}
+}
+
+// splitPipeLine splits url|username|password, the password may contain pipes
+func splitPipeLine(line string) []string {
+	parts := strings.SplitN(line, "|", 3)
+	if len(parts) < 3 {
+		return nil
+	}
+	return []string{strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), strings.TrimSpace(parts[2])}
+}
+
+// parseJSONLine parses a line holding a single JSON object
+func parseJSONLine(line string) []string {
+	var record struct {
+		URL      string `json:"url"`
+		Host     string `json:"host"`
+		Username string `json:"username"`
+		User     string `json:"user"`
+		Login    string `json:"login"`
+		Password string `json:"password"`
+		Pass     string `json:"pass"`
+	}
+	if err := json.Unmarshal([]byte(line), &record); err != nil {
+		return nil
+	}
+
+	url := firstNonEmpty(record.URL, record.Host)
+	username := firstNonEmpty(record.Username, record.User, record.Login)
+	password := firstNonEmpty(record.Password, record.Pass)
+	if username == "" && password == "" {
+		return nil
+	}
+	return []string{url, username, password}
+}
+
+// parseCSVLine parses a url,username,password CSV line, skipping a header row
+func parseCSVLine(line string) []string {
+	reader := csv.NewReader(strings.NewReader(line))
+	reader.FieldsPerRecord = -1
+	fields, err := reader.Read()
+	if err != nil || len(fields) < 3 {
+		return nil
+	}
+	if strings.EqualFold(strings.TrimSpace(fields[0]), "url") && strings.EqualFold(strings.TrimSpace(fields[1]), "username") {
+		return nil
+	}
+	return []string{strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1]), fields[2]}
+}
+
+// CSVColumns are the positions of the url, username and password columns of
+// a CSV file. URL is -1 when the file has no url column.
+type CSVColumns struct {
+	URL      int
+	Username int
+	Password int
+}
+
+// csvHeaderNames maps the accepted header names, in lowercase, to a column
+var csvHeaderNames = map[string]string{
+	"url":      "url",
+	"username": "username",
+	"user":     "username",
+	"login":    "username",
+	"email":    "username",
+	"password": "password",
+	"pass":     "password",
+}
+
+// ParseCSVHeader maps the fields of a CSV header row such as
+// url,username,password,created. Names are compared ignoring case and other
+// columns are ignored, but username and password are required and no column
+// may appear twice.
+func ParseCSVHeader(fields []string) (CSVColumns, error) {
+	found := map[string]int{}
+	for i, field := range fields {
+		column, ok := csvHeaderNames[strings.ToLower(strings.TrimSpace(field))]
+		if !ok {
+			continue
+		}
+		if _, dup := found[column]; dup {
+			return CSVColumns{}, fmt.Errorf("duplicate %s column", column)
+		}
+		found[column] = i
+	}
+
+	columns := CSVColumns{URL: -1}
+	var ok bool
+	if columns.Username, ok = found["username"]; !ok {
+		return CSVColumns{}, fmt.Errorf("missing username column")
+	}
+	if columns.Password, ok = found["password"]; !ok {
+		return CSVColumns{}, fmt.Errorf("missing password column")
+	}
+	if i, ok := found["url"]; ok {
+		columns.URL = i
+	}
+	return columns, nil
+}
+
+// parseCSVColumns parses a CSV line whose columns are mapped by columns
+func parseCSVColumns(line string, columns CSVColumns) []string {
+	reader := csv.NewReader(strings.NewReader(line))
+	reader.FieldsPerRecord = -1
+	fields, err := reader.Read()
+	if err != nil || len(fields) <= max(columns.URL, columns.Username, columns.Password) {
+		return nil
+	}
+	url := ""
+	if columns.URL >= 0 {
+		url = strings.TrimSpace(fields[columns.URL])
+	}
+	return []string{url, strings.TrimSpace(fields[columns.Username]), fields[columns.Password]}
+}
+
+// parseWithRegexTemplate extracts the url, username and password named
+// groups of re from line. It returns nil when the line doesn't match.
+func parseWithRegexTemplate(re *regexp.Regexp, line string) []string {
+	match := re.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+
+	group := func(name string) string {
+		if i := re.SubexpIndex(name); i >= 0 {
+			return match[i]
+		}
+		return ""
+	}
+	return []string{group("url"), group("username"), group("password")}
+}
+
+// newLabeledParser parses blocks of "Label: value" lines as written by
+// stealer logs, for example:
+//
+//	URL: https://example.com
+//	Username: alice
+//	Password: secret
+//
+// An entry is emitted once its password is read.
+func newLabeledParser() lineParser {
+	var url, username string
+	var inBlock bool
+
+	return func(line string) ([]string, bool) {
+		label, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, false
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(strings.TrimSpace(label)) {
+		case "url", "host", "site":
+			url, username, inBlock = value, "", true
+			return nil, true
+		case "username", "user", "login":
+			username, inBlock = value, true
+			return nil, true
+		case "password", "pass":
+			if !inBlock {
+				return nil, false
+			}
+			parts := []string{url, username, value}
+			url, username, inBlock = "", "", false
+			return parts, false
+		default:
+			// Other labels, such as the browser name, are ignored
+			return nil, true
+		}
+	}
+}
+
+// firstNonEmpty returns the first of values that isn't empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
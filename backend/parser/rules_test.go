@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseWithRegexTemplate(t *testing.T) {
+	rule := Rule{Pattern: "*", Parser: Regex, Regex: `^(?P<username>\S+) (?P<password>\S+)$`}
+	if err := rule.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := parseWithRegexTemplate(rule.re, "alice secret"), []string{"", "alice", "secret"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseWithRegexTemplate = %q, want %q", got, want)
+	}
+	if got := parseWithRegexTemplate(rule.re, "no-match"); got != nil {
+		t.Errorf("parseWithRegexTemplate(no-match) = %q, want nil", got)
+	}
+}
+
+func TestParseCSVHeader(t *testing.T) {
+	for _, tt := range []struct {
+		header  []string
+		want    CSVColumns
+		wantErr bool
+	}{
+		{[]string{"url", "username", "password", "created"}, CSVColumns{URL: 0, Username: 1, Password: 2}, false},
+		{[]string{"Password", "notes", " Email "}, CSVColumns{URL: -1, Username: 2, Password: 0}, false},
+		{[]string{"url", "username"}, CSVColumns{}, true},
+		{[]string{"user", "login", "pass"}, CSVColumns{}, true},
+		{[]string{"a", "b", "c"}, CSVColumns{}, true},
+	} {
+		got, err := ParseCSVHeader(tt.header)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCSVHeader(%q) error = %v, want error %t", tt.header, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("ParseCSVHeader(%q) = %+v, want %+v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestScanCSVColumns(t *testing.T) {
+	content := "password,username,url\n\"p,w\",alice,https://a.com\nshort\n"
+	opts := Options{
+		Rule:       &Rule{Pattern: "*", Parser: CSV},
+		CSVColumns: &CSVColumns{URL: 2, Username: 1, Password: 0},
+	}
+
+	var entries []Entry
+	var stats Stats
+	err := Scan(strings.NewReader(content), opts, &stats, func(entry Entry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	if e := entries[0]; e.URL != "https://a.com" || e.Username != "alice" || e.Password != "p,w" {
+		t.Errorf("entry = %+v, want mapped columns", e)
+	}
+	if stats.SkippedHeader != 1 {
+		t.Errorf("SkippedHeader = %d, want 1", stats.SkippedHeader)
+	}
+}
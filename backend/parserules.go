@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"hello-world/backend/parser"
+)
+
+// loadParseRules reads the rules file, a JSON object with a list of rules:
+//
+//	{"rules": [{"pattern": "*.csv.txt", "parser": "csv"}]}
+//
+// Rules are tried in order and the first match wins.
+func loadParseRules(path string) ([]parser.Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Rules []parser.Rule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid rules file: %w", err)
+	}
+
+	for i := range file.Rules {
+		if err := file.Rules[i].Compile(); err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i+1, file.Rules[i].Pattern, err)
+		}
+	}
+	return file.Rules, nil
+}
+
+// matchParseRule returns the first rule matching fileName, or nil
+func matchParseRule(rules []parser.Rule, fileName string) *parser.Rule {
+	for i := range rules {
+		if ok, _ := filepath.Match(rules[i].Pattern, fileName); ok {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// sameParseRules reports whether two rule lists are identical
+func sameParseRules(a, b []parser.Rule) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Pattern != b[i].Pattern || a[i].Parser != b[i].Parser || a[i].Regex != b[i].Regex {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"hello-world/backend/parser"
+)
+
+// writeRules writes a rules file and loads it
+func writeRules(t *testing.T, content string) ([]parser.Rule, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return loadParseRules(path)
+}
+
+func TestParseRulesSelectParser(t *testing.T) {
+	rules, err := writeRules(t, `{"rules": [
+		{"pattern": "colon-*.txt", "parser": "colon"},
+		{"pattern": "acme-*.txt", "parser": "regex", "regex": "^(?P<username>[^;]+);(?P<password>[^;]+);(?P<url>.+)$"},
+		{"pattern": "pipe-*.txt", "parser": "pipe"}
+	]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		fileName string
+		line     string
+		want     []string
+	}{
+		// The same line splits differently under the heuristic and the colon rule
+		{"dump.txt", "https://site.com:8080/login:alice:pa:ss", []string{"https://site.com:8080/login", "alice", "pa:ss"}},
+		{"colon-dump.txt", "https://site.com:8080/login:alice:pa:ss", []string{"https://site.com:8080/login:alice", "pa", "ss"}},
+		{"acme-1.txt", "alice;secret;https://acme.com", []string{"https://acme.com", "alice", "secret"}},
+		{"pipe-1.txt", "https://site.com | alice | se|cret", []string{"https://site.com", "alice", "se|cret"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			opts := parser.Options{Rule: matchParseRule(rules, tt.fileName)}
+			var stats ParseStats
+			var got []string
+			err := scanEntries(strings.NewReader(tt.line), Config{}, opts, &stats, func(entry parser.Entry) error {
+				got = []string{entry.URL, entry.Username, entry.Password}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parse(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRulesFormats(t *testing.T) {
+	tests := []struct {
+		parser  string
+		content string
+		want    []parser.Entry
+		skipped int
+	}{
+		{
+			parser: parser.Labeled,
+			content: "URL: https://a.com/login\nUsername: alice\nPassword: one\n" +
+				"Browser: Chrome\nURL: https://b.com\nLogin: bob\nPassword: two\nstray line\n",
+			want:    []parser.Entry{{URL: "https://a.com/login", Username: "alice", Password: "one", Line: 1}, {URL: "https://b.com", Username: "bob", Password: "two", Line: 4}},
+			skipped: 1,
+		},
+		{
+			parser:  parser.JSON,
+			content: `{"url": "https://a.com", "username": "alice", "password": "one"}` + "\n" + `{"host": "b.com", "login": "bob", "pass": "two"}` + "\nnot json\n",
+			want:    []parser.Entry{{URL: "https://a.com", Username: "alice", Password: "one", Line: 1}, {URL: "b.com", Username: "bob", Password: "two", Line: 2}},
+			skipped: 1,
+		},
+		{
+			parser:  parser.CSV,
+			content: "url,username,password\nhttps://a.com,alice,one\n\"https://b.com/?a,b\",bob,\"t,wo\"\n",
+			want:    []parser.Entry{{URL: "https://a.com", Username: "alice", Password: "one", Line: 2}, {URL: "https://b.com/?a,b", Username: "bob", Password: "t,wo", Line: 3}},
+			skipped: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.parser, func(t *testing.T) {
+			rule := &parser.Rule{Pattern: "*", Parser: tt.parser}
+			var stats ParseStats
+			var entries []parser.Entry
+			err := scanEntries(strings.NewReader(tt.content), Config{}, parser.Options{Rule: rule}, &stats, func(entry parser.Entry) error {
+				entry.End = parser.Position{}
+				entries = append(entries, entry)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(entries, tt.want) {
+				t.Errorf("entries = %+v, want %+v", entries, tt.want)
+			}
+			if stats.Skipped() != tt.skipped || stats.Parser != tt.parser {
+				t.Errorf("stats = %+v, want %d skipped by the %s parser", stats, tt.skipped, tt.parser)
+			}
+		})
+	}
+}
+
+func TestLoadParseRulesInvalid(t *testing.T) {
+	for _, content := range []string{
+		`{"rules": [{"pattern": "*.txt", "parser": "xml"}]}`,
+		`{"rules": [{"pattern": "[", "parser": "colon"}]}`,
+		`{"rules": [{"pattern": "*.txt", "parser": "regex", "regex": "(?P<user>.+)"}]}`,
+		`not json`,
+	} {
+		if _, err := writeRules(t, content); err == nil {
+			t.Errorf("loadParseRules(%s) succeeded, want an error", content)
+		}
+	}
+}
+
+func TestParseTestEndpoint(t *testing.T) {
+	rules, err := writeRules(t, `{"rules": [{"pattern": "pipe-*.txt", "parser": "pipe"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setConfig(Config{RequestTimeout: time.Minute, RejectValues: []string{"unknown"}, ParseRules: rules})
+	defer setConfig(Config{})
+
+	post := func(body string) (int, []parser.LineResult) {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/parse-test", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := newApp().Test(req, testConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var result struct {
+			Results []parser.LineResult `json:"results"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, result.Results
+	}
+
+	status, results := post(`{"line": "https://a.com:8080:alice:one", "lines": ["https://b.com:unknown:two", "nothing"]}`)
+	if status != 200 || len(results) != 3 {
+		t.Fatalf("status %d with %d results, want 200 with 3", status, len(results))
+	}
+	want := []parser.LineResult{
+		{Line: "https://a.com:8080:alice:one", Parts: []string{"https://a.com:8080", "alice", "one"}, Branch: parser.BranchPort, Accepted: true},
+		{Line: "https://b.com:unknown:two", Parts: []string{"https://b.com", "unknown", "two"}, Branch: parser.BranchPort, Reason: parser.ReasonPlaceholder},
+		{Line: "nothing", Parts: []string{"nothing"}, Branch: parser.BranchNone, Reason: parser.ReasonShort},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("results = %+v, want %+v", results, want)
+	}
+
+	// The file name picks the parse rule
+	_, results = post(`{"line": "https://a.com | alice | one", "fileName": "pipe-1.txt"}`)
+	if len(results) != 1 || results[0].Branch != parser.Pipe || !results[0].Accepted {
+		t.Errorf("results = %+v, want an accepted line split by the pipe parser", results)
+	}
+
+	lines, _ := json.Marshal(make([]string, maxParseTestLines+1))
+	if status, _ := post(`{"lines": ` + string(lines) + `}`); status != fiber.StatusRequestEntityTooLarge {
+		t.Errorf("status for too many lines = %d, want 413", status)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// hashPassword returns the hex encoded HMAC-SHA256 of a password keyed with
+// the server secret. The same password always hashes to the same value, so
+// exact lookups still work without keeping the plaintext.
+func hashPassword(secret, password string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(password))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// storedPassword returns the value stored for a password, its hash with
+// HASH_PASSWORDS and the password itself otherwise
+func storedPassword(cfg Config, password string) string {
+	if !cfg.HashPasswords {
+		return password
+	}
+	return hashPassword(cfg.PasswordHashSecret, password)
+}
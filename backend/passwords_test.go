@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStoredPassword(t *testing.T) {
+	plain := Config{}
+	if got := storedPassword(plain, "hunter2"); got != "hunter2" {
+		t.Errorf("storedPassword without hashing = %q, want the plaintext", got)
+	}
+
+	hashed := Config{HashPasswords: true, PasswordHashSecret: "secret"}
+	got := storedPassword(hashed, "hunter2")
+	if got == "hunter2" || len(got) != 64 {
+		t.Errorf("storedPassword with hashing = %q, want a hex HMAC-SHA256", got)
+	}
+	if again := storedPassword(hashed, "hunter2"); again != got {
+		t.Errorf("hashing is not deterministic: %q != %q", again, got)
+	}
+	if other := hashPassword("other", "hunter2"); other == got {
+		t.Error("hash doesn't depend on the secret")
+	}
+}
+
+func TestSearchPasswordsHashed(t *testing.T) {
+	setConfig(Config{RequestTimeout: time.Minute, HashPasswords: true, PasswordHashSecret: "secret"})
+	defer setConfig(Config{})
+
+	if status, _ := postSearch(t, `{"passwords": ["hunter2"]}`); status != 409 {
+		t.Errorf("password search with hashed passwords = %d, want 409", status)
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	setupTestDB(t)
+	cfg := currentConfig()
+	cfg.HashPasswords = true
+	cfg.PasswordHashSecret = "secret"
+	setConfig(cfg)
+
+	insertTestEntries(t,
+		Entry{URL: "https://a.com", User: "alice", Pass: storedPassword(cfg, "hunter2")},
+		Entry{URL: "https://b.com", User: "bob", Pass: storedPassword(cfg, "hunter2")},
+		Entry{URL: "https://c.com", User: "carol", Pass: storedPassword(cfg, "letmein")},
+	)
+
+	for _, tt := range []struct {
+		pass  string
+		found bool
+		count int
+	}{
+		{"hunter2", true, 2},
+		{"letmein", true, 1},
+		{"nope", false, 0},
+	} {
+		var body struct {
+			Found bool `json:"found"`
+			Count int  `json:"count"`
+		}
+		if status := getJSON(t, "/api/check?pass="+tt.pass, &body); status != 200 {
+			t.Fatalf("GET /api/check status = %d", status)
+		}
+		if body.Found != tt.found || body.Count != tt.count {
+			t.Errorf("check %q = %+v, want found %v count %d", tt.pass, body, tt.found, tt.count)
+		}
+	}
+
+	var errBody ErrorResponse
+	if status := getJSON(t, "/api/check", &errBody); status != 400 {
+		t.Errorf("check without pass = %d, want 400", status)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import "context"
+
+// progressKey is the context key of the progress callback of an import
+type progressKey struct{}
+
+// withProgress returns a context under which processReader calls onProgress
+// with the number of entries sent to the database after every batch, so
+// callers can report progress of long imports
+func withProgress(ctx context.Context, onProgress func(entriesSoFar int)) context.Context {
+	return context.WithValue(ctx, progressKey{}, onProgress)
+}
+
+// reportProgress calls the progress callback of ctx, if there's one
+func reportProgress(ctx context.Context, entriesSoFar int) {
+	if onProgress, ok := ctx.Value(progressKey{}).(func(int)); ok && onProgress != nil {
+		onProgress(entriesSoFar)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"hello-world/backend/parser"
+)
+
+func TestReportProgress(t *testing.T) {
+	// Without a callback, or with a nil one, nothing happens
+	reportProgress(context.Background(), 1)
+	reportProgress(withProgress(context.Background(), nil), 1)
+
+	var counts []int
+	ctx := withProgress(context.Background(), func(entriesSoFar int) {
+		counts = append(counts, entriesSoFar)
+	})
+	reportProgress(ctx, 2)
+	reportProgress(ctx, 4)
+	if want := []int{2, 4}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("progress = %v, want %v", counts, want)
+	}
+}
+
+func TestProcessReaderProgress(t *testing.T) {
+	setupTestDB(t)
+
+	c := currentConfig()
+	c.BatchSize = 2
+	setConfig(c)
+
+	var counts []int
+	ctx := withProgress(context.Background(), func(entriesSoFar int) {
+		counts = append(counts, entriesSoFar)
+	})
+	input := "https://a.com:u1:p\nhttps://a.com:u2:p\nhttps://a.com:u3:p\nhttps://a.com:u4:p\nhttps://a.com:u5:p\n"
+	if _, err := processReader(ctx, strings.NewReader(input), "progress.txt", parser.Options{}, nil); err != nil {
+		t.Fatalf("processReader failed: %v", err)
+	}
+
+	// Once per batch, the last one partial
+	if want := []int{2, 4, 5}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("progress = %v, want %v", counts, want)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"hello-world/backend/parser"
+)
+
+// insertProxies copies a batch of proxies into the proxies table
+func insertProxies(ctx context.Context, tx pgx.Tx, proxies []parser.Proxy, created string) error {
+	rows := make([][]any, len(proxies))
+	for i, p := range proxies {
+		rows[i] = []any{p.Host, p.Port, p.Username, p.Password, created}
+	}
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"proxies"},
+		[]string{"host", "port", "username", "password", "created"}, pgx.CopyFromRows(rows))
+	return err
+}
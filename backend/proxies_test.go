@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"hello-world/backend/parser"
+)
+
+func TestScanEntriesProxyLines(t *testing.T) {
+	content := strings.Join([]string{
+		"1.2.3.4:8080:user:pass",
+		"proxy.example.com:3128",
+		"https://a.com:alice:secret",
+		"https://b.com:8443:bob:secret",
+	}, "\n")
+
+	scan := func(t *testing.T, content string, opts parser.Options) ([]parser.Entry, ParseStats) {
+		t.Helper()
+		var stats ParseStats
+		var entries []parser.Entry
+		err := scanEntries(strings.NewReader(content), Config{}, opts, &stats, func(entry parser.Entry) error {
+			entry.End = parser.Position{}
+			entries = append(entries, entry)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("scanEntries returned error: %v", err)
+		}
+		return entries, stats
+	}
+
+	t.Run("proxies are skipped by default", func(t *testing.T) {
+		entries, stats := scan(t, content, parser.Options{})
+		if stats.SkippedProxy != 2 || stats.Proxies != 0 || stats.Skipped() != 2 {
+			t.Errorf("stats = %+v, want 2 skipped proxies", stats)
+		}
+		expected := []parser.Entry{
+			{URL: "https://a.com", Username: "alice", Password: "secret", Line: 3},
+			{URL: "https://b.com:8443", Username: "bob", Password: "secret", Line: 4},
+		}
+		if !reflect.DeepEqual(entries, expected) {
+			t.Errorf("entries = %+v, want %+v", entries, expected)
+		}
+	})
+
+	t.Run("proxies are passed to OnProxy", func(t *testing.T) {
+		var proxies []parser.Proxy
+		_, stats := scan(t, content, parser.Options{OnProxy: func(p parser.Proxy) error {
+			proxies = append(proxies, p)
+			return nil
+		}})
+		if stats.Proxies != 2 || stats.SkippedProxy != 0 {
+			t.Errorf("stats = %+v, want 2 proxies", stats)
+		}
+		expected := []parser.Proxy{
+			{Host: "1.2.3.4", Port: 8080, Username: "user", Password: "pass"},
+			{Host: "proxy.example.com", Port: 3128},
+		}
+		if !reflect.DeepEqual(proxies, expected) {
+			t.Errorf("proxies = %+v, want %+v", proxies, expected)
+		}
+	})
+
+	t.Run("hostnames in a combolist are credentials", func(t *testing.T) {
+		entries, stats := scan(t, "john.doe:1234\njane.doe:5678\n10.0.0.1:8080\n", parser.Options{})
+		if !stats.ComboMode || stats.SkippedProxy != 1 || len(entries) != 2 {
+			t.Errorf("stats = %+v, entries = %+v, want 2 entries and 1 skipped proxy", stats, entries)
+		}
+	})
+
+	t.Run("parse rules turn detection off", func(t *testing.T) {
+		rule := &parser.Rule{Parser: parser.Colon}
+		entries, stats := scan(t, "1.2.3.4:8080:user:pass\n", parser.Options{Rule: rule})
+		if stats.SkippedProxy != 0 || len(entries) != 1 {
+			t.Errorf("stats = %+v, entries = %+v, want the line parsed by the rule", stats, entries)
+		}
+	})
+}
+
+func TestProcessLogFileProxyIngestion(t *testing.T) {
+	setupTestDB(t)
+
+	c := currentConfig()
+	c.ProxyIngestion = true
+	setConfig(c)
+
+	content := "1.2.3.4:8080:user:pass\n5.6.7.8:3128\nhttps://a.com:alice:secret\n"
+	filePath := filepath.Join(t.TempDir(), "proxies.txt")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := processLogFile(context.Background(), filePath, parser.Options{}, nil)
+	if err != nil {
+		t.Fatalf("processLogFile failed: %v", err)
+	}
+	if stats.Proxies != 2 || stats.Inserted != 1 {
+		t.Errorf("stored %d proxies and %d entries, want 2 and 1", stats.Proxies, stats.Inserted)
+	}
+
+	rows, err := dbPool.Query(context.Background(), "SELECT host, port, username, password FROM proxies ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var proxies []parser.Proxy
+	for rows.Next() {
+		var p parser.Proxy
+		if err := rows.Scan(&p.Host, &p.Port, &p.Username, &p.Password); err != nil {
+			t.Fatal(err)
+		}
+		proxies = append(proxies, p)
+	}
+	expected := []parser.Proxy{
+		{Host: "1.2.3.4", Port: 8080, Username: "user", Password: "pass"},
+		{Host: "5.6.7.8", Port: 3128},
+	}
+	if !reflect.DeepEqual(proxies, expected) {
+		t.Errorf("proxies = %+v, want %+v", proxies, expected)
+	}
+}
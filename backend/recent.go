@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// recentImportsSize is the number of processed files kept by recentImports
+const recentImportsSize = 50
+
+// RecentImport is a processed file as listed by GET /recent
+type RecentImport struct {
+	Filename   string    `json:"filename"`
+	Entries    int       `json:"entriesAdded"`
+	DurationMs int64     `json:"durationMs"`
+	ImportedAt time.Time `json:"importedAt"`
+}
+
+// recentBuffer is a fixed-size ring buffer of the latest imports, safe for
+// concurrent use. Once full, each new import replaces the oldest one.
+type recentBuffer struct {
+	mu    sync.Mutex
+	items []RecentImport
+	// next is where the next import is written
+	next int
+	full bool
+}
+
+// newRecentBuffer returns a buffer holding the last size imports
+func newRecentBuffer(size int) *recentBuffer {
+	return &recentBuffer{items: make([]RecentImport, size)}
+}
+
+// recentImports lists the latest processed files without a database query
+var recentImports = newRecentBuffer(recentImportsSize)
+
+// add records an import, evicting the oldest one when the buffer is full
+func (b *recentBuffer) add(item RecentImport) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.items[b.next] = item
+	b.next = (b.next + 1) % len(b.items)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// list returns a copy of the buffered imports, the most recent first
+func (b *recentBuffer) list() []RecentImport {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := b.next
+	if b.full {
+		n = len(b.items)
+	}
+	result := make([]RecentImport, 0, n)
+	for i := 1; i <= n; i++ {
+		result = append(result, b.items[(b.next-i+len(b.items))%len(b.items)])
+	}
+	return result
+}
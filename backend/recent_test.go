@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestRecentBuffer(t *testing.T) {
+	b := newRecentBuffer(3)
+	if got := b.list(); len(got) != 0 {
+		t.Errorf("empty buffer lists %+v", got)
+	}
+
+	names := func() []string {
+		var result []string
+		for _, item := range b.list() {
+			result = append(result, item.Filename)
+		}
+		return result
+	}
+	for i := 1; i <= 2; i++ {
+		b.add(RecentImport{Filename: fmt.Sprintf("file%d.txt", i)})
+	}
+	if want := []string{"file2.txt", "file1.txt"}; !reflect.DeepEqual(names(), want) {
+		t.Errorf("names = %v, want %v", names(), want)
+	}
+
+	// The oldest imports are evicted once the buffer is full
+	for i := 3; i <= 5; i++ {
+		b.add(RecentImport{Filename: fmt.Sprintf("file%d.txt", i)})
+	}
+	if want := []string{"file5.txt", "file4.txt", "file3.txt"}; !reflect.DeepEqual(names(), want) {
+		t.Errorf("names = %v, want %v", names(), want)
+	}
+}
+
+// Run with -race to check the buffer is safe for concurrent use
+func TestRecentImportsConcurrent(t *testing.T) {
+	previous := recentImports
+	recentImports = newRecentBuffer(recentImportsSize)
+	defer func() { recentImports = previous }()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				recentImports.add(RecentImport{Filename: fmt.Sprintf("worker%d-%d.txt", w, i), Entries: i})
+			}
+		}()
+	}
+
+	for i := 0; i < 20; i++ {
+		var result struct {
+			Imports []RecentImport `json:"imports"`
+		}
+		if status := getJSON(t, "/api/recent", &result); status != 200 {
+			t.Fatalf("status = %d, want 200", status)
+		}
+		if len(result.Imports) > recentImportsSize {
+			t.Fatalf("listed %d imports, want at most %d", len(result.Imports), recentImportsSize)
+		}
+	}
+	wg.Wait()
+
+	if got := len(recentImports.list()); got != recentImportsSize {
+		t.Errorf("listed %d imports after the writers finished, want %d", got, recentImportsSize)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// insertRetryAttempts is how many times a file import is attempted when
+	// the database connection fails
+	insertRetryAttempts = 5
+	// maxRetryDelay caps the exponential backoff between attempts
+	maxRetryDelay = 30 * time.Second
+)
+
+// insertRetryDelay is the wait before the first retry, doubled on every attempt
+var insertRetryDelay = 500 * time.Millisecond
+
+// isTransientError reports whether a database error is caused by a lost or
+// refused connection, so the operation can succeed when it's retried.
+// Errors reported by the server for the statement itself are not transient.
+func isTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		// Class 08 is connection exceptions
+		return len(pgErr.Code) == 5 && pgErr.Code[:2] == "08"
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return pgconn.SafeToRetry(err) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// retryTransient calls fn until it succeeds, fails with an error that isn't
+// transient, or has been called attempts times. The wait between attempts
+// starts at delay and doubles every time.
+func retryTransient(ctx context.Context, attempts int, delay time.Duration, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || !isTransientError(err) || attempt >= attempts {
+			return err
+		}
+
+		log.Printf("Transient database error (attempt %d of %d), retrying in %s: %v", attempt, attempts, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
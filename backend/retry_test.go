@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"hello-world/backend/parser"
+)
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"connection failure", fmt.Errorf("batch execution failed: %w", &pgconn.PgError{Code: "08006"}), true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"connection reset", fmt.Errorf("batch execution failed: %w", os.NewSyscallError("read", syscall.ECONNRESET)), true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"file error", os.ErrNotExist, false},
+		{"cancelled", context.Canceled, false},
+	}
+
+	for _, tt := range tests {
+		if got := isTransientError(tt.err); got != tt.want {
+			t.Errorf("%s: isTransientError = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryTransient(t *testing.T) {
+	ctx := context.Background()
+	transient := &pgconn.PgError{Code: "57P01"}
+
+	calls := 0
+	err := retryTransient(ctx, 5, time.Millisecond, func() error {
+		calls++
+		if calls < 3 {
+			return transient
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("transient errors: err = %v after %d calls, want success after 3", err, calls)
+	}
+
+	calls = 0
+	err = retryTransient(ctx, 5, time.Millisecond, func() error {
+		calls++
+		return &pgconn.PgError{Code: "23505"}
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("constraint violation: err = %v after %d calls, want failure after 1", err, calls)
+	}
+
+	calls = 0
+	err = retryTransient(ctx, 3, time.Millisecond, func() error {
+		calls++
+		return transient
+	})
+	if !errors.Is(err, transient) || calls != 3 {
+		t.Errorf("persistent failure: err = %v after %d calls, want failure after 3", err, calls)
+	}
+}
+
+// terminateConnections kills the connections to the test database, such as
+// the one an import holds while it reports progress. The pool drops them,
+// the import has to reconnect.
+func terminateConnections(t *testing.T) {
+	t.Helper()
+
+	_, err := dbPool.Exec(context.Background(),
+		"SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = current_database() AND backend_type = 'client backend' AND pid <> pg_backend_pid()")
+	if err != nil {
+		t.Errorf("failed to terminate connections: %v", err)
+	}
+	dbPool.Reset()
+}
+
+func TestProcessLogFileRetriesDroppedConnection(t *testing.T) {
+	setupTestDB(t)
+	useDedupeScope(t, dedupeNone)
+
+	cfg := currentConfig()
+	cfg.BatchSize = 2
+	setConfig(cfg)
+
+	delay := insertRetryDelay
+	insertRetryDelay = 10 * time.Millisecond
+	defer func() { insertRetryDelay = delay }()
+
+	// Kill the import's connection once, after its first batch was
+	// committed, so the retry resumes from the checkpoint
+	killed := false
+	ctx := withProgress(context.Background(), func(int) {
+		if !killed {
+			killed = true
+			terminateConnections(t)
+		}
+	})
+
+	var lines []string
+	for i := 0; i < 7; i++ {
+		lines = append(lines, fmt.Sprintf("https://site%d.com:user:pass", i))
+	}
+	filePath := filepath.Join(t.TempDir(), "retry.txt")
+	if err := os.WriteFile(filePath, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := processLogFile(ctx, filePath, parser.Options{}, nil)
+	if err != nil {
+		t.Fatalf("processLogFile failed: %v", err)
+	}
+	if !killed {
+		t.Fatal("connection was never terminated")
+	}
+	if stats.Inserted != 7 {
+		t.Errorf("inserted = %d, want 7", stats.Inserted)
+	}
+	if got := countEntries(t); got != 7 {
+		t.Errorf("entries = %d, want 7 with no partial or duplicate import", got)
+	}
+}
+
+func TestRetryResumes(t *testing.T) {
+	dir := t.TempDir()
+	utf8Path := filepath.Join(dir, "utf8.txt")
+	utf16Path := filepath.Join(dir, "utf16.txt")
+	if err := os.WriteFile(utf8Path, []byte("https://a.com:alice:one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(utf16Path, []byte("\xff\xfeh\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		cfg  Config
+		want bool
+	}{
+		{"checkpointed", utf8Path, Config{Sink: sinkPostgres}, true},
+		{"UTF-16", utf16Path, Config{Sink: sinkPostgres}, false},
+		{"UTF-16 atomic", utf16Path, Config{Sink: sinkPostgres, AtomicImport: true}, true},
+		{"UTF-16 into a file", utf16Path, Config{Sink: sinkFile}, true},
+		{"missing", filepath.Join(dir, "missing.txt"), Config{Sink: sinkPostgres}, true},
+	}
+	for _, tt := range tests {
+		if got := retryResumes(tt.path, tt.cfg); got != tt.want {
+			t.Errorf("%s: retryResumes = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProcessLogFileDoesNotRetryUTF16(t *testing.T) {
+	setupTestDB(t)
+	useDedupeScope(t, dedupeNone)
+
+	cfg := currentConfig()
+	cfg.BatchSize = 2
+	setConfig(cfg)
+
+	delay := insertRetryDelay
+	insertRetryDelay = 10 * time.Millisecond
+	defer func() { insertRetryDelay = delay }()
+
+	killed := false
+	ctx := withProgress(context.Background(), func(int) {
+		if !killed {
+			killed = true
+			terminateConnections(t)
+		}
+	})
+
+	// The committed batches have no checkpoint to resume from, so a retry
+	// would insert them again
+	content := "\xff\xfe"
+	for i := 0; i < 7; i++ {
+		for _, r := range fmt.Sprintf("https://site%d.com:user:pass\n", i) {
+			content += string([]byte{byte(r), 0})
+		}
+	}
+	filePath := filepath.Join(t.TempDir(), "retry-utf16.txt")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := processLogFile(ctx, filePath, parser.Options{}, nil); err == nil {
+		t.Fatal("expected the dropped connection to fail the import")
+	}
+	if got := countEntries(t); got > 2 {
+		t.Errorf("entries = %d, want at most the first batch", got)
+	}
+}
@@ -0,0 +1,506 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"hello-world/backend/parser"
+)
+
+// searchFilter describes a search. GET /search fills it from the query
+// string and POST /search from a JSON body. Multiple values of the same
+// filter match any of them.
+type searchFilter struct {
+	Q                 string   `json:"q"`
+	URLs              []string `json:"urls"`
+	Users             []string `json:"users"`
+	Passwords         []string `json:"passwords"`
+	Domains           []string `json:"domains"`
+	IncludeSubdomains bool     `json:"includeSubdomains"`
+	ExcludeURLs       []string `json:"excludeUrls"`
+	ExcludeUsers      []string `json:"excludeUsers"`
+	ExcludeDomains    []string `json:"excludeDomains"`
+	Tags              []string `json:"tags"`
+	// Kinds keeps entries classified as any of the kinds, see classify
+	Kinds []string `json:"kinds"`
+	// From and To limit the created date (YYYY-MM-DD), both inclusive
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"pageSize"`
+	// ExactCount set to false allows an estimated total when nothing is filtered
+	ExactCount bool `json:"exactCount"`
+	// AllowPartial returns the entries found so far instead of an error when
+	// the search runs out of time
+	AllowPartial bool `json:"allowPartial"`
+	// CountOnly responds with the total alone, without fetching a page
+	CountOnly bool `json:"countOnly"`
+
+	// hashedPasswords is set with HASH_PASSWORDS, when passwords can only be
+	// matched exactly through /check
+	hashedPasswords bool
+}
+
+// searchQuery accumulates the conditions and parameters of a search
+type searchQuery struct {
+	conditions []string
+	params     []interface{}
+}
+
+// param adds a query parameter and returns its placeholder number
+func (q *searchQuery) param(value interface{}) int {
+	q.params = append(q.params, value)
+	return len(q.params)
+}
+
+// likeAny adds a case-insensitive substring match of column against any of values
+func (q *searchQuery) likeAny(column string, values []string) {
+	var matches []string
+	for _, v := range values {
+		if v = strings.ToLower(v); v != "" {
+			matches = append(matches, fmt.Sprintf("LOWER(%s) LIKE $%d", column, q.param("%"+v+"%")))
+		}
+	}
+
+	if len(matches) > 0 {
+		q.conditions = append(q.conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+}
+
+// notLikeAny adds a case-insensitive substring match of column that must not
+// match any of values
+func (q *searchQuery) notLikeAny(column string, values []string) {
+	for _, v := range values {
+		if v = strings.ToLower(v); v != "" {
+			q.conditions = append(q.conditions, fmt.Sprintf("LOWER(%s) NOT LIKE $%d", column, q.param("%"+v+"%")))
+		}
+	}
+}
+
+// where returns the WHERE clause for the accumulated conditions, which
+// always leaves out soft-deleted entries
+func (q *searchQuery) where() string {
+	return " WHERE " + strings.Join(append([]string{liveEntries}, q.conditions...), " AND ")
+}
+
+// buildSearchQuery turns a search filter into parameterized SQL conditions
+func buildSearchQuery(f searchFilter) (*searchQuery, error) {
+	q := &searchQuery{}
+
+	if query := strings.ToLower(f.Q); query != "" {
+		pattern := "%" + query + "%"
+		if f.hashedPasswords {
+			q.conditions = append(q.conditions, fmt.Sprintf("(LOWER(url) LIKE $%d OR LOWER(username) LIKE $%d)",
+				q.param(pattern), q.param(pattern)))
+		} else {
+			q.conditions = append(q.conditions, fmt.Sprintf("(LOWER(url) LIKE $%d OR LOWER(username) LIKE $%d OR LOWER(password) LIKE $%d)",
+				q.param(pattern), q.param(pattern), q.param(pattern)))
+		}
+	}
+
+	q.likeAny("url", f.URLs)
+	q.likeAny("username", f.Users)
+	q.likeAny("password", f.Passwords)
+
+	// Any of the domains, as one array parameter however long the list is
+	var domains, subdomains []string
+	for _, d := range f.Domains {
+		if d = parser.ExtractDomain(d); d != "" && !slices.Contains(domains, d) {
+			domains = append(domains, d)
+			subdomains = append(subdomains, "%."+d)
+		}
+	}
+	if len(domains) > 0 {
+		if f.IncludeSubdomains {
+			q.conditions = append(q.conditions, fmt.Sprintf("(domain = ANY($%d::text[]) OR domain LIKE ANY($%d::text[]))",
+				q.param(domains), q.param(subdomains)))
+		} else {
+			q.conditions = append(q.conditions, fmt.Sprintf("domain = ANY($%d::text[])", q.param(domains)))
+		}
+	}
+
+	q.notLikeAny("url", f.ExcludeURLs)
+	q.notLikeAny("username", f.ExcludeUsers)
+
+	var excluded []string
+	for _, d := range f.ExcludeDomains {
+		if d = parser.ExtractDomain(d); d != "" {
+			excluded = append(excluded, fmt.Sprintf("$%d", q.param(d)))
+		}
+	}
+	if len(excluded) > 0 {
+		q.conditions = append(q.conditions, "domain NOT IN ("+strings.Join(excluded, ", ")+")")
+	}
+
+	// Entries with any of the tags, the array overlap can use the GIN index
+	if tags := normalizeTags(f.Tags); len(tags) > 0 {
+		q.conditions = append(q.conditions, fmt.Sprintf("tags && $%d::text[]", q.param(tags)))
+	}
+
+	var kinds []string
+	for _, kind := range f.Kinds {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if !slices.Contains(entryKinds, kind) {
+			return nil, fmt.Errorf("invalid kind %q, expected one of %s", kind, strings.Join(entryKinds, ", "))
+		}
+		kinds = append(kinds, kind)
+	}
+	if len(kinds) > 0 {
+		q.conditions = append(q.conditions, fmt.Sprintf("kind = ANY($%d::text[])", q.param(kinds)))
+	}
+
+	// Dates are stored as YYYY-MM-DD text, which sorts chronologically
+	if f.From != "" {
+		if _, err := time.Parse("2006-01-02", f.From); err != nil {
+			return nil, fmt.Errorf("invalid from date %q, expected YYYY-MM-DD", f.From)
+		}
+		q.conditions = append(q.conditions, fmt.Sprintf("created >= $%d", q.param(f.From)))
+	}
+	if f.To != "" {
+		if _, err := time.Parse("2006-01-02", f.To); err != nil {
+			return nil, fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", f.To)
+		}
+		q.conditions = append(q.conditions, fmt.Sprintf("created <= $%d", q.param(f.To)))
+	}
+
+	return q, nil
+}
+
+// domainCondition returns the SQL condition matching an exact domain held in
+// parameter $n, optionally including any of its subdomains
+func domainCondition(n int, includeSubdomains bool) string {
+	if includeSubdomains {
+		return fmt.Sprintf("(domain = $%d OR domain LIKE '%%.' || $%d)", n, n)
+	}
+	return fmt.Sprintf("domain = $%d", n)
+}
+
+// normalizeTags trims and lowercases tags, dropping empty and repeated ones
+func normalizeTags(tags []string) []string {
+	normalized := []string{}
+	for _, tag := range tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// nonEmpty returns value as a single item list, or nil when it's empty
+func nonEmpty(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return []string{value}
+}
+
+// searchFilterFromQuery reads the filter of GET /search from the query string
+func searchFilterFromQuery(c fiber.Ctx) (searchFilter, error) {
+	page, pageSize, _, err := parsePagination(c)
+	if err != nil {
+		return searchFilter{}, err
+	}
+
+	return searchFilter{
+		Q:                 c.Query("q", ""),
+		URLs:              nonEmpty(c.Query("url", "")),
+		Users:             nonEmpty(c.Query("user", "")),
+		Passwords:         nonEmpty(c.Query("pass", "")),
+		Domains:           append(nonEmpty(c.Query("domain", "")), parseListSetting(c.Query("domains", ""))...),
+		IncludeSubdomains: c.Query("includeSubdomains", "false") == "true",
+		ExcludeURLs:       nonEmpty(c.Query("excludeUrl", "")),
+		ExcludeUsers:      nonEmpty(c.Query("excludeUser", "")),
+		ExcludeDomains:    nonEmpty(c.Query("excludeDomain", "")),
+		Tags:              nonEmpty(c.Query("tag", "")),
+		Kinds:             nonEmpty(c.Query("kind", "")),
+		ExactCount:        c.Query("exactCount", "true") != "false",
+		AllowPartial:      c.Query("allowPartial", "false") == "true",
+		CountOnly:         c.Query("countOnly", "false") == "true",
+		Page:              page,
+		PageSize:          pageSize,
+	}, nil
+}
+
+// searchPageSQL returns the query of one page of a search, the page size and
+// offset are the two parameters after those of q
+func searchPageSQL(q *searchQuery) string {
+	return fmt.Sprintf("SELECT %s FROM entries%s ORDER BY id DESC LIMIT $%d OFFSET $%d",
+		entryColumns, q.where(), len(q.params)+1, len(q.params)+2)
+}
+
+// searchCountSQL returns the query counting every match of a search
+func searchCountSQL(q *searchQuery) string {
+	return "SELECT COUNT(*) FROM entries" + q.where()
+}
+
+// runSearch executes a search and responds with one page of matching entries
+func runSearch(c fiber.Ctx, f searchFilter) error {
+	f.hashedPasswords = currentConfig().HashPasswords
+	if f.hashedPasswords && len(f.Passwords) > 0 {
+		return jsonError(c, fiber.StatusConflict, errCodeConflict,
+			"Passwords are stored hashed, use /api/check for exact password lookups", "")
+	}
+
+	q, err := buildSearchQuery(f)
+	if err != nil {
+		return jsonError(c, fiber.StatusBadRequest, errCodeBadRequest, "Invalid search filter", err.Error())
+	}
+
+	if f.CountOnly {
+		return countSearch(c, q, f.ExactCount)
+	}
+
+	page, pageSize, offset := normalizePagination(f.Page, f.PageSize)
+	ctx := c.Context()
+
+	// A partial search has to stop before REQUEST_TIMEOUT cancels the whole
+	// request, leaving time for the count after the page was fetched
+	cfg := currentConfig()
+	timeout := cfg.SearchTimeout
+	if f.AllowPartial {
+		timeout = min(timeout, cfg.RequestTimeout/3)
+	}
+
+	result, err := fetchSearchPage(ctx, q, pageSize, offset, f.ExactCount, f.AllowPartial, timeout)
+	if err != nil {
+		return serverError(c, "Failed to search database", err)
+	}
+
+	// Create pagination response
+	response := newPaginationResponse(result.entries, result.total, page, pageSize)
+	response.Approximate = result.approximate
+	if result.partial {
+		response.Partial = true
+		response.Warning = "The search timed out, results are incomplete"
+	}
+	return c.JSON(response)
+}
+
+// searchPage is one page of search results and the total number of matches
+type searchPage struct {
+	entries     []Entry
+	total       int
+	approximate bool
+	// partial is set when allowPartial was given and the search timed out
+	partial bool
+}
+
+// fetchSearchPage runs the page and count queries of q under timeout. With
+// allowPartial, a timeout returns the entries read until then with partial
+// set instead of an error, and the total then only counts those.
+func fetchSearchPage(ctx context.Context, q *searchQuery, pageSize, offset int, exact, allowPartial bool, timeout time.Duration) (searchPage, error) {
+	var result searchPage
+	err := withSearchTimeout(ctx, timeout, func(tx pgx.Tx) error {
+		// PostgreSQL automatically caches execution plans for parameterized queries
+		rows, err := tx.Query(ctx, searchPageSQL(q), append(q.params, pageSize, offset)...)
+		if err != nil {
+			return err
+		}
+		result.entries, result.partial, err = collectEntries(rows, allowPartial)
+		if err != nil || result.partial {
+			return err
+		}
+
+		// Get total count for pagination metadata
+		result.total, result.approximate, err = countMatchingEntries(ctx, tx, q, exact)
+		if allowPartial && isStatementTimeout(err) {
+			result.partial = true
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to count filtered entries: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return searchPage{}, err
+	}
+
+	// Without a count the total is only known to include what was found
+	if result.partial {
+		result.total = offset + len(result.entries)
+		result.approximate = true
+	}
+	return result, nil
+}
+
+// countSearch responds with the number of entries matching q without running
+// the page query, for clients that only need to know whether to paginate
+func countSearch(c fiber.Ctx, q *searchQuery, exact bool) error {
+	ctx := c.Context()
+
+	var total int
+	var approximate bool
+	err := withSearchTimeout(ctx, currentConfig().SearchTimeout, func(tx pgx.Tx) error {
+		var err error
+		total, approximate, err = countMatchingEntries(ctx, tx, q, exact)
+		return err
+	})
+	if err != nil {
+		return serverError(c, "Failed to count matching entries", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"total":       total,
+		"approximate": approximate,
+		"status":      "success",
+	})
+}
+
+// queryRower runs a single row query, either on the pool or in a transaction
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// withSearchTimeout runs fn in a read-only transaction whose statements the
+// server cancels after timeout, normally SEARCH_TIMEOUT, so a broad substring
+// search can't hold on to a connection for minutes even if the client's
+// cancellation never arrives. The transaction is always rolled back: a
+// timeout aborts it, and fn may still return the rows it read before that.
+func withSearchTimeout(ctx context.Context, timeout time.Duration, fn func(tx pgx.Tx) error) error {
+	tx, err := dbPool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// SET doesn't take parameters, the timeout is a plain number of milliseconds
+	if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", timeout.Milliseconds())); err != nil {
+		return fmt.Errorf("failed to set statement timeout: %w", err)
+	}
+	return fn(tx)
+}
+
+// collectEntries reads every entry of rows. With allowPartial, a statement
+// timeout ends the read early and the entries received until then are
+// returned with partial set; the transaction can't be used afterwards.
+func collectEntries(rows pgx.Rows, allowPartial bool) (entries []Entry, partial bool, err error) {
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry Entry
+		if err := rows.Scan(entry.scanFields()...); err != nil {
+			return nil, false, fmt.Errorf("failed to scan search results: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	err = rows.Err()
+	if allowPartial && isStatementTimeout(err) {
+		return entries, true, nil
+	}
+	return entries, false, err
+}
+
+// isStatementTimeout reports whether the server cancelled a query, which
+// happens when it runs past statement_timeout
+func isStatementTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "57014" // query_canceled
+}
+
+// countMatchingEntries counts the entries matching q. When exact is false and
+// nothing is filtered, the planner's estimate from pg_class is used instead of
+// scanning the table, unless soft-deleted entries it would include exist.
+// Filtered counts are always exact.
+func countMatchingEntries(ctx context.Context, db queryRower, q *searchQuery, exact bool) (total int, approximate bool, err error) {
+	if !exact && len(q.conditions) == 0 {
+		var estimate int64
+		var anyDeleted bool
+		err := db.QueryRow(ctx,
+			"SELECT reltuples::bigint, EXISTS (SELECT 1 FROM entries WHERE deleted_at IS NOT NULL) FROM pg_class WHERE oid = 'entries'::regclass").
+			Scan(&estimate, &anyDeleted)
+		if err != nil {
+			return 0, false, err
+		}
+		// reltuples is -1 until the table has been vacuumed or analyzed
+		if estimate >= 0 && !anyDeleted {
+			return int(estimate), true, nil
+		}
+	}
+
+	err = db.QueryRow(ctx, searchCountSQL(q), q.params...).Scan(&total)
+	return total, false, err
+}
+
+// parseSince parses the since parameter of GET /entries: a Go duration such
+// as 24h, or a number of days such as 7d since time.ParseDuration has no days
+func parseSince(value string) (time.Duration, error) {
+	var since time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number of days %q", days)
+		}
+		since = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if since, err = time.ParseDuration(value); err != nil {
+			return 0, err
+		}
+	}
+	if since <= 0 {
+		return 0, fmt.Errorf("since must be positive, got %q", value)
+	}
+	return since, nil
+}
+
+// createdSince adds a condition keeping entries created within since of now.
+// created only holds the date, so the whole first day is included.
+func (q *searchQuery) createdSince(since time.Duration, now time.Time) {
+	q.conditions = append(q.conditions, fmt.Sprintf("created >= $%d", q.param(now.Add(-since).Format("2006-01-02"))))
+}
+
+// suggestFields maps the fields accepted by GET /search/suggest to their columns
+var suggestFields = map[string]string{
+	"domain":   "domain",
+	"url":      "url",
+	"username": "username",
+}
+
+// suggestLimit is the number of suggestions returned
+const suggestLimit = 10
+
+// suggestion is a distinct value of a field and how many entries have it
+type suggestion struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// suggestValues returns the most frequent values of column containing prefix,
+// ignoring case. Values starting with it come first. The column must come
+// from suggestFields; the LOWER() match uses its trigram index.
+func suggestValues(ctx context.Context, column, prefix string) ([]suggestion, error) {
+	prefix = strings.ToLower(prefix)
+	rows, err := dbPool.Query(ctx, `
+		SELECT `+column+`, COUNT(*) AS count
+		FROM entries
+		WHERE LOWER(`+column+`) LIKE $1 AND `+liveEntries+`
+		GROUP BY 1
+		ORDER BY bool_or(LOWER(`+column+`) LIKE $2) DESC, count DESC, 1
+		LIMIT $3
+	`, "%"+prefix+"%", prefix+"%", suggestLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []suggestion{}
+	for rows.Next() {
+		var s suggestion
+		if err := rows.Scan(&s.Value, &s.Count); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, rows.Err()
+}
@@ -0,0 +1,697 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestBuildSearchQuery(t *testing.T) {
+	q, err := buildSearchQuery(searchFilter{
+		URLs:           []string{"Login", ""},
+		Users:          []string{"alice", "bob"},
+		ExcludeUsers:   []string{"Admin"},
+		ExcludeDomains: []string{"https://www.Spam.com/path", "junk.org"},
+		From:           "2025-01-01",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantWhere := " WHERE deleted_at IS NULL AND (LOWER(url) LIKE $1) AND (LOWER(username) LIKE $2 OR LOWER(username) LIKE $3)" +
+		" AND LOWER(username) NOT LIKE $4 AND domain NOT IN ($5, $6) AND created >= $7"
+	if got := q.where(); got != wantWhere {
+		t.Errorf("where = %q, want %q", got, wantWhere)
+	}
+
+	wantParams := []interface{}{"%login%", "%alice%", "%bob%", "%admin%", "spam.com", "junk.org", "2025-01-01"}
+	if !reflect.DeepEqual(q.params, wantParams) {
+		t.Errorf("params = %v, want %v", q.params, wantParams)
+	}
+
+	q, err = buildSearchQuery(searchFilter{Tags: []string{"Verified", "", "verified", "junk"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.where(), " WHERE deleted_at IS NULL AND tags && $1::text[]"; got != want {
+		t.Errorf("where = %q, want %q", got, want)
+	}
+	if want := []interface{}{[]string{"verified", "junk"}}; !reflect.DeepEqual(q.params, want) {
+		t.Errorf("params = %v, want %v", q.params, want)
+	}
+
+	q, err = buildSearchQuery(searchFilter{Domains: []string{"a.com", "https://www.A.com/x", "b.com"}, IncludeSubdomains: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.where(), " WHERE deleted_at IS NULL AND (domain = ANY($1::text[]) OR domain LIKE ANY($2::text[]))"; got != want {
+		t.Errorf("where = %q, want %q", got, want)
+	}
+	if want := []interface{}{[]string{"a.com", "b.com"}, []string{"%.a.com", "%.b.com"}}; !reflect.DeepEqual(q.params, want) {
+		t.Errorf("params = %v, want %v", q.params, want)
+	}
+
+	if _, err := buildSearchQuery(searchFilter{To: "yesterday"}); err == nil {
+		t.Error("expected an error for an invalid date")
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	for value, want := range map[string]time.Duration{
+		"24h":   24 * time.Hour,
+		"90m":   90 * time.Minute,
+		"7d":    7 * 24 * time.Hour,
+		"1h30m": 90 * time.Minute,
+	} {
+		if got, err := parseSince(value); err != nil || got != want {
+			t.Errorf("parseSince(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "7", "d", "1.5d", "week", "-2h", "0d"} {
+		if got, err := parseSince(value); err == nil {
+			t.Errorf("parseSince(%q) = %v, want an error", value, got)
+		}
+	}
+
+	q := &searchQuery{}
+	q.createdSince(48*time.Hour, time.Date(2025, 3, 2, 9, 0, 0, 0, time.UTC))
+	if got, want := q.where(), " WHERE deleted_at IS NULL AND created >= $1"; got != want {
+		t.Errorf("where = %q, want %q", got, want)
+	}
+	if want := []interface{}{"2025-02-28"}; !reflect.DeepEqual(q.params, want) {
+		t.Errorf("params = %v, want %v", q.params, want)
+	}
+}
+
+func TestEntriesSince(t *testing.T) {
+	setupTestDB(t)
+
+	day := func(daysAgo int) string {
+		return time.Now().AddDate(0, 0, -daysAgo).Format("2006-01-02")
+	}
+	insertTestEntries(t,
+		Entry{URL: "https://a.com", User: "today", Pass: "p", Created: day(0)},
+		Entry{URL: "https://a.com", User: "yesterday", Pass: "p", Created: day(1)},
+		Entry{URL: "https://a.com", User: "last-week", Pass: "p", Created: day(5)},
+		Entry{URL: "https://a.com", User: "old", Pass: "p", Created: day(30)},
+	)
+
+	for _, tt := range []struct {
+		since string
+		want  int
+	}{
+		{"24h", 2},
+		{"7d", 3},
+		{"90d", 4},
+	} {
+		var result PaginationResponse
+		if status := getJSON(t, "/api/entries?since="+tt.since, &result); status != 200 {
+			t.Fatalf("since=%s: status = %d, want 200", tt.since, status)
+		}
+		if result.Total != tt.want || len(result.Items) != tt.want {
+			t.Errorf("since=%s: %d entries (total %d), want %d", tt.since, len(result.Items), result.Total, tt.want)
+		}
+	}
+}
+
+// postSearch performs a POST /api/search with a JSON body and decodes the response
+func postSearch(t *testing.T, body string) (int, PaginationResponse) {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", "/api/search", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := newApp().Test(req, testConfig)
+	if err != nil {
+		t.Fatalf("POST /api/search failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result PaginationResponse
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+	return resp.StatusCode, result
+}
+
+func TestSearchPost(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://mail.google.com", User: "alice@gmail.com", Pass: "one"},
+		Entry{URL: "https://spammy-domain.com/login", User: "bob@gmail.com", Pass: "two"},
+		Entry{URL: "https://github.com/login", User: "carol", Pass: "three"},
+		Entry{URL: "https://example.org", User: "dave@gmail.com", Pass: "four"},
+	)
+
+	tests := []struct {
+		name  string
+		body  string
+		users []string
+	}{
+		{"multiple users", `{"users": ["alice", "carol"]}`, []string{"carol", "alice@gmail.com"}},
+		{"multiple urls", `{"urls": ["google", "github"]}`, []string{"carol", "alice@gmail.com"}},
+		{"exclusion", `{"q": "gmail", "excludeDomains": ["spammy-domain.com"]}`, []string{"dave@gmail.com", "alice@gmail.com"}},
+		{"multiple exclusions", `{"q": "gmail", "excludeDomains": ["spammy-domain.com", "mail.google.com"]}`, []string{"dave@gmail.com"}},
+		{"page size", `{"q": "gmail", "page": 2, "pageSize": 2}`, []string{"alice@gmail.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, result := postSearch(t, tt.body)
+			if status != 200 {
+				t.Fatalf("status = %d, want 200", status)
+			}
+
+			var users []string
+			for _, item := range result.Items {
+				users = append(users, item.User)
+			}
+			if !reflect.DeepEqual(users, tt.users) {
+				t.Errorf("users = %v, want %v", users, tt.users)
+			}
+		})
+	}
+
+	if status, _ := postSearch(t, `{"from": "01/02/2025"}`); status != 400 {
+		t.Errorf("invalid date status = %d, want 400", status)
+	}
+}
+
+func TestSearchExclusions(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://mail.google.com", User: "alice@gmail.com", Pass: "one"},
+		Entry{URL: "https://spammy-domain.com/login", User: "bob@gmail.com", Pass: "two"},
+		Entry{URL: "https://example.org/account", User: "carol@gmail.com", Pass: "three"},
+		Entry{URL: "https://github.com/login", User: "dave", Pass: "four"},
+	)
+
+	tests := []struct {
+		path  string
+		users []string
+	}{
+		{"/api/search?q=gmail&excludeDomain=spammy-domain.com", []string{"carol@gmail.com", "alice@gmail.com"}},
+		{"/api/search?q=gmail&excludeUrl=ACCOUNT", []string{"bob@gmail.com", "alice@gmail.com"}},
+		{"/api/search?q=gmail&excludeUser=alice", []string{"carol@gmail.com", "bob@gmail.com"}},
+		{"/api/search?q=login&excludeDomain=github.com&excludeUser=nobody", []string{"bob@gmail.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			var result PaginationResponse
+			if status := getJSON(t, tt.path, &result); status != 200 {
+				t.Fatalf("status = %d, want 200", status)
+			}
+
+			// The count must apply the exclusions too so pagination stays correct
+			if result.Total != len(tt.users) {
+				t.Errorf("total = %d, want %d", result.Total, len(tt.users))
+			}
+
+			var users []string
+			for _, item := range result.Items {
+				users = append(users, item.User)
+			}
+			if !reflect.DeepEqual(users, tt.users) {
+				t.Errorf("users = %v, want %v", users, tt.users)
+			}
+		})
+	}
+}
+
+func TestApproximateCount(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://mail.google.com", User: "alice@gmail.com", Pass: "one"},
+		Entry{URL: "https://github.com/login", User: "bob", Pass: "two"},
+		Entry{URL: "https://example.org", User: "carol@gmail.com", Pass: "three"},
+	)
+	// Refresh the planner statistics so reltuples holds an estimate
+	if _, err := dbPool.Exec(context.Background(), "ANALYZE entries"); err != nil {
+		t.Fatalf("failed to analyze entries: %v", err)
+	}
+
+	tests := []struct {
+		path        string
+		total       int
+		approximate bool
+	}{
+		{"/api/entries", 3, false},
+		{"/api/entries?exactCount=false", 3, true},
+		{"/api/search?exactCount=false", 3, true},
+		{"/api/search?q=gmail&exactCount=false", 2, false},
+		{"/api/search?domain=github.com&exactCount=false", 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			var result PaginationResponse
+			if status := getJSON(t, tt.path, &result); status != 200 {
+				t.Fatalf("status = %d, want 200", status)
+			}
+			if result.Approximate != tt.approximate {
+				t.Errorf("approximate = %v, want %v", result.Approximate, tt.approximate)
+			}
+			if result.Total != tt.total {
+				t.Errorf("total = %d, want %d", result.Total, tt.total)
+			}
+		})
+	}
+
+	// The estimate would include soft-deleted entries, so they are counted
+	if status := deleteEntry(t, "/api/entries/1"); status != 200 {
+		t.Fatalf("delete status = %d, want 200", status)
+	}
+	var result PaginationResponse
+	getJSON(t, "/api/search?exactCount=false", &result)
+	if result.Total != 2 || result.Approximate {
+		t.Errorf("total = %d, approximate = %t after a delete, want an exact 2", result.Total, result.Approximate)
+	}
+}
+
+// postTags performs a POST /api/entries/:id/tags and decodes the updated entry
+func postTags(t *testing.T, id int, body string) (int, Entry) {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", fmt.Sprintf("/api/entries/%d/tags", id), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := newApp().Test(req, testConfig)
+	if err != nil {
+		t.Fatalf("POST tags failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var entry Entry
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&entry); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+	return resp.StatusCode, entry
+}
+
+func TestEntryTags(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://a.com", User: "alice", Pass: "one"},
+		Entry{URL: "https://b.com", User: "bob", Pass: "two"},
+		Entry{URL: "https://c.com", User: "carol", Pass: "three"},
+	)
+
+	steps := []struct {
+		id   int
+		body string
+		tags []string
+	}{
+		{1, `{"add": ["Verified", "reviewed"]}`, []string{"reviewed", "verified"}},
+		{1, `{"add": ["verified"], "remove": ["reviewed"]}`, []string{"verified"}},
+		{2, `{"add": ["junk", " "]}`, []string{"junk"}},
+		{3, `{"add": ["verified", "junk"], "remove": ["JUNK"]}`, []string{"verified"}},
+		{3, `{"remove": ["missing"]}`, []string{"verified"}},
+	}
+	for _, step := range steps {
+		status, entry := postTags(t, step.id, step.body)
+		if status != 200 {
+			t.Fatalf("tagging entry %d with %s: status = %d, want 200", step.id, step.body, status)
+		}
+		if !reflect.DeepEqual(entry.Tags, step.tags) {
+			t.Errorf("tagging entry %d with %s: tags = %v, want %v", step.id, step.body, entry.Tags, step.tags)
+		}
+	}
+
+	if status, _ := postTags(t, 99, `{"add": ["junk"]}`); status != 404 {
+		t.Errorf("unknown entry status = %d, want 404", status)
+	}
+	if status, _ := postTags(t, 1, `{"add": "junk"}`); status != 400 {
+		t.Errorf("invalid body status = %d, want 400", status)
+	}
+
+	filters := []struct {
+		path  string
+		users []string
+	}{
+		{"/api/search?tag=verified", []string{"carol", "alice"}},
+		{"/api/search?tag=JUNK", []string{"bob"}},
+		{"/api/search?tag=verified&user=alice", []string{"alice"}},
+	}
+	for _, tt := range filters {
+		var result PaginationResponse
+		if status := getJSON(t, tt.path, &result); status != 200 {
+			t.Fatalf("%s: status = %d, want 200", tt.path, status)
+		}
+
+		var users []string
+		for _, item := range result.Items {
+			users = append(users, item.User)
+		}
+		if !reflect.DeepEqual(users, tt.users) {
+			t.Errorf("%s: users = %v, want %v", tt.path, users, tt.users)
+		}
+	}
+
+	// Multiple tags in a POST search match any of them
+	_, result := postSearch(t, `{"tags": ["junk", "verified"]}`)
+	if result.Total != 3 {
+		t.Errorf("entries tagged junk or verified = %d, want 3", result.Total)
+	}
+}
+
+func TestSearchDomains(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://a.com/login", User: "alice", Pass: "one"},
+		Entry{URL: "https://mail.b.com", User: "bob", Pass: "two"},
+		Entry{URL: "https://c.com", User: "carol", Pass: "three"},
+		Entry{URL: "https://d.com", User: "dave", Pass: "four"},
+		Entry{URL: "https://not-a.com", User: "erin", Pass: "five"},
+	)
+
+	tests := []struct {
+		path  string
+		users []string
+	}{
+		{"/api/search?domains=a.com,b.com,c.com", []string{"carol", "alice"}},
+		{"/api/search?domains=a.com,%20b.com,,c.com&includeSubdomains=true", []string{"carol", "bob", "alice"}},
+		{"/api/search?domain=d.com&domains=c.com", []string{"dave", "carol"}},
+		{"/api/search?domains=a.com,b.com,c.com&pageSize=1", []string{"carol"}},
+	}
+	for _, tt := range tests {
+		var result PaginationResponse
+		if status := getJSON(t, tt.path, &result); status != 200 {
+			t.Fatalf("%s: status = %d, want 200", tt.path, status)
+		}
+		var users []string
+		for _, item := range result.Items {
+			users = append(users, item.User)
+		}
+		if !reflect.DeepEqual(users, tt.users) {
+			t.Errorf("%s: users = %v, want %v", tt.path, users, tt.users)
+		}
+	}
+
+	var result PaginationResponse
+	getJSON(t, "/api/search?domains=a.com,b.com,c.com&pageSize=1", &result)
+	if result.Total != 2 || !result.HasNext {
+		t.Errorf("paginated total = %d, hasNext = %t, want 2 and a next page", result.Total, result.HasNext)
+	}
+}
+
+func TestSearchCountOnly(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://a.com/login", User: "alice", Pass: "one"},
+		Entry{URL: "https://a.com/signin", User: "bob", Pass: "two"},
+		Entry{URL: "https://b.com", User: "carol", Pass: "three"},
+	)
+
+	var result map[string]any
+	if status := getJSON(t, "/api/search?domain=a.com&countOnly=true&pageSize=1", &result); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if _, ok := result["items"]; ok {
+		t.Errorf("count-only response has items: %v", result)
+	}
+	if result["total"] != float64(2) || result["approximate"] != false {
+		t.Errorf("response = %v, want an exact total of 2", result)
+	}
+
+	// The count matches the total of the full response
+	var full PaginationResponse
+	getJSON(t, "/api/search?domain=a.com&pageSize=1", &full)
+	if full.Total != 2 || len(full.Items) != 1 {
+		t.Errorf("full response total = %d with %d items, want 2 with 1", full.Total, len(full.Items))
+	}
+
+	req := httptest.NewRequest("POST", "/api/search", strings.NewReader(`{"users": ["carol"], "countOnly": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	result = nil
+	doJSON(t, req, &result)
+	if _, ok := result["items"]; ok || result["total"] != float64(1) {
+		t.Errorf("POST count-only response = %v, want a total of 1 without items", result)
+	}
+}
+
+func TestSearchSuggest(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://paypal.com/login", User: "alice", Pass: "one"},
+		Entry{URL: "https://paypal.com/signin", User: "bob", Pass: "two"},
+		Entry{URL: "https://paypal.com", User: "carol", Pass: "three"},
+		Entry{URL: "https://paysafe.com", User: "dave", Pass: "four"},
+		Entry{URL: "https://mypayments.com", User: "erin", Pass: "five"},
+		Entry{URL: "https://mypayments.com/a", User: "frank", Pass: "six"},
+		Entry{URL: "https://mypayments.com/b", User: "grace", Pass: "seven"},
+		Entry{URL: "https://mypayments.com/c", User: "heidi", Pass: "eight"},
+		Entry{URL: "https://example.com", User: "PayUser", Pass: "nine"},
+	)
+
+	type suggestResponse struct {
+		Field       string       `json:"field"`
+		Suggestions []suggestion `json:"suggestions"`
+	}
+
+	// Prefix matches rank before more frequent substring matches
+	var result suggestResponse
+	if status := getJSON(t, "/api/search/suggest?field=domain&q=PAY", &result); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	expected := []suggestion{
+		{Value: "paypal.com", Count: 3},
+		{Value: "paysafe.com", Count: 1},
+		{Value: "mypayments.com", Count: 4},
+	}
+	if result.Field != "domain" || !reflect.DeepEqual(result.Suggestions, expected) {
+		t.Errorf("suggestions = %+v, want %+v", result, expected)
+	}
+
+	result = suggestResponse{}
+	if status := getJSON(t, "/api/search/suggest?field=username&q=pay", &result); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if want := []suggestion{{Value: "PayUser", Count: 1}}; !reflect.DeepEqual(result.Suggestions, want) {
+		t.Errorf("username suggestions = %+v, want %+v", result.Suggestions, want)
+	}
+
+	result = suggestResponse{}
+	getJSON(t, "/api/search/suggest?field=url&q=nothing-matches", &result)
+	if result.Suggestions == nil || len(result.Suggestions) != 0 {
+		t.Errorf("suggestions = %#v, want an empty list", result.Suggestions)
+	}
+}
+
+func TestSearchSuggestFieldWhitelist(t *testing.T) {
+	for _, path := range []string{
+		"/api/search/suggest?field=password&q=pay",
+		"/api/search/suggest?field=domain)%20OR%201=1--&q=pay",
+		"/api/search/suggest?field=domain",
+	} {
+		var result map[string]any
+		if status := getJSON(t, path, &result); status != 400 {
+			t.Errorf("GET %s: status = %d, want 400", path, status)
+		}
+	}
+}
+
+func TestCheckCredential(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t,
+		Entry{URL: "https://a.com/login", User: "alice@example.com", Pass: "hunter2"},
+		Entry{URL: "https://b.com/login", User: "alice@example.com", Pass: "hunter2"},
+		Entry{URL: "https://a.com/login", User: "bob@example.com", Pass: "hunter2"},
+	)
+
+	for _, tt := range []struct {
+		query string
+		found bool
+		count int
+	}{
+		{"user=alice@example.com&pass=hunter2", true, 2},
+		{"user=alice@example.com&pass=hunter2&url=https://b.com/login", true, 1},
+		{"user=bob@example.com", true, 1},
+		// Exact matches only, unlike /search
+		{"user=alice&pass=hunter2", false, 0},
+		{"user=alice@example.com&pass=hunter", false, 0},
+		{"user=carol@example.com&pass=hunter2", false, 0},
+	} {
+		var body struct {
+			Found bool `json:"found"`
+			Count int  `json:"count"`
+		}
+		if status := getJSON(t, "/api/check?"+tt.query, &body); status != 200 {
+			t.Fatalf("GET /api/check?%s status = %d", tt.query, status)
+		}
+		if body.Found != tt.found || body.Count != tt.count {
+			t.Errorf("check %s = %+v, want found %v count %d", tt.query, body, tt.found, tt.count)
+		}
+	}
+
+	var errBody ErrorResponse
+	if status := getJSON(t, "/api/check?url=https://a.com/login", &errBody); status != 400 {
+		t.Errorf("check with only url = %d, want 400", status)
+	}
+}
+
+func TestCheckNormalizedPassword(t *testing.T) {
+	for _, hash := range []bool{false, true} {
+		t.Run(fmt.Sprintf("hash=%t", hash), func(t *testing.T) {
+			setupTestDB(t)
+			c := currentConfig()
+			c.NormalizePasswords = true
+			c.HashPasswords = hash
+			c.PasswordHashSecret = "secret"
+			setConfig(c)
+
+			// Stored as hunter2, without the quotes and trailing space
+			if status, result := postEntries(t, `[{"url": "https://a.com", "user": "alice", "pass": "'hunter2' "}]`); status != 200 {
+				t.Fatalf("POST /api/entries status = %d: %v", status, result)
+			}
+
+			for _, pass := range []string{"hunter2", "%27hunter2%27", "hunter2%20%20"} {
+				var body struct {
+					Found bool `json:"found"`
+				}
+				if status := getJSON(t, "/api/check?user=alice&pass="+pass, &body); status != 200 {
+					t.Fatalf("GET /api/check status = %d", status)
+				}
+				if !body.Found {
+					t.Errorf("check %q found nothing, want the normalized password to match", pass)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchTimeout(t *testing.T) {
+	setupTestDB(t)
+
+	ctx := context.Background()
+	err := withSearchTimeout(ctx, 50*time.Millisecond, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "SELECT pg_sleep(5)")
+		return err
+	})
+	if !isStatementTimeout(err) {
+		t.Fatalf("slow query error = %v, want a statement timeout", err)
+	}
+
+	// The timeout only applies inside the transaction
+	if _, err := dbPool.Exec(ctx, "SELECT pg_sleep(0.1)"); err != nil {
+		t.Errorf("query outside the search transaction failed: %v", err)
+	}
+	err = withSearchTimeout(ctx, 50*time.Millisecond, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "SELECT 1")
+		return err
+	})
+	if err != nil {
+		t.Errorf("fast query failed: %v", err)
+	}
+}
+
+func TestSearchPartialResults(t *testing.T) {
+	setupTestDB(t)
+	for i := range 20 {
+		insertTestEntries(t, Entry{URL: fmt.Sprintf("https://site%d.com", i), User: "alice", Pass: "one"})
+	}
+
+	// Every row takes 50ms, so only a few arrive before the timeout
+	slowSQL := "SELECT " + entryColumns + " FROM entries WHERE pg_sleep(0.05) IS NOT NULL"
+	ctx := context.Background()
+	for _, allowPartial := range []bool{false, true} {
+		var entries []Entry
+		var partial bool
+		err := withSearchTimeout(ctx, 300*time.Millisecond, func(tx pgx.Tx) error {
+			rows, err := tx.Query(ctx, slowSQL)
+			if err != nil {
+				return err
+			}
+			entries, partial, err = collectEntries(rows, allowPartial)
+			return err
+		})
+
+		if !allowPartial {
+			if !isStatementTimeout(err) {
+				t.Errorf("full results error = %v, want a statement timeout", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("partial results failed: %v", err)
+		}
+		if !partial || len(entries) == 0 || len(entries) >= 20 {
+			t.Errorf("got %d entries, partial = %t, want some of the 20 entries", len(entries), partial)
+		}
+	}
+}
+
+func TestFetchSearchPagePartial(t *testing.T) {
+	setupTestDB(t)
+	for i := range 20 {
+		insertTestEntries(t, Entry{URL: fmt.Sprintf("https://site%d.com", i), User: "alice", Pass: "one"})
+	}
+
+	// The timeout aborts the transaction, which must not turn partial
+	// results into a failed commit
+	q := &searchQuery{conditions: []string{"pg_sleep(0.05) IS NOT NULL"}}
+	ctx := context.Background()
+	if _, err := fetchSearchPage(ctx, q, 50, 0, true, false, 300*time.Millisecond); !isStatementTimeout(err) {
+		t.Errorf("full results error = %v, want a statement timeout", err)
+	}
+
+	result, err := fetchSearchPage(ctx, q, 50, 0, true, true, 300*time.Millisecond)
+	if err != nil {
+		t.Fatalf("partial results failed: %v", err)
+	}
+	if !result.partial || !result.approximate || len(result.entries) >= 20 || result.total != len(result.entries) {
+		t.Errorf("result = %d entries, total %d, partial = %t, want a partial page counting only its entries",
+			len(result.entries), result.total, result.partial)
+	}
+
+	// The connection is usable again afterwards
+	if _, err := fetchSearchPage(ctx, &searchQuery{}, 50, 0, true, true, time.Second); err != nil {
+		t.Errorf("search after a timeout failed: %v", err)
+	}
+}
+
+func TestExplainSearch(t *testing.T) {
+	setupTestDB(t)
+	insertTestEntries(t, Entry{URL: "https://mail.google.com", User: "alice@gmail.com", Pass: "one"})
+
+	var result struct {
+		Page  SearchPlan `json:"page"`
+		Count SearchPlan `json:"count"`
+	}
+	if status := getAdminJSON(t, "/api/admin/explain?endpoint=search&q=gmail&domain=google.com", &result); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+
+	// The explained SQL is the one /search runs
+	q, err := buildSearchQuery(searchFilter{Q: "gmail", Domains: []string{"google.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Page.SQL != searchPageSQL(q) || result.Count.SQL != searchCountSQL(q) {
+		t.Errorf("explained %q and %q, want the search queries", result.Page.SQL, result.Count.SQL)
+	}
+
+	for _, plan := range []SearchPlan{result.Page, result.Count} {
+		var explained []struct {
+			Plan          map[string]any `json:"Plan"`
+			ExecutionTime float64        `json:"Execution Time"`
+		}
+		if err := json.Unmarshal(plan.Plan, &explained); err != nil || len(explained) != 1 || explained[0].Plan["Node Type"] == nil {
+			t.Errorf("plan of %s = %s, want an EXPLAIN ANALYZE plan (%v)", plan.SQL, plan.Plan, err)
+		}
+	}
+
+	var errBody ErrorResponse
+	if status := getAdminJSON(t, "/api/admin/explain?endpoint=entries", &errBody); status != 400 {
+		t.Errorf("unsupported endpoint status = %d, want 400", status)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Values of SINK
+const (
+	sinkPostgres = "postgres"
+	sinkFile     = "file"
+)
+
+// defaultSinkFile is where SINK=file writes entries unless SINK_FILE is set
+const defaultSinkFile = "entries.ndjson"
+
+// Sink receives the entries parsed from a log, one batch at a time
+type Sink interface {
+	Write(entries []EntryDetail) error
+	Close() error
+}
+
+// insertCounter is implemented by sinks that may drop entries. Inserted
+// reports how many were kept and Rejected how many failed to insert, the
+// others being duplicates dropped by the unique index of DEDUPE_SCOPE.
+type insertCounter interface {
+	Inserted() int
+	Rejected() int
+}
+
+// postgresSink inserts entries into the entries table. The transaction
+// belongs to the import, so Close leaves it open.
+type postgresSink struct {
+	ctx        context.Context
+	tx         pgx.Tx
+	insertName string
+	inserted   int
+	rejected   int
+}
+
+// Write inserts a batch under a savepoint. When a row is refused the batch is
+// rolled back and retried row by row, so only the offending rows are skipped.
+// Lost connections and cancellations still fail the import.
+func (s *postgresSink) Write(entries []EntryDetail) error {
+	batch := &pgx.Batch{}
+	for _, e := range entries {
+		s.queue(batch, e)
+	}
+	n, err := s.sendUnderSavepoint(batch)
+	var pgErr *pgconn.PgError
+	if err == nil || !errors.As(err, &pgErr) || isTransientError(err) {
+		s.inserted += n
+		return err
+	}
+
+	log.Printf("Batch of %d entries failed, retrying row by row: %v", len(entries), err)
+	for _, e := range entries {
+		row := &pgx.Batch{}
+		s.queue(row, e)
+		n, err := s.sendUnderSavepoint(row)
+		if err != nil && (!errors.As(err, &pgErr) || isTransientError(err)) {
+			return err
+		}
+		if err != nil {
+			// Entries written by processReader always have a line and source file
+			log.Printf("Skipped entry on line %d of %s: %v", *e.LineNo, *e.SourceFile, err)
+			s.rejected++
+			continue
+		}
+		s.inserted += n
+	}
+	return nil
+}
+
+// queue adds the insert of e to batch
+func (s *postgresSink) queue(batch *pgx.Batch, e EntryDetail) {
+	var raw []byte
+	if e.RawLine != nil {
+		raw = []byte(*e.RawLine)
+	}
+	batch.Queue(s.insertName, e.URL, e.User, e.Pass, e.Created, e.Domain, e.RunID, e.LineNo, e.SourceFile, e.ContentID, e.Kind, raw)
+}
+
+// sendUnderSavepoint sends batch in a savepoint of the import transaction,
+// which is rolled back on failure so the transaction stays usable
+func (s *postgresSink) sendUnderSavepoint(batch *pgx.Batch) (int, error) {
+	savepoint, err := s.tx.Begin(s.ctx)
+	if err != nil {
+		return 0, err
+	}
+	n, err := sendEntryBatch(s.ctx, savepoint, batch)
+	if err != nil {
+		savepoint.Rollback(s.ctx)
+		return 0, err
+	}
+	return n, savepoint.Commit(s.ctx)
+}
+
+func (s *postgresSink) Inserted() int {
+	return s.inserted
+}
+
+func (s *postgresSink) Rejected() int {
+	return s.rejected
+}
+
+func (s *postgresSink) Close() error {
+	return nil
+}
+
+// sinkFileMu serializes appends to the sink file, so the lines of concurrent
+// imports don't interleave
+var sinkFileMu sync.Mutex
+
+// fileSink appends entries to a file as newline-delimited JSON, one
+// EntryDetail per line. A file can't be rolled back, so the entries of an
+// import are written to a pending file next to it first and only appended by
+// Publish, once the whole input was read. Imports that fail or are retried
+// leave the sink file untouched.
+type fileSink struct {
+	path    string
+	pending *os.File
+}
+
+// openFileSink starts an import into the sink file at path. The pending file
+// is readable by the owner only since it holds credentials.
+func openFileSink(path string) (*fileSink, error) {
+	pending, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".pending-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pending sink file: %w", err)
+	}
+	return &fileSink{path: path, pending: pending}, nil
+}
+
+func (s *fileSink) Write(entries []EntryDetail) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := encoder.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.pending.Write(buf.Bytes())
+	return err
+}
+
+// Publish appends the pending entries to the sink file, all of them or none
+func (s *fileSink) Publish() error {
+	if _, err := s.pending.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	sinkFileMu.Lock()
+	defer sinkFileMu.Unlock()
+	return appendWhole(s.path, s.pending)
+}
+
+// appendWhole appends everything read from r to the file at path, creating
+// it readable by the owner only. When the copy fails midway the file is
+// truncated back to its previous size, so it never holds part of r.
+func appendWhole(path string, r io.Reader) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open sink file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat sink file: %w", err)
+	}
+
+	if _, err := io.Copy(file, r); err != nil {
+		if truncErr := file.Truncate(info.Size()); truncErr != nil {
+			log.Printf("Warning: Failed to remove a partial append from %s: %v", path, truncErr)
+		}
+		file.Close()
+		return fmt.Errorf("failed to append to sink file: %w", err)
+	}
+	return file.Close()
+}
+
+// Close removes the pending file, discarding entries that weren't published.
+// It can be called more than once.
+func (s *fileSink) Close() error {
+	if s.pending == nil {
+		return nil
+	}
+	name := s.pending.Name()
+	s.pending.Close()
+	s.pending = nil
+	return os.Remove(name)
+}
+
+// describeSink names the sink of cfg for logs
+func describeSink(cfg Config) string {
+	if cfg.Sink == sinkFile {
+		return fmt.Sprintf("%s (%s)", sinkFile, cfg.SinkFile)
+	}
+	return cfg.Sink
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/jackc/pgx/v5"
+
+	"hello-world/backend/parser"
+)
+
+// readSinkFile decodes the JSON lines written by a file sink
+func readSinkFile(t *testing.T, path string) []EntryDetail {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var entries []EntryDetail
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e EntryDetail
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %q isn't JSON: %v", scanner.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entries.ndjson")
+	runID := 3
+
+	first := []EntryDetail{
+		newEntryDetail(parser.Entry{URL: "https://a.com/login", Username: "alice", Password: "one", Line: 1}, "2025-01-02", &runID, "dump.txt"),
+		newEntryDetail(parser.Entry{URL: "android://x@com.app/", Username: "bob", Password: "two\nlines", Line: 4, Raw: []byte("android://x@com.app/:bob:two")}, "2025-01-02", &runID, "dump.txt"),
+	}
+	second := []EntryDetail{
+		newEntryDetail(parser.Entry{Username: "carol@c.com", Password: "three", Line: 9}, "2025-01-03", nil, "stdin"),
+	}
+
+	// Published batches are appended, and so are later imports
+	importEntries := func(batches [][]EntryDetail, publish bool) {
+		t.Helper()
+		sink, err := openFileSink(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sink.Close()
+		for _, batch := range batches {
+			if err := sink.Write(batch); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if publish {
+			if err := sink.Publish(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	importEntries([][]EntryDetail{first[:1], first[1:]}, true)
+	// An import that isn't published leaves the file untouched
+	importEntries([][]EntryDetail{second}, false)
+	importEntries([][]EntryDetail{second}, true)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(content), "\n"); lines != 3 {
+		t.Fatalf("file has %d lines, want one per entry:\n%s", lines, content)
+	}
+	want := append(first, second...)
+	if got := readSinkFile(t, path); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %+v, want %+v", got, want)
+	}
+	if want[1].Kind != kindAndroid || *want[1].RawLine != "android://x@com.app/:bob:two" || *want[2].SourceFile != "stdin" {
+		t.Errorf("entry details = %+v, want the kind, raw line and source file", want)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("file mode = %v, %v, want 0600", info.Mode(), err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*")); len(files) != 1 {
+		t.Errorf("files = %v, want no pending file left", files)
+	}
+}
+
+func TestAppendWholeFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entries.ndjson")
+	if err := os.WriteFile(path, []byte("{\"url\":\"a\"}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first line is written before the read fails
+	failing := io.MultiReader(strings.NewReader("{\"url\":\"b\"}\n{\"url"), iotest.ErrReader(errors.New("disk failure")))
+	if err := appendWhole(path, failing); err == nil {
+		t.Fatal("expected the read error")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "{\"url\":\"a\"}\n" {
+		t.Errorf("sink file = %q, want only the earlier entry", data)
+	}
+
+	if err := appendWhole(path, strings.NewReader("{\"url\":\"c\"}\n")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "{\"url\":\"a\"}\n{\"url\":\"c\"}\n" {
+		t.Errorf("sink file = %q, want the earlier and the new entry", data)
+	}
+}
+
+// useFileSink imports into a sink file in a temporary directory, without a
+// database, and returns the sink file's path
+func useFileSink(t *testing.T, c Config) string {
+	t.Helper()
+
+	c.Sink = sinkFile
+	c.SinkFile = filepath.Join(t.TempDir(), "entries.ndjson")
+	if c.BatchSize == 0 {
+		c.BatchSize = defaultBatchSize
+	}
+	setConfig(c)
+	t.Cleanup(func() { setConfig(Config{}) })
+	return c.SinkFile
+}
+
+func TestProcessReaderFileSink(t *testing.T) {
+	// The file sink works while dbPool is nil
+	path := useFileSink(t, Config{BatchSize: 1, ProxyIngestion: true})
+
+	input := "https://a.com:alice:one\n1.2.3.4:8080:proxyuser:proxypass\nhttps://b.com:bob:two\n"
+	stats, err := processReader(context.Background(), strings.NewReader(input), "sink.txt", parser.Options{}, nil)
+	if err != nil {
+		t.Fatalf("processReader failed: %v", err)
+	}
+	if stats.Inserted != 2 || stats.SHA256 != sha256Hex(input) {
+		t.Errorf("stats = %+v, want 2 inserted and the hash of the input", stats)
+	}
+
+	var users []string
+	for _, e := range readSinkFile(t, path) {
+		users = append(users, e.User)
+	}
+	if want := []string{"alice", "bob"}; !reflect.DeepEqual(users, want) {
+		t.Errorf("users in the file = %v, want %v", users, want)
+	}
+
+	// Nothing records the input, so a copy is imported again
+	stats, err = processReader(context.Background(), strings.NewReader(input), "copy.txt", parser.Options{}, nil)
+	if err != nil || stats.DuplicateOf != "" || stats.Inserted != 2 {
+		t.Errorf("stats of the copy = %+v, %v, want 2 inserted", stats, err)
+	}
+	if got := readSinkFile(t, path); len(got) != 4 {
+		t.Errorf("file has %d entries, want 4", len(got))
+	}
+}
+
+func TestProcessReaderFileSinkReadError(t *testing.T) {
+	content := "https://a.com:alice:one\nhttps://b.com:bob:two\n"
+	failing := func() io.Reader {
+		return io.MultiReader(strings.NewReader(content), iotest.ErrReader(errors.New("disk failure")))
+	}
+
+	// The entries read before the error are kept
+	path := useFileSink(t, Config{})
+	if _, err := processReader(context.Background(), failing(), "partial.txt", parser.Options{}, nil); err == nil {
+		t.Fatal("expected the read error")
+	}
+	if got := readSinkFile(t, path); len(got) != 2 {
+		t.Errorf("file has %d entries, want the 2 read before the error", len(got))
+	}
+
+	// Unless the import is atomic
+	path = useFileSink(t, Config{AtomicImport: true})
+	stats, err := processReader(context.Background(), failing(), "partial.txt", parser.Options{}, nil)
+	if err == nil || stats.Inserted != 0 {
+		t.Fatalf("stats = %+v, %v, want the read error and nothing inserted", stats, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("sink file exists after a failed atomic import: %v", err)
+	}
+}
+
+func TestProcessReaderPostgresWithoutDatabase(t *testing.T) {
+	// A reload to SINK=postgres on a server started without a database
+	setConfig(Config{Sink: sinkPostgres, BatchSize: defaultBatchSize})
+	defer setConfig(Config{})
+
+	_, err := processReader(context.Background(), strings.NewReader("https://a.com:alice:one\n"), "a.txt", parser.Options{}, nil)
+	if !errors.Is(err, errNoDatabase) {
+		t.Errorf("processReader = %v, want %v", err, errNoDatabase)
+	}
+}
+
+func TestProcessReaderPoisonRow(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+
+	// A constraint stands in for a row the database refuses, like a value
+	// too large for an index
+	if _, err := dbPool.Exec(ctx, "ALTER TABLE entries ADD CONSTRAINT test_poison CHECK (username <> 'poison')"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := dbPool.Exec(context.Background(), "ALTER TABLE entries DROP CONSTRAINT IF EXISTS test_poison"); err != nil {
+			t.Errorf("failed to drop constraint: %v", err)
+		}
+	})
+
+	input := "https://a.com:u1:p\nhttps://a.com:u2:p\nhttps://a.com:poison:p\nhttps://a.com:u4:p\nhttps://a.com:u5:p\n"
+	stats, err := processReader(ctx, strings.NewReader(input), "poison.txt", parser.Options{}, nil)
+	if err != nil {
+		t.Fatalf("processReader failed: %v", err)
+	}
+	if stats.Inserted != 4 || stats.SkippedRejected != 1 || stats.SkippedDuplicate != 0 {
+		t.Errorf("stats = %+v, want 4 inserted and 1 rejected", stats)
+	}
+
+	rows, err := dbPool.Query(ctx, "SELECT username FROM entries ORDER BY line_no")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"u1", "u2", "u4", "u5"}; !reflect.DeepEqual(users, want) {
+		t.Errorf("users = %v, want %v", users, want)
+	}
+}
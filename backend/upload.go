@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"hello-world/backend/parser"
+)
+
+// uploadFormOverhead is allowed on top of MAX_UPLOAD_SIZE for the multipart
+// boundaries, headers and other form fields of an upload
+const uploadFormOverhead = 64 << 10
+
+// bodyLimit returns the request body limit of the app, large enough for an
+// upload of maxUploadSize bytes and never below Fiber's default
+func bodyLimit(maxUploadSize int64) int {
+	return max(int(maxUploadSize)+uploadFormOverhead, fiber.DefaultBodyLimit)
+}
+
+// importUpload imports an uploaded log file read from r and records it in
+// processed_log_files under name, like a file found in the log directory
+func importUpload(r io.Reader, name string, opts parser.Options) (ParseStats, time.Duration, error) {
+	// Large uploads take longer than REQUEST_TIMEOUT, like /process-file
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if logWatcher != nil {
+		defer logWatcher.startProcessing(name)()
+	}
+
+	start := time.Now()
+	stats, err := processReader(ctx, r, name, opts, nil)
+	duration := time.Since(start)
+	if err != nil {
+		return stats, duration, err
+	}
+	if logWatcher != nil {
+		logWatcher.entriesAdded.Add(int64(stats.Inserted))
+	}
+	return stats, duration, nil
+}
+
+// checkCSVImport reads a CSV file for /import/csv and returns the columns
+// named by its header. Every record must have as many fields as the header
+// and fit on one line, since the csv parser reads the file line by line.
+func checkCSVImport(r io.Reader) (parser.CSVColumns, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return parser.CSVColumns{}, errors.New("file is empty")
+	}
+	if err != nil {
+		return parser.CSVColumns{}, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	columns, err := parser.ParseCSVHeader(header)
+	if err != nil {
+		return parser.CSVColumns{}, err
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return columns, nil
+		}
+		if err != nil {
+			return parser.CSVColumns{}, err
+		}
+		for _, field := range record {
+			if strings.ContainsAny(field, "\r\n") {
+				line, _ := reader.FieldPos(0)
+				return parser.CSVColumns{}, fmt.Errorf("record on line %d spans several lines", line)
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"mime/multipart"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// postUpload sends content as the file of a POST /api/upload and decodes the response
+func postUpload(t *testing.T, name, content string) (int, map[string]any) {
+	t.Helper()
+	return postFile(t, "/api/upload", name, content)
+}
+
+// postFile sends content as the multipart field file of a POST to path and
+// decodes the response
+func postFile(t *testing.T, path, name, content string) (int, map[string]any) {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := newApp().Test(req, testConfig)
+	if err != nil {
+		t.Fatalf("POST %s failed: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	var result map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return resp.StatusCode, result
+}
+
+func TestUpload(t *testing.T) {
+	setupTestDB(t)
+	cfg := currentConfig()
+	cfg.MaxUploadSize = defaultMaxUploadSize
+	setConfig(cfg)
+
+	status, result := postUpload(t, "dump.txt", "https://a.com:alice:one\nhttps://b.com:bob:two\nincomplete\n")
+	if status != 200 {
+		t.Fatalf("status = %d, want 200: %v", status, result)
+	}
+	if result["inserted"] != float64(2) || result["skipped"] != float64(1) {
+		t.Errorf("result = %v, want 2 inserted and 1 skipped", result)
+	}
+	if got := countEntries(t); got != 2 {
+		t.Errorf("entries = %d, want 2", got)
+	}
+
+	var entriesAdded int
+	err := dbPool.QueryRow(context.Background(),
+		"SELECT entries_added FROM processed_log_files WHERE filename = 'dump.txt'").Scan(&entriesAdded)
+	if err != nil || entriesAdded != 2 {
+		t.Errorf("processed file entries_added = %d (%v), want 2", entriesAdded, err)
+	}
+}
+
+func TestUploadRejected(t *testing.T) {
+	setConfig(Config{RequestTimeout: time.Minute, MaxUploadSize: 64})
+	defer setConfig(Config{})
+
+	tests := []struct {
+		name, content string
+		status        int
+	}{
+		{"dump.exe", "https://a.com:alice:one\n", 400},
+		{"dump", "\x00\x01\x02binary", 400},
+		{"dump.txt", "no separators here", 400},
+		{"dump.txt", string(bytes.Repeat([]byte("https://a.com:alice:one\n"), 10)), 413},
+	}
+	for _, tt := range tests {
+		if status, result := postUpload(t, tt.name, tt.content); status != tt.status {
+			t.Errorf("upload of %s = %d %v, want %d", tt.name, status, result, tt.status)
+		}
+	}
+}
+
+func TestImportCSVRoundTrip(t *testing.T) {
+	setupTestDB(t)
+	cfg := currentConfig()
+	cfg.MaxUploadSize = defaultMaxUploadSize
+	setConfig(cfg)
+
+	insertTestEntries(t,
+		Entry{URL: "https://a.com/login", User: "alice", Pass: "one"},
+		Entry{URL: "https://a.com", User: "bob", Pass: "t,wo"},
+	)
+	exported := getExport(t, "/api/export/by-domain?domains=a.com")["a.com.csv"]
+
+	var content bytes.Buffer
+	if err := csv.NewWriter(&content).WriteAll(exported); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbPool.Exec(context.Background(), "DELETE FROM entries"); err != nil {
+		t.Fatal(err)
+	}
+
+	status, result := postFile(t, "/api/import/csv", "a.com.csv", content.String())
+	if status != 200 {
+		t.Fatalf("status = %d, want 200: %v", status, result)
+	}
+	if result["inserted"] != float64(2) || result["skipped"] != float64(0) {
+		t.Errorf("result = %v, want 2 inserted and none skipped", result)
+	}
+
+	// Exporting the imported entries gives back the same credentials
+	reexported := getExport(t, "/api/export/by-domain?domains=a.com")["a.com.csv"]
+	credentials := func(rows [][]string) [][]string {
+		var out [][]string
+		for _, row := range rows {
+			out = append(out, row[:3])
+		}
+		return out
+	}
+	if got, want := credentials(reexported), credentials(exported); !reflect.DeepEqual(got, want) {
+		t.Errorf("re-export = %v, want %v", got, want)
+	}
+
+	// Importing the file again only finds duplicates
+	if _, result := postFile(t, "/api/import/csv", "a.com.csv", content.String()); result["duplicatesSkipped"] != float64(2) {
+		t.Errorf("second import = %v, want 2 duplicates skipped", result)
+	}
+}
+
+func TestImportCSVRejected(t *testing.T) {
+	setConfig(Config{RequestTimeout: time.Minute, MaxUploadSize: 1 << 10})
+	defer setConfig(Config{})
+
+	tests := []struct {
+		name, content string
+	}{
+		{"empty", ""},
+		{"no password column", "url,username\nhttps://a.com,alice\n"},
+		{"duplicate column", "username,email,password\nalice,a@b.com,one\n"},
+		{"short record", "url,username,password\nhttps://a.com,alice\n"},
+		{"bare quote", "url,username,password\nhttps://a.com,al\"ice,one\n"},
+		{"multi-line record", "url,username,password\nhttps://a.com,alice,\"one\ntwo\"\n"},
+	}
+	for _, tt := range tests {
+		if status, result := postFile(t, "/api/import/csv", "dump.csv", tt.content); status != 400 {
+			t.Errorf("%s: import = %d %v, want 400", tt.name, status, result)
+		}
+	}
+}
@@ -2,14 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+
+	"hello-world/backend/parser"
 )
 
 // LogWatcher watches the log directory for new files and processes them
@@ -20,10 +25,113 @@ type LogWatcher struct {
 	mu             sync.Mutex
 	ctx            context.Context
 	cancel         context.CancelFunc
+
+	// handling counts the new files being handled, so Stop can wait for
+	// them. Files are only added while ctx isn't canceled, under mu, and Stop
+	// cancels ctx under mu, so none are added once it waits.
+	handling sync.WaitGroup
+	// stopTimeout is how long Stop waits for files being handled
+	stopTimeout time.Duration
+
+	// queue hands files that are ready to import to a fixed number of
+	// workers, so a burst of new files can't take every pooled connection.
+	// Files wait for a free worker once it's full.
+	queue chan func()
+	// queueDepth counts the files waiting for a worker. Files still queued
+	// when the watcher stops are dropped and no longer counted.
+	queueDepth atomic.Int64
+
+	// pending holds a reset channel for every file that is still waiting
+	// for its size to settle before being processed
+	pending map[string]chan struct{}
+
+	// debounce holds a timer for every file with a recent create or rename
+	// event, so the burst of events of a single copy is handled once
+	debounce map[string]*time.Timer
+	// debounceDelay is how long a file must go without new events before
+	// it's handled
+	debounceDelay time.Duration
+
+	// pollInterval and stableChecks control how long a new file must stay
+	// unchanged before it is considered fully written
+	pollInterval time.Duration
+	stableChecks int
+
+	// ingest processes a new file once it is fully written and returns its
+	// parse statistics. It defaults to ingestFile.
+	ingest func(ctx context.Context, filePath string) (ParseStats, error)
+	// skipTooLarge reports whether a file is over MAX_FILE_SIZE and records
+	// it as skipped. It defaults to skipTooLargeFile.
+	skipTooLarge func(ctx context.Context, filePath string, maxSize int64) bool
+
+	// healthy is false while the log directory isn't being watched, e.g.
+	// after it was deleted, until recoverWatch adds it again
+	healthy    bool
+	recovering bool
+	// watchErrors counts consecutive watcher errors
+	watchErrors int
+	// recoverDelay is the wait before the first attempt to watch the
+	// directory again, doubled up to maxRecoverDelay
+	recoverDelay time.Duration
+
+	// processing holds the files being imported and when they started
+	processing map[string]time.Time
+	// entriesAdded counts the entries imported since the watcher was created
+	entriesAdded atomic.Int64
+	// lastEvent is the time of the last file system event in unix nanoseconds
+	lastEvent atomic.Int64
+}
+
+// watcherProgress describes the work the watcher has in flight
+type watcherProgress struct {
+	// Processing lists the files being imported
+	Processing []string `json:"processing"`
+	// Queued is the number of new files waiting for their size to settle
+	Queued int `json:"queued"`
+	// QueueDepth is the number of files ready to import waiting for a worker
+	QueueDepth int `json:"queueDepth"`
+	// EntriesAdded counts the entries imported since the server started
+	EntriesAdded int64 `json:"entriesAdded"`
+	// LastEvent is the time of the last file system event, nil before the first
+	LastEvent *time.Time `json:"lastEvent"`
 }
 
+const (
+	// defaultDebounceDelay collapses the events some platforms emit for a single copy
+	defaultDebounceDelay = 100 * time.Millisecond
+	// defaultRecoverDelay is the first wait before the log directory is watched again
+	defaultRecoverDelay = time.Second
+	// maxRecoverDelay caps the backoff between attempts to watch the directory again
+	maxRecoverDelay = 30 * time.Second
+	// maxWatchErrors is how many consecutive watcher errors trigger a recovery
+	maxWatchErrors = 3
+
+	// defaultStopTimeout is how long Stop waits for imports to roll back
+	defaultStopTimeout = 30 * time.Second
+)
+
 // NewLogWatcher creates a new log watcher for the specified directory
 func NewLogWatcher(logDir string) (*LogWatcher, error) {
+	w, err := newLogWatcher(logDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load processed files from database, with SINK=file only files imported
+	// since the watcher started are known
+	if recordsImports(currentConfig()) {
+		if err := w.loadProcessedFiles(); err != nil {
+			log.Printf("Warning: Failed to load processed files from database: %v", err)
+		}
+	}
+
+	return w, nil
+}
+
+// newLogWatcher creates a log watcher without touching the database. Its
+// WATCHER_WORKERS workers run until Stop. Like the number of workers, the
+// stability settings are only read here.
+func newLogWatcher(logDir string) (*LogWatcher, error) {
 	// Create a new fsnotify watcher
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -40,16 +148,87 @@ func NewLogWatcher(logDir string) (*LogWatcher, error) {
 		processedFiles: make(map[string]bool),
 		ctx:            ctx,
 		cancel:         cancel,
+		pending:        make(map[string]chan struct{}),
+		debounce:       make(map[string]*time.Timer),
+		debounceDelay:  defaultDebounceDelay,
+		recoverDelay:   defaultRecoverDelay,
+		stopTimeout:    defaultStopTimeout,
+		processing:     make(map[string]time.Time),
+	}
+	w.ingest = w.ingestFile
+	w.skipTooLarge = func(ctx context.Context, filePath string, maxSize int64) bool {
+		return skipTooLargeFile(ctx, filePath, maxSize, nil)
 	}
 
-	// Load processed files from database
-	if err := w.loadProcessedFiles(); err != nil {
-		log.Printf("Warning: Failed to load processed files from database: %v", err)
+	cfg := currentConfig()
+	w.pollInterval, w.stableChecks = cfg.WatcherPollInterval, cfg.WatcherStableChecks
+	if w.pollInterval <= 0 {
+		w.pollInterval = defaultWatcherPollInterval
+	}
+	if w.stableChecks < 1 {
+		w.stableChecks = defaultWatcherStableChecks
+	}
+	workers := cfg.WatcherWorkers
+	if workers < 1 {
+		workers = defaultWatcherWorkers
+	}
+	w.queue = make(chan func(), workers)
+	w.handling.Add(workers)
+	for range workers {
+		go w.work()
 	}
 
 	return w, nil
 }
 
+// work runs the files of the queue one at a time until the watcher stops
+func (w *LogWatcher) work() {
+	defer w.handling.Done()
+	for {
+		select {
+		case job := <-w.queue:
+			job()
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+// enqueue waits for a worker to run job and for job to return. It returns
+// false without running job when the watcher stops first.
+func (w *LogWatcher) enqueue(job func()) bool {
+	// Whichever of the worker and a stopping watcher claims the job first
+	// takes it out of queueDepth, a job dropped at shutdown never runs
+	var claimed atomic.Bool
+	done := make(chan struct{})
+	run := func() {
+		if !claimed.CompareAndSwap(false, true) {
+			return
+		}
+		w.queueDepth.Add(-1)
+		defer close(done)
+		job()
+	}
+
+	w.queueDepth.Add(1)
+	select {
+	case w.queue <- run:
+	case <-w.ctx.Done():
+		w.queueDepth.Add(-1)
+		return false
+	}
+
+	select {
+	case <-done:
+		return true
+	case <-w.ctx.Done():
+		if claimed.CompareAndSwap(false, true) {
+			w.queueDepth.Add(-1)
+		}
+		return false
+	}
+}
+
 // Start begins watching the log directory
 func (w *LogWatcher) Start() error {
 	log.Printf("Starting log watcher for directory: %s", w.logDir)
@@ -66,6 +245,9 @@ func (w *LogWatcher) Start() error {
 	if err := w.watcher.Add(w.logDir); err != nil {
 		return err
 	}
+	w.mu.Lock()
+	w.healthy = true
+	w.mu.Unlock()
 
 	// Process existing files first
 	w.processExistingFiles()
@@ -79,7 +261,7 @@ func (w *LogWatcher) Start() error {
 // processExistingFiles checks any log files that already exist in the directory
 // against the database of processed files
 func (w *LogWatcher) processExistingFiles() {
-	files, err := filepath.Glob(filepath.Join(w.logDir, "*.txt"))
+	files, err := listLogFiles(w.logDir)
 	if err != nil {
 		log.Printf("Error reading existing log files: %v", err)
 		return
@@ -115,10 +297,42 @@ func (w *LogWatcher) watch() {
 			if !ok {
 				return
 			}
+			w.mu.Lock()
+			w.watchErrors = 0
+			w.mu.Unlock()
+			w.lastEvent.Store(time.Now().UnixNano())
+
+			// The log directory itself was deleted or moved away, so no more
+			// events will arrive for it
+			if filepath.Clean(event.Name) == filepath.Clean(w.logDir) &&
+				event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				log.Printf("Log directory %s was removed, watching it again once it's recreated", w.logDir)
+				go w.recoverWatch()
+				continue
+			}
+
+			// We're only interested in events for log files, the content of
+			// extensionless files is checked once they're fully written
+			if !isLogFileName(event.Name) {
+				continue
+			}
 
-			// We're only interested in create events for .txt files
-			if event.Op&fsnotify.Create == fsnotify.Create && filepath.Ext(event.Name) == ".txt" {
-				w.handleNewFile(event.Name)
+			switch {
+			case event.Op&fsnotify.Create == fsnotify.Create:
+				// Files moved into the directory are reported as creates too
+				w.scheduleNewFile(event.Name)
+
+			case event.Op&fsnotify.Rename == fsnotify.Rename:
+				// Rename is reported for the old name. If the path still exists
+				// the file was moved into place, otherwise it was moved out and
+				// there is nothing to do.
+				if _, err := os.Stat(event.Name); err == nil {
+					w.scheduleNewFile(event.Name)
+				}
+
+			case event.Op&fsnotify.Write == fsnotify.Write:
+				// The file is still being written, restart its stability check
+				w.notifyWrite(event.Name)
 			}
 
 		case err, ok := <-w.watcher.Errors:
@@ -127,76 +341,372 @@ func (w *LogWatcher) watch() {
 			}
 			log.Printf("Watcher error: %v", err)
 
+			w.mu.Lock()
+			w.watchErrors++
+			failing := w.watchErrors >= maxWatchErrors
+			w.mu.Unlock()
+			if failing {
+				log.Printf("Watcher failed %d times in a row, watching %s again", maxWatchErrors, w.logDir)
+				go w.recoverWatch()
+			}
+
 		case <-w.ctx.Done():
 			return
 		}
 	}
 }
 
-// handleNewFile processes a newly added log file
+// recoverWatch re-creates the log directory if it's missing and adds it to
+// the watcher again, retrying with a growing delay until it succeeds or the
+// watcher is stopped. Files that appeared in the meantime are processed.
+func (w *LogWatcher) recoverWatch() {
+	w.mu.Lock()
+	if w.recovering {
+		w.mu.Unlock()
+		return
+	}
+	w.recovering = true
+	w.healthy = false
+	w.mu.Unlock()
+
+	defer func() {
+		w.mu.Lock()
+		w.recovering = false
+		w.mu.Unlock()
+	}()
+
+	delay := w.recoverDelay
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		// The old watch is gone with the directory, removing it may fail
+		w.watcher.Remove(w.logDir)
+
+		err := os.MkdirAll(w.logDir, 0755)
+		if err == nil {
+			err = w.watcher.Add(w.logDir)
+		}
+		if err != nil {
+			delay = min(delay*2, maxRecoverDelay)
+			log.Printf("Failed to watch log directory %s (attempt %d), retrying in %s: %v", w.logDir, attempt, delay, err)
+			continue
+		}
+		break
+	}
+
+	w.mu.Lock()
+	w.healthy = true
+	w.watchErrors = 0
+	w.mu.Unlock()
+	log.Printf("Watching log directory %s again", w.logDir)
+
+	// Pick up files created before the watch was added again
+	files, err := listLogFiles(w.logDir)
+	if err != nil {
+		log.Printf("Error reading log directory %s: %v", w.logDir, err)
+		return
+	}
+	for _, file := range files {
+		w.mu.Lock()
+		processed := w.processedFiles[filepath.Base(file)]
+		w.mu.Unlock()
+		if !processed {
+			go w.handleNewFile(file)
+		}
+	}
+}
+
+// progress reports the files being imported, the new files waiting to be
+// imported and how many entries were added
+func (w *LogWatcher) progress() watcherProgress {
+	w.mu.Lock()
+	p := watcherProgress{
+		Processing:   make([]string, 0, len(w.processing)),
+		Queued:       len(w.pending),
+		QueueDepth:   int(w.queueDepth.Load()),
+		EntriesAdded: w.entriesAdded.Load(),
+	}
+	for name := range w.processing {
+		p.Processing = append(p.Processing, name)
+	}
+	w.mu.Unlock()
+
+	slices.Sort(p.Processing)
+	if last := w.lastEvent.Load(); last != 0 {
+		t := time.Unix(0, last)
+		p.LastEvent = &t
+	}
+	return p
+}
+
+// startProcessing marks a file as being imported until the returned function is called
+func (w *LogWatcher) startProcessing(fileName string) func() {
+	w.mu.Lock()
+	w.processing[fileName] = time.Now()
+	w.mu.Unlock()
+
+	return func() {
+		w.mu.Lock()
+		delete(w.processing, fileName)
+		w.mu.Unlock()
+	}
+}
+
+// isHealthy reports whether the log directory is currently being watched
+func (w *LogWatcher) isHealthy() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.healthy
+}
+
+// scheduleNewFile handles a new file once no other event arrived for it
+// during debounceDelay. Every event restarts the wait.
+func (w *LogWatcher) scheduleNewFile(filePath string) {
+	fileName := filepath.Base(filePath)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if timer, ok := w.debounce[fileName]; ok {
+		timer.Reset(w.debounceDelay)
+		return
+	}
+	w.debounce[fileName] = time.AfterFunc(w.debounceDelay, func() {
+		w.mu.Lock()
+		delete(w.debounce, fileName)
+		w.mu.Unlock()
+
+		w.handleNewFile(filePath)
+	})
+}
+
+// handleNewFile processes a newly added log file once it's fully written,
+// waiting for a free worker to import it
 func (w *LogWatcher) handleNewFile(filePath string) {
 	fileName := filepath.Base(filePath)
 
 	w.mu.Lock()
-	// Check if we've already processed this file
-	if w.processedFiles[fileName] {
+	// Files showing up while the watcher stops are left for the next start
+	if w.ctx.Err() != nil {
 		w.mu.Unlock()
 		return
 	}
+	// Check if this file is already waiting to be processed
+	if _, waiting := w.pending[fileName]; waiting {
+		w.mu.Unlock()
+		return
+	}
+	w.handling.Add(1)
+	defer w.handling.Done()
+
+	// A file with a known name is only processed again if its content changed
+	known := w.processedFiles[fileName]
 
 	// Mark as processed in our memory map
 	w.processedFiles[fileName] = true
+	reset := make(chan struct{}, 1)
+	w.pending[fileName] = reset
 	w.mu.Unlock()
 
-	// Wait a brief moment to make sure the file is fully written
-	// This helps avoid processing a file that's still being copied
-	time.Sleep(2 * time.Second)
+	// Wait until the file stops growing so we don't ingest a partial copy
+	err := waitForStableFile(w.ctx, filePath, w.pollInterval, w.stableChecks, reset)
 
-	log.Printf("Processing new log file: %s", fileName)
+	w.mu.Lock()
+	delete(w.pending, fileName)
+	w.mu.Unlock()
 
-	// Create a context with timeout for processing the file
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	if err != nil {
+		log.Printf("Error waiting for log file %s to be fully written: %v", fileName, err)
+		return
+	}
+
+	if !looksLikeCredentialFile(filePath) {
+		log.Printf("Ignoring %s: doesn't look like a credential log", fileName)
+		if !known {
+			w.mu.Lock()
+			delete(w.processedFiles, fileName)
+			w.mu.Unlock()
+		}
+		return
+	}
+
+	w.enqueue(func() { w.importNewFile(filePath, known) })
+}
+
+// importNewFile imports a new log file from a worker. A known file is only
+// imported again if its content changed.
+func (w *LogWatcher) importNewFile(filePath string, known bool) {
+	fileName := filepath.Base(filePath)
+
+	// Create a context with timeout for processing the file, canceled
+	// when the watcher stops so the import is rolled back
+	ctx, cancel := context.WithTimeout(w.ctx, 5*time.Minute)
 	defer cancel()
 
+	if w.skipTooLarge(ctx, filePath, currentConfig().MaxFileSize) {
+		if !known {
+			w.mu.Lock()
+			delete(w.processedFiles, fileName)
+			w.mu.Unlock()
+		}
+		return
+	}
+
+	if known {
+		changed, err := fileChanged(ctx, filePath)
+		if err != nil {
+			log.Printf("Error checking log file %s for changes: %v", fileName, err)
+			return
+		}
+		if !changed {
+			return
+		}
+		log.Printf("Log file %s changed since it was processed, reprocessing", fileName)
+	}
+
+	log.Printf("Processing new log file: %s", fileName)
+
 	// Process the file
-	count, err := processLogFile(ctx, filePath)
+	done := w.startProcessing(fileName)
+	start := time.Now()
+	stats, err := w.ingest(ctx, filePath)
+	done()
 	if err != nil {
 		log.Printf("Error processing new log file %s: %v", filePath, err)
 		return
 	}
+	w.entriesAdded.Add(int64(stats.Inserted))
+
+	if stats.DuplicateOf != "" {
+		log.Printf("Skipped new log file %s: same content as %s", fileName, stats.DuplicateOf)
+		return
+	}
 
-	// Record the processed file in the database
-	_, err = dbPool.Exec(ctx,
-		"INSERT INTO processed_log_files (filename, entries_added) VALUES ($1, $2) "+
-			"ON CONFLICT (filename) DO UPDATE SET processed_at = NOW(), entries_added = $2",
-		fileName, count)
+	log.Printf("Successfully processed new log file %s: %d entries added, %d lines skipped",
+		fileName, stats.Inserted, stats.Skipped())
 
-	if err != nil {
-		log.Printf("Failed to record processed file in database: %v", err)
+	notifyWebhook(currentConfig(), webhookPayload{
+		Event:        webhookEventFile,
+		File:         fileName,
+		EntriesAdded: stats.Inserted,
+		Skipped:      stats.Skipped(),
+		DurationMs:   time.Since(start).Milliseconds(),
+	})
+}
+
+// ingestFile parses a log file into the database and records it as processed
+func (w *LogWatcher) ingestFile(ctx context.Context, filePath string) (ParseStats, error) {
+	return processLogFile(ctx, filePath, parser.Options{}, nil)
+}
+
+// notifyWrite resets the stability check of a file that is still pending
+func (w *LogWatcher) notifyWrite(filePath string) {
+	w.mu.Lock()
+	reset, ok := w.pending[filepath.Base(filePath)]
+	w.mu.Unlock()
+
+	if !ok {
+		return
 	}
 
-	log.Printf("Successfully processed new log file %s: %d entries added", fileName, count)
+	// Don't block if a reset is already queued
+	select {
+	case reset <- struct{}{}:
+	default:
+	}
 }
 
-// ProcessFile manually processes a specific log file
-func (w *LogWatcher) ProcessFile(filePath string) (int, error) {
+// waitForStableFile polls the size of a file every interval and returns once
+// it has stayed unchanged for the given number of consecutive checks.
+// A signal on reset restarts the count, e.g. when a write event arrives.
+func waitForStableFile(ctx context.Context, filePath string, interval time.Duration, checks int, reset <-chan struct{}) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastSize := int64(-1)
+	unchanged := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-reset:
+			unchanged = 0
+
+		case <-ticker.C:
+			info, err := os.Stat(filePath)
+			if err != nil {
+				return err
+			}
+
+			if info.Size() != lastSize {
+				lastSize = info.Size()
+				unchanged = 0
+				continue
+			}
+
+			unchanged++
+			if unchanged >= checks {
+				return nil
+			}
+		}
+	}
+}
+
+// errFileInProgress is returned by ProcessFile for a file that is being
+// imported already
+var errFileInProgress = errors.New("file is being imported already")
+
+// ProcessFile manually processes a specific log file and returns its parse
+// statistics along with how long the import took. A file being imported
+// already fails with errFileInProgress.
+func (w *LogWatcher) ProcessFile(filePath string, opts parser.Options) (ParseStats, time.Duration, error) {
 	fileName := filepath.Base(filePath)
 
+	// The file is claimed under the same lock, so a second request for it
+	// can't pass the checks below before this import starts
 	w.mu.Lock()
-	// If file was already processed, we could check the database to see how many entries were added previously
-	if w.processedFiles[fileName] {
-		var entriesAdded int
+	if _, busy := w.processing[fileName]; busy {
+		w.mu.Unlock()
+		return ParseStats{}, 0, fmt.Errorf("%w: %s", errFileInProgress, fileName)
+	}
+	processed := w.processedFiles[fileName]
+	w.processing[fileName] = time.Now()
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		delete(w.processing, fileName)
+		w.mu.Unlock()
+	}()
+
+	// If file was already processed and hasn't changed, report how many entries were added previously.
+	// The database is queried without holding the lock so the watcher isn't blocked meanwhile.
+	// Files skipped for their size are imported now, and without records every
+	// file is.
+	if processed && recordsImports(currentConfig()) {
+		var entriesAdded, duplicatesSkipped int
+		var durationMs int64
+		var status string
 		err := dbPool.QueryRow(context.Background(),
-			"SELECT entries_added FROM processed_log_files WHERE filename = $1",
-			fileName).Scan(&entriesAdded)
-		if err == nil {
-			w.mu.Unlock()
-			log.Printf("File %s was already processed with %d entries", fileName, entriesAdded)
-			return entriesAdded, nil
+			"SELECT entries_added, duplicates_skipped, duration_ms, status FROM processed_log_files WHERE filename = $1",
+			fileName).Scan(&entriesAdded, &duplicatesSkipped, &durationMs, &status)
+		if err == nil && status == fileStatusProcessed {
+			changed, err := fileChanged(context.Background(), filePath)
+			if err != nil || !changed {
+				log.Printf("File %s was already processed with %d entries", fileName, entriesAdded)
+				return ParseStats{Inserted: entriesAdded, SkippedDuplicate: duplicatesSkipped}, time.Duration(durationMs) * time.Millisecond, nil
+			}
+			log.Printf("File %s changed since it was processed, reprocessing", fileName)
 		}
 	}
 
 	// Mark as processed in memory
+	w.mu.Lock()
 	w.processedFiles[fileName] = true
 	w.mu.Unlock()
 
@@ -206,36 +716,74 @@ func (w *LogWatcher) ProcessFile(filePath string) (int, error) {
 
 	// Process the file
 	log.Printf("Manually processing log file: %s", fileName)
-	count, err := processLogFile(ctx, filePath)
+	start := time.Now()
+	stats, err := processLogFile(ctx, filePath, opts, nil)
+	duration := time.Since(start)
 
 	if err != nil {
-		return 0, err
+		return stats, duration, err
 	}
+	w.entriesAdded.Add(int64(stats.Inserted))
 
-	// Record the processed file in the database
-	_, dbErr := dbPool.Exec(ctx,
-		"INSERT INTO processed_log_files (filename, entries_added) VALUES ($1, $2) "+
-			"ON CONFLICT (filename) DO UPDATE SET processed_at = NOW(), entries_added = $2",
-		fileName, count)
+	return stats, duration, nil
+}
 
-	if dbErr != nil {
-		log.Printf("Warning: Failed to record processed file in database: %v", dbErr)
+// processedFileNames returns a sorted copy of the processed file names, so
+// callers never touch the map outside the lock
+func (w *LogWatcher) processedFileNames() []string {
+	w.mu.Lock()
+	names := make([]string, 0, len(w.processedFiles))
+	for name := range w.processedFiles {
+		names = append(names, name)
 	}
+	w.mu.Unlock()
+
+	slices.Sort(names)
+	return names
+}
 
-	return count, nil
+// resetProcessedFiles forgets every processed file, so files are imported
+// again the next time they appear
+func (w *LogWatcher) resetProcessedFiles() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.processedFiles = make(map[string]bool)
 }
 
-// Stop stops the watcher
+// Stop stops the watcher. Files being imported are canceled and rolled
+// back, Stop waits up to stopTimeout for them to finish.
 func (w *LogWatcher) Stop() error {
+	// Canceled under mu so no file is added to handling after the check of
+	// handleNewFile, while Stop waits for them
+	w.mu.Lock()
 	w.cancel()
-	return w.watcher.Close()
+	for name, timer := range w.debounce {
+		timer.Stop()
+		delete(w.debounce, name)
+	}
+	w.mu.Unlock()
+
+	err := w.watcher.Close()
+
+	done := make(chan struct{})
+	go func() {
+		w.handling.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(w.stopTimeout):
+		log.Printf("Warning: Log watcher stopped with files still being processed after %s", w.stopTimeout)
+	}
+
+	return err
 }
 
 // loadProcessedFiles loads the list of previously processed files from the database
 func (w *LogWatcher) loadProcessedFiles() error {
 	// Query the database for previously processed files
 	rows, err := dbPool.Query(context.Background(),
-		"SELECT filename FROM processed_log_files")
+		"SELECT filename FROM processed_log_files WHERE status = $1", fileStatusProcessed)
 	if err != nil {
 		return fmt.Errorf("failed to query processed files: %w", err)
 	}
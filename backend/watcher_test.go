@@ -0,0 +1,663 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hello-world/backend/parser"
+)
+
+func TestWaitForStableFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "growing.txt")
+	if err := os.WriteFile(filePath, []byte("https://a.com:user:pass\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep appending to the file for a while to simulate a slow copy
+	const writes = 8
+	done := make(chan time.Time, 1)
+	go func() {
+		f, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		for i := 0; i < writes; i++ {
+			time.Sleep(30 * time.Millisecond)
+			f.WriteString("https://b.com:user:pass\n")
+		}
+		done <- time.Now()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := waitForStableFile(ctx, filePath, 20*time.Millisecond, 3, nil); err != nil {
+		t.Fatalf("waitForStableFile returned error: %v", err)
+	}
+	returned := time.Now()
+
+	var lastWrite time.Time
+	select {
+	case lastWrite = <-done:
+	default:
+		t.Fatal("waitForStableFile returned while the file was still growing")
+	}
+
+	if returned.Before(lastWrite) {
+		t.Errorf("returned at %v, before the last write at %v", returned, lastWrite)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(24 * (writes + 1)); info.Size() != want {
+		t.Errorf("file size = %d, want %d", info.Size(), want)
+	}
+}
+
+func TestWaitForStableFileReset(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "static.txt")
+	if err := os.WriteFile(filePath, []byte("https://a.com:user:pass\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep signalling writes so the file never counts as stable
+	reset := make(chan struct{}, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case reset <- struct{}{}:
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	if err := waitForStableFile(ctx, filePath, 20*time.Millisecond, 3, reset); err != context.DeadlineExceeded {
+		t.Errorf("waitForStableFile error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestWatcherPicksUpRenamedFile(t *testing.T) {
+	logDir := t.TempDir()
+
+	w, err := newLogWatcher(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.pollInterval = 10 * time.Millisecond
+
+	ingested := make(chan string, 10)
+	w.ingest = func(ctx context.Context, filePath string) (ParseStats, error) {
+		ingested <- filepath.Base(filePath)
+		return ParseStats{Stats: parser.Stats{Total: 1}, Inserted: 1}, nil
+	}
+
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// Write the file elsewhere and move it into the watched directory
+	tmpPath := filepath.Join(t.TempDir(), "upload.tmp")
+	if err := os.WriteFile(tmpPath, []byte("https://a.com:user:pass\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(logDir, "moved.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case name := <-ingested:
+		if name != "moved.txt" {
+			t.Errorf("ingested %q, want %q", name, "moved.txt")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("renamed file was not processed")
+	}
+
+	// Moving it back out must not trigger another run
+	if err := os.Rename(filepath.Join(logDir, "moved.txt"), tmpPath); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case name := <-ingested:
+		t.Errorf("unexpected second ingestion of %q", name)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestWatcherStatusConcurrentAccess(t *testing.T) {
+	logDir := t.TempDir()
+	setConfig(Config{LogDir: logDir})
+	defer setConfig(Config{})
+
+	w, err := newLogWatcher(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	w.pollInterval = time.Millisecond
+	w.stableChecks = 1
+	w.ingest = func(ctx context.Context, filePath string) (ParseStats, error) {
+		return ParseStats{Inserted: 1}, nil
+	}
+
+	logWatcher = w
+	defer func() { logWatcher = nil }()
+
+	const files = 200
+	for i := 0; i < files; i++ {
+		if err := os.WriteFile(filepath.Join(logDir, fmt.Sprintf("file%03d.txt", i)), []byte("https://a.com:user:pass\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Process files while /watcher-status is requested in parallel
+	var wg sync.WaitGroup
+	for i := 0; i < files; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w.handleNewFile(filepath.Join(logDir, fmt.Sprintf("file%03d.txt", i)))
+		}(i)
+	}
+
+	app := newApp()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				resp, err := app.Test(httptest.NewRequest("GET", "/api/watcher-status", nil), testConfig)
+				if err != nil {
+					t.Errorf("GET /api/watcher-status failed: %v", err)
+					return
+				}
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var status struct {
+		Processed int `json:"processed"`
+	}
+	if code := getJSON(t, "/api/watcher-status", &status); code != 200 {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if status.Processed != files {
+		t.Errorf("processed = %d, want %d", status.Processed, files)
+	}
+}
+
+func TestWatcherRecoversRemovedDirectory(t *testing.T) {
+	logDir := filepath.Join(t.TempDir(), "data")
+
+	w, err := newLogWatcher(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.pollInterval = 10 * time.Millisecond
+	w.recoverDelay = 200 * time.Millisecond
+
+	ingested := make(chan string, 10)
+	w.ingest = func(ctx context.Context, filePath string) (ParseStats, error) {
+		ingested <- filepath.Base(filePath)
+		return ParseStats{Stats: parser.Stats{Total: 1}, Inserted: 1}, nil
+	}
+
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	waitForHealth := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for w.isHealthy() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("watcher health did not become %v", want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	if !w.isHealthy() {
+		t.Fatal("expected a started watcher to be healthy")
+	}
+	if err := os.RemoveAll(logDir); err != nil {
+		t.Fatal(err)
+	}
+	waitForHealth(false)
+
+	// Recreate the directory with a file in it before the watch is added again
+	if err := os.Mkdir(logDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(logDir, "early.txt"), []byte("https://a.com:user:pass\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitForHealth(true)
+
+	// Files written after the recovery are picked up by the new watch
+	if err := os.WriteFile(filepath.Join(logDir, "late.txt"), []byte("https://b.com:user:pass\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	for len(got) < 2 {
+		select {
+		case name := <-ingested:
+			got[name] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("ingested %v, want early.txt and late.txt", got)
+		}
+	}
+	if !got["early.txt"] || !got["late.txt"] {
+		t.Errorf("ingested %v, want early.txt and late.txt", got)
+	}
+}
+
+func TestWatcherSkipsLargeFiles(t *testing.T) {
+	logDir := t.TempDir()
+	setConfig(Config{LogDir: logDir, MaxFileSize: 30})
+	defer setConfig(Config{})
+
+	w, err := newLogWatcher(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	w.pollInterval = time.Millisecond
+	w.stableChecks = 1
+
+	var ingested, skipped []string
+	w.ingest = func(ctx context.Context, filePath string) (ParseStats, error) {
+		ingested = append(ingested, filepath.Base(filePath))
+		return ParseStats{Inserted: 1}, nil
+	}
+	w.skipTooLarge = func(ctx context.Context, filePath string, maxSize int64) bool {
+		if !fileTooLarge(filePath, maxSize) {
+			return false
+		}
+		skipped = append(skipped, filepath.Base(filePath))
+		return true
+	}
+
+	for name, content := range map[string]string{
+		"small.txt": "https://a.com:user:pass\n",
+		"big.txt":   "https://b.com:user:pass\nhttps://c.com:user:pass\n",
+	} {
+		path := filepath.Join(logDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		w.handleNewFile(path)
+	}
+
+	if len(ingested) != 1 || ingested[0] != "small.txt" {
+		t.Errorf("ingested %v, want only small.txt", ingested)
+	}
+	if len(skipped) != 1 || skipped[0] != "big.txt" {
+		t.Errorf("skipped %v, want big.txt", skipped)
+	}
+	// A skipped file is picked up again when it's replaced
+	if names := w.processedFileNames(); len(names) != 1 || names[0] != "small.txt" {
+		t.Errorf("processed files = %v, want only small.txt", names)
+	}
+}
+
+func TestWatcherStatusProgress(t *testing.T) {
+	logDir := t.TempDir()
+	setConfig(Config{LogDir: logDir})
+	defer setConfig(Config{})
+
+	w, err := newLogWatcher(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.pollInterval = 10 * time.Millisecond
+	w.stableChecks = 1
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	w.ingest = func(ctx context.Context, filePath string) (ParseStats, error) {
+		close(started)
+		<-release
+		return ParseStats{Inserted: 7}, nil
+	}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	logWatcher = w
+	defer func() { logWatcher = nil }()
+
+	if err := os.WriteFile(filepath.Join(logDir, "big.txt"), []byte("https://a.com:user:pass\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("file was not processed")
+	}
+
+	var status struct {
+		Processing   []string   `json:"processing"`
+		Queued       int        `json:"queued"`
+		EntriesAdded int64      `json:"entriesAdded"`
+		LastEvent    *time.Time `json:"lastEvent"`
+	}
+	if code := getJSON(t, "/api/watcher-status", &status); code != 200 {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if !slices.Equal(status.Processing, []string{"big.txt"}) || status.Queued != 0 || status.EntriesAdded != 0 {
+		t.Errorf("mid-processing status = %+v, want big.txt processing and nothing added yet", status)
+	}
+	if status.LastEvent == nil || time.Since(*status.LastEvent) > time.Minute {
+		t.Errorf("lastEvent = %v, want the time of the create event", status.LastEvent)
+	}
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if code := getJSON(t, "/api/watcher-status", &status); code != 200 {
+			t.Fatalf("status = %d, want 200", code)
+		}
+		if len(status.Processing) == 0 && status.EntriesAdded == 7 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status after processing = %+v, want nothing processing and 7 entries added", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatcherQueueBoundsConcurrency(t *testing.T) {
+	logDir := t.TempDir()
+	setConfig(Config{LogDir: logDir, WatcherWorkers: 2})
+	defer setConfig(Config{})
+
+	w, err := newLogWatcher(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	w.pollInterval = time.Millisecond
+	w.stableChecks = 1
+
+	// Imports block until released so the queue fills up
+	var running, maxRunning atomic.Int64
+	release := make(chan struct{})
+	w.ingest = func(ctx context.Context, filePath string) (ParseStats, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := maxRunning.Load()
+			if n <= old || maxRunning.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		<-release
+		return ParseStats{Inserted: 1}, nil
+	}
+
+	logWatcher = w
+	defer func() { logWatcher = nil }()
+
+	const files = 20
+	var wg sync.WaitGroup
+	for i := 0; i < files; i++ {
+		path := filepath.Join(logDir, fmt.Sprintf("burst%02d.txt", i))
+		if err := os.WriteFile(path, []byte("https://a.com:user:pass\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.handleNewFile(path)
+		}()
+	}
+
+	// Two files are imported while the others wait in the queue
+	var status struct {
+		Processing []string `json:"processing"`
+		QueueDepth int      `json:"queueDepth"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if code := getJSON(t, "/api/watcher-status", &status); code != 200 {
+			t.Fatalf("status = %d, want 200", code)
+		}
+		if len(status.Processing) == 2 && status.QueueDepth == files-2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status = %+v, want 2 files processing and %d queued", status, files-2)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	close(release)
+	wg.Wait()
+	if got := maxRunning.Load(); got != 2 {
+		t.Errorf("at most %d files were imported at once, want 2", got)
+	}
+	if got := w.entriesAdded.Load(); got != files {
+		t.Errorf("entries added = %d, want %d", got, files)
+	}
+	if depth := w.progress().QueueDepth; depth != 0 {
+		t.Errorf("queue depth after the burst = %d, want 0", depth)
+	}
+}
+
+func TestWatcherStopDropsQueuedFiles(t *testing.T) {
+	logDir := t.TempDir()
+	setConfig(Config{LogDir: logDir, WatcherWorkers: 1})
+	defer setConfig(Config{})
+
+	w, err := newLogWatcher(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.pollInterval = time.Millisecond
+	w.stableChecks = 1
+	w.stopTimeout = 50 * time.Millisecond
+
+	// The first import blocks the only worker so the other files queue up
+	release := make(chan struct{})
+	var imported atomic.Int64
+	w.ingest = func(ctx context.Context, filePath string) (ParseStats, error) {
+		imported.Add(1)
+		<-release
+		return ParseStats{}, nil
+	}
+
+	const files = 5
+	var wg sync.WaitGroup
+	for i := 0; i < files; i++ {
+		path := filepath.Join(logDir, fmt.Sprintf("queued%d.txt", i))
+		if err := os.WriteFile(path, []byte("https://a.com:user:pass\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.handleNewFile(path)
+		}()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for w.progress().QueueDepth != files-1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("queue depth = %d, want %d", w.progress().QueueDepth, files-1)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	w.Stop()
+	close(release)
+	wg.Wait()
+
+	// Files still queued are dropped rather than imported after the stop
+	if depth := w.progress().QueueDepth; depth != 0 {
+		t.Errorf("queue depth after stopping = %d, want 0", depth)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if got := imported.Load(); got != 1 {
+		t.Errorf("imported %d files, want only the one running when stopped", got)
+	}
+}
+
+func TestWatcherStopCancelsProcessing(t *testing.T) {
+	logDir := t.TempDir()
+	setConfig(Config{LogDir: logDir})
+	defer setConfig(Config{})
+
+	w, err := newLogWatcher(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.pollInterval = 10 * time.Millisecond
+	w.stableChecks = 1
+	w.stopTimeout = 5 * time.Second
+
+	// The import only returns once its context is canceled
+	started := make(chan struct{})
+	var canceled atomic.Bool
+	w.ingest = func(ctx context.Context, filePath string) (ParseStats, error) {
+		close(started)
+		<-ctx.Done()
+		canceled.Store(true)
+		return ParseStats{}, ctx.Err()
+	}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(logDir, "slow.txt"), []byte("https://a.com:user:pass\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		w.Stop()
+		t.Fatal("file was not processed")
+	}
+
+	stopped := time.Now()
+	if err := w.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if !canceled.Load() {
+		t.Error("Stop returned before the import was canceled")
+	}
+	if elapsed := time.Since(stopped); elapsed >= w.stopTimeout {
+		t.Errorf("Stop took %s, want it to return once the import is canceled", elapsed)
+	}
+
+	// Files handled after Stop aren't processed
+	w.handleNewFile(filepath.Join(logDir, "late.txt"))
+	if progress := w.progress(); len(progress.Processing) != 0 || progress.Queued != 0 {
+		t.Errorf("progress after Stop = %+v, want nothing in flight", progress)
+	}
+}
+
+func TestWatcherDebouncesEvents(t *testing.T) {
+	logDir := t.TempDir()
+
+	w, err := newLogWatcher(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	w.debounceDelay = 50 * time.Millisecond
+	w.pollInterval = time.Millisecond
+	w.stableChecks = 1
+
+	var mu sync.Mutex
+	ingested := 0
+	w.ingest = func(ctx context.Context, filePath string) (ParseStats, error) {
+		mu.Lock()
+		ingested++
+		mu.Unlock()
+		return ParseStats{Inserted: 1}, nil
+	}
+
+	filePath := filepath.Join(logDir, "copy.txt")
+	if err := os.WriteFile(filePath, []byte("https://a.com:user:pass\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A burst of create and rename events for the same copy
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			time.Sleep(time.Duration(i) * time.Millisecond)
+			w.scheduleNewFile(filePath)
+		}(i)
+	}
+	wg.Wait()
+
+	// Wait for the debounced run to finish and any stray ones to show up
+	time.Sleep(300 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if ingested != 1 {
+		t.Errorf("file was ingested %d times, want 1", ingested)
+	}
+}
+
+func TestProcessFileInProgress(t *testing.T) {
+	logDir := t.TempDir()
+	setConfig(Config{LogDir: logDir})
+	defer setConfig(Config{})
+
+	w, err := newLogWatcher(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	filePath := filepath.Join(logDir, "busy.txt")
+	if err := os.WriteFile(filePath, []byte("https://a.com:user:pass\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The watcher is importing the file, so a manual import of it bails out
+	// before touching the database
+	done := w.startProcessing("busy.txt")
+	defer done()
+	if _, _, err := w.ProcessFile(filePath, parser.Options{}); !errors.Is(err, errFileInProgress) {
+		t.Fatalf("ProcessFile = %v, want %v", err, errFileInProgress)
+	}
+	if names := w.processedFileNames(); len(names) != 0 {
+		t.Errorf("processed files = %v, want none", names)
+	}
+	if p := w.progress(); !slices.Equal(p.Processing, []string{"busy.txt"}) {
+		t.Errorf("processing = %v, want busy.txt still claimed by the watcher", p.Processing)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Webhook event types, enabled with WEBHOOK_EVENTS
+const (
+	// webhookEventImportRun is sent when a directory import finishes
+	webhookEventImportRun = "import-run"
+	// webhookEventFile is sent for every file picked up by the watcher
+	webhookEventFile = "file"
+)
+
+const (
+	// webhookAttempts is how many times a notification is sent before giving up
+	webhookAttempts = 3
+	// webhookTimeout bounds each attempt so a slow endpoint can't pile up requests
+	webhookTimeout = 5 * time.Second
+)
+
+// webhookRetryDelay is the wait between attempts
+var webhookRetryDelay = time.Second
+
+// webhookPayload is the JSON body posted to WEBHOOK_URL
+type webhookPayload struct {
+	Event        string `json:"event"`
+	File         string `json:"file"`
+	EntriesAdded int    `json:"entriesAdded"`
+	Skipped      int    `json:"skipped"`
+	DurationMs   int64  `json:"durationMs"`
+	// FilesProcessed and Status are only set for import runs
+	FilesProcessed int    `json:"filesProcessed,omitempty"`
+	Status         string `json:"status,omitempty"`
+}
+
+// notifyWebhook posts the payload to the configured webhook in the background
+// when its event is enabled. Delivery failures are only logged so they never
+// affect ingestion.
+func notifyWebhook(c Config, payload webhookPayload) {
+	if c.WebhookURL == "" || !slices.Contains(c.WebhookEvents, payload.Event) {
+		return
+	}
+
+	go func() {
+		if err := sendWebhook(c.WebhookURL, payload); err != nil {
+			log.Printf("Warning: Failed to send %s webhook: %v", payload.Event, err)
+		}
+	}()
+}
+
+// sendWebhook posts the payload to url, retrying failed attempts
+func sendWebhook(url string, payload webhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: webhookTimeout}
+	for attempt := 1; ; attempt++ {
+		err = postWebhook(client, url, body)
+		if err == nil || attempt == webhookAttempts {
+			return err
+		}
+		time.Sleep(webhookRetryDelay)
+	}
+}
+
+// postWebhook makes a single delivery attempt, any 2xx response is a success
+func postWebhook(client *http.Client, url string, body []byte) error {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
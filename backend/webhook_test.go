@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// captureWebhook starts a server that fails the first failures requests and
+// sends every payload it accepts to the returned channel
+func captureWebhook(t *testing.T, failures int32) (*httptest.Server, <-chan webhookPayload) {
+	t.Helper()
+
+	payloads := make(chan webhookPayload, 10)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var payload webhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("failed to decode webhook payload: %v", err)
+		}
+		payloads <- payload
+	}))
+	t.Cleanup(server.Close)
+
+	return server, payloads
+}
+
+// receivePayload waits for the next captured payload
+func receivePayload(t *testing.T, payloads <-chan webhookPayload) webhookPayload {
+	t.Helper()
+
+	select {
+	case payload := <-payloads:
+		return payload
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for webhook")
+		return webhookPayload{}
+	}
+}
+
+func TestSendWebhookRetries(t *testing.T) {
+	defer func(delay time.Duration) { webhookRetryDelay = delay }(webhookRetryDelay)
+	webhookRetryDelay = time.Millisecond
+
+	server, payloads := captureWebhook(t, webhookAttempts-1)
+
+	want := webhookPayload{Event: webhookEventFile, File: "dump.txt", EntriesAdded: 3, Skipped: 1, DurationMs: 42}
+	if err := sendWebhook(server.URL, want); err != nil {
+		t.Fatalf("sendWebhook failed: %v", err)
+	}
+	if got := receivePayload(t, payloads); got != want {
+		t.Errorf("payload = %+v, want %+v", got, want)
+	}
+
+	failing, _ := captureWebhook(t, webhookAttempts)
+	if err := sendWebhook(failing.URL, want); err == nil {
+		t.Error("expected an error once every attempt failed")
+	}
+}
+
+func TestNotifyWebhookEvents(t *testing.T) {
+	server, payloads := captureWebhook(t, 0)
+	c := Config{WebhookURL: server.URL, WebhookEvents: []string{webhookEventImportRun}}
+
+	// Disabled events are not sent
+	notifyWebhook(c, webhookPayload{Event: webhookEventFile, File: "skipped.txt"})
+	notifyWebhook(c, webhookPayload{Event: webhookEventImportRun, File: "./data"})
+
+	if got := receivePayload(t, payloads); got.Event != webhookEventImportRun {
+		t.Errorf("event = %q, want %q", got.Event, webhookEventImportRun)
+	}
+	select {
+	case payload := <-payloads:
+		t.Errorf("unexpected webhook %+v", payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestParseLogDirectoryWebhook(t *testing.T) {
+	setupTestDB(t)
+	server, payloads := captureWebhook(t, 0)
+
+	c := currentConfig()
+	c.WebhookURL = server.URL
+	c.WebhookEvents = defaultWebhookEvents
+	setConfig(c)
+
+	logDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(logDir, "run.txt"), []byte("https://a.com:user:pass\nonly:two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ParseLogDirectory(logDir, 0); err != nil {
+		t.Fatalf("ParseLogDirectory failed: %v", err)
+	}
+
+	got := receivePayload(t, payloads)
+	if got.Event != webhookEventImportRun || got.File != logDir || got.EntriesAdded != 1 ||
+		got.Skipped != 1 || got.FilesProcessed != 1 || got.Status != importRunCompleted {
+		t.Errorf("payload = %+v", got)
+	}
+}